@@ -11,6 +11,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
@@ -20,13 +21,16 @@ import (
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
 	"github.com/ucloud/redis-cluster-operator/pkg/apis"
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	config2 "github.com/ucloud/redis-cluster-operator/pkg/config"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller"
 	"github.com/ucloud/redis-cluster-operator/version"
@@ -38,6 +42,12 @@ var (
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
 )
+
+var (
+	enableWebhooks bool
+	webhookPort    int
+	webhookCertDir string
+)
 var log = logf.Log.WithName("cmd")
 
 func printVersion() {
@@ -57,6 +67,10 @@ func main() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
 	config2.RedisConf().AddFlags(pflag.CommandLine)
+	pflag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the defaulting and validating admission webhooks of the custom resources")
+	pflag.IntVar(&webhookPort, "webhook-port", 9443, "Port of the admission webhook server")
+	pflag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"Directory containing the tls.crt and tls.key of the admission webhook server")
 
 	pflag.Parse()
 
@@ -98,6 +112,7 @@ func main() {
 		Namespace:          namespace,
 		MapperProvider:     restmapper.NewDynamicRESTMapper,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
 	})
 	if err != nil {
 		log.Error(err, "")
@@ -111,6 +126,19 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
+	// ServiceMonitors and PodMonitors of the clusters, the prometheus-operator CRDs may not be installed
+	if err := monitoringv1.AddToScheme(mgr.GetScheme()); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	if enableWebhooks {
+		mgr.GetWebhookServer().CertDir = webhookCertDir
+		if err := setupWebhooks(mgr); err != nil {
+			log.Error(err, "unable to setup the admission webhooks")
+			os.Exit(1)
+		}
+	}
 
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr); err != nil {
@@ -178,3 +206,17 @@ func serveCRMetrics(cfg *rest.Config) error {
 	}
 	return nil
 }
+
+// setupWebhooks registers the defaulting and validating admission webhooks of the custom resources, at
+// /mutate-redis-kun-v1alpha1-<kind> and /validate-redis-kun-v1alpha1-<kind>.
+func setupWebhooks(mgr manager.Manager) error {
+	for _, obj := range []k8sruntime.Object{
+		&redisv1alpha1.DistributedRedisCluster{},
+		&redisv1alpha1.RedisClusterBackup{},
+	} {
+		if err := builder.WebhookManagedBy(mgr).For(obj).Complete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -16,6 +16,10 @@ show_help() {
   echo "    --bucket=BUCKET                name of bucket"
   echo "    --folder=FOLDER                name of folder in bucket"
   echo "    --snapshot=SNAPSHOT            name of snapshot"
+  echo "    --source-dir=DIR               path to directory holding the redis data to upload"
+  echo "    --lastsave=LASTSAVE            unix time of the save of the RDB file to upload"
+  echo "    --sha256=SHA256                SHA-256 checksum the RDB file of the snapshot must match"
+  echo "    --replay-port=PORT             port of the local redis server loading the snapshot to replay"
   echo "    --enable-analytics=ENABLE_ANALYTICS   send analytical events to Google Analytics (default false)"
 }
 
@@ -29,8 +33,14 @@ REDIS_BUCKET=${REDIS_BUCKET:-}
 REDIS_FOLDER=${REDIS_FOLDER:-}
 REDIS_SNAPSHOT=${REDIS_SNAPSHOT:-}
 REDIS_DATA_DIR=${REDIS_DATA_DIR:-/data}
+REDIS_SOURCE_DIR=${REDIS_SOURCE_DIR:-}
+REDIS_LASTSAVE=${REDIS_LASTSAVE:-}
+REDIS_REPLAY_PORT=${REDIS_REPLAY_PORT:-6380}
+REDIS_SHA256=${REDIS_SHA256:-}
 REDIS_RESTORE_SUCCEEDED=${REDIS_RESTORE_SUCCEEDED:-0}
+REDIS_TLS_DIR=${REDIS_TLS_DIR:-}
 OSM_CONFIG_FILE=/etc/osm/config
+TERMINATION_LOG=${TERMINATION_LOG:-/dev/termination-log}
 ENABLE_ANALYTICS=${ENABLE_ANALYTICS:-false}
 
 op=$1
@@ -66,6 +76,22 @@ while test $# -gt 0; do
       export REDIS_SNAPSHOT=$(echo $1 | sed -e 's/^[^=]*=//g')
       shift
       ;;
+    --source-dir*)
+      export REDIS_SOURCE_DIR=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --lastsave*)
+      export REDIS_LASTSAVE=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --sha256*)
+      export REDIS_SHA256=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --replay-port*)
+      export REDIS_REPLAY_PORT=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
     --analytics* | --enable-analytics*)
       export ENABLE_ANALYTICS=$(echo $1 | sed -e 's/^[^=]*=//g')
       shift
@@ -93,6 +119,49 @@ fi
 #  sleep 5
 #done
 
+# write_artifact reports the object key, size, checksum and key count of the dump file in the
+# termination message of the container, they are recorded in the status of the backup
+write_artifact() {
+  local size sha keys
+  size=$(stat -c %s dump.rdb)
+  sha=$(sha256sum dump.rdb | awk '{print $1}')
+  # redis-check-rdb fails on a corrupted dump file
+  keys=$(redis-check-rdb dump.rdb | awk '/keys read/ {print $2}')
+  echo "{\"objectKey\":\"${REDIS_FOLDER}/${REDIS_SNAPSHOT}/dump.rdb\",\"size\":${size},\"sha256\":\"${sha}\",\"keys\":${keys:-0}}" >"${TERMINATION_LOG}"
+}
+
+# verify_checksum fails if the pulled dump file does not match the checksum of the backup
+verify_checksum() {
+  if [ -n "${REDIS_SHA256}" ]; then
+    echo "${REDIS_SHA256}  dump.rdb" | sha256sum -c -
+  fi
+}
+
+# write_nodes_conf writes the line of the dumped node in nodes.conf. The line of a replica is rewritten as the
+# one of a master owning the slots of its master, so that the restored node starts as the master of the shard
+write_nodes_conf() {
+  redis-cli -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" ${REDIS_TLS_ARGS} CLUSTER NODES | awk '
+    $3 ~ /myself/ { self = $0; id = $1; addr = $2; master = $4; slave = ($3 ~ /slave/) }
+    { line[$1] = $0 }
+    END {
+      if (!slave) { print self; exit 0 }
+      if (!(master in line)) { print "master " master " of the replica not found" > "/dev/stderr"; exit 1 }
+      n = split(line[master], f, " ")
+      printf "%s %s myself,master - 0 0 %s connected", id, addr, f[7]
+      # the slots being migrated, within brackets, are not restored
+      for (i = 9; i <= n; i++) if (f[i] !~ /^\[/) printf " %s", f[i]
+      printf "\n"
+    }' >nodes.conf
+}
+
+# the redis nodes only accept TLS connections when the certificate is mounted
+REDIS_TLS_ARGS=""
+REDIS_SERVER_TLS_ARGS=""
+if [ -n "${REDIS_TLS_DIR}" ]; then
+  REDIS_TLS_ARGS="--tls --cert ${REDIS_TLS_DIR}/tls.crt --key ${REDIS_TLS_DIR}/tls.key --cacert ${REDIS_TLS_DIR}/ca.crt"
+  REDIS_SERVER_TLS_ARGS="--tls-cert-file ${REDIS_TLS_DIR}/tls.crt --tls-key-file ${REDIS_TLS_DIR}/tls.key --tls-ca-cert-file ${REDIS_TLS_DIR}/ca.crt"
+fi
+
 # cleanup data dump dir
 mkdir -p "$REDIS_DATA_DIR"
 cd "$REDIS_DATA_DIR"
@@ -101,13 +170,29 @@ rm -rf *
 case "$op" in
   backup)
     echo "Dumping database......"
-    redis-cli --rdb dump.rdb -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}"
-    redis-cli -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" CLUSTER NODES | grep myself > nodes.conf
+    redis-cli --rdb dump.rdb -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" ${REDIS_TLS_ARGS}
+    write_nodes_conf
+    write_artifact
     echo "Uploading dump file to the backend......."
     osm --config "$OSM_CONFIG_FILE" sync "$REDIS_DATA_DIR" ceph:"$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT" -v
 
     echo "Backup successful"
     ;;
+  upload)
+    echo "Copying dump file......"
+    cp -p "${REDIS_SOURCE_DIR}/dump.rdb" dump.rdb
+    # the RDB file must be the one produced by the save at REDIS_LASTSAVE, not a newer one
+    if [ -n "${REDIS_LASTSAVE}" ] && [ "$(stat -c %Y dump.rdb)" -gt "${REDIS_LASTSAVE}" ]; then
+      echo "Dump file has been overwritten by a newer save"
+      exit 1
+    fi
+    write_nodes_conf
+    write_artifact
+    echo "Uploading dump file to the backend......."
+    osm --config "$OSM_CONFIG_FILE" sync "$REDIS_DATA_DIR" ceph:"$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT" -v
+
+    echo "Upload successful"
+    ;;
   restore)
     echo "Pulling backup file from the backend"
     if [ "${REDIS_RESTORE_SUCCEEDED}" == "1" ];then
@@ -117,9 +202,37 @@ case "$op" in
     index=$(echo "${POD_NAME}" | awk -F- '{print $NF}')
     REDIS_SNAPSHOT=${REDIS_SNAPSHOT}-${index}
     osm --config "$OSM_CONFIG_FILE" sync ceph:"$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT" "$REDIS_DATA_DIR" -v
+    verify_checksum
 
     echo "Recovery successful"
     ;;
+  replay)
+    echo "Pulling backup file from the backend"
+    osm --config "$OSM_CONFIG_FILE" sync ceph:"$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT" "$REDIS_DATA_DIR" -v
+    verify_checksum
+
+    echo "Loading dump file......"
+    # a standalone server, the keys are moved to the slots of the running cluster by the import
+    REDIS_REPLAY_PORT_ARGS="--port ${REDIS_REPLAY_PORT}"
+    if [ -n "${REDIS_TLS_DIR}" ]; then
+      # redis-cli uses the same TLS settings for the cluster and the local server
+      REDIS_REPLAY_PORT_ARGS="--port 0 --tls-port ${REDIS_REPLAY_PORT} ${REDIS_SERVER_TLS_ARGS}"
+    fi
+    # the server only listens on localhost and has no password, the import does not authenticate to it
+    redis-server ${REDIS_REPLAY_PORT_ARGS} --bind 127.0.0.1 --dir "$REDIS_DATA_DIR" --dbfilename dump.rdb \
+      --appendonly no --save "" --daemonize yes
+    until [ "$(redis-cli -p "${REDIS_REPLAY_PORT}" ${REDIS_TLS_ARGS} PING 2>/dev/null)" == "PONG" ]; do
+      echo "Waiting... dump file is loading"
+      sleep 1
+    done
+
+    echo "Replaying keys into the cluster......"
+    redis-cli -a "${REDIS_PASSWORD}" ${REDIS_TLS_ARGS} --cluster import "${REDIS_HOST}:${REDIS_PORT}" \
+      --cluster-from 127.0.0.1:"${REDIS_REPLAY_PORT}" --cluster-copy --cluster-replace
+    redis-cli -p "${REDIS_REPLAY_PORT}" ${REDIS_TLS_ARGS} SHUTDOWN NOSAVE || true
+
+    echo "Replay successful"
+    ;;
   *)
     (10)
     echo $"Unknown op!"
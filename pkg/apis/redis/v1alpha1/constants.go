@@ -25,6 +25,29 @@ const (
 	RedisClusterNodeRoleNone RedisRole = "None"
 )
 
+// ClusterConditionType is the type of a condition of a DistributedRedisCluster
+type ClusterConditionType string
+
+const (
+	// ClusterConditionReady is True when the cluster is healthy and matches its spec
+	ClusterConditionReady ClusterConditionType = "Ready"
+	// ClusterConditionSlotsCovered is True when every hash slot is owned by a master
+	ClusterConditionSlotsCovered ClusterConditionType = "SlotsCovered"
+	// ClusterConditionReplicasHealthy is True when every master has spec.clusterReplicas replicas
+	ClusterConditionReplicasHealthy ClusterConditionType = "ReplicasHealthy"
+	// ClusterConditionScaling is True while shards or replicas are added or removed
+	ClusterConditionScaling ClusterConditionType = "Scaling"
+	// ClusterConditionRebalancing is True while the masters and slots of the cluster are being dispatched
+	ClusterConditionRebalancing ClusterConditionType = "Rebalancing"
+	// ClusterConditionUpgrading is True while the pods of the cluster are rolling updated
+	ClusterConditionUpgrading ClusterConditionType = "Upgrading"
+	// ClusterConditionResizing is True while the data volumes are expanded to spec.storage.size
+	ClusterConditionResizing ClusterConditionType = "Resizing"
+	// ClusterConditionRestoreComplete is True when the cluster created from a backup has restored it,
+	// it is only set when spec.init is set
+	ClusterConditionRestoreComplete ClusterConditionType = "RestoreComplete"
+)
+
 // ClusterStatus Redis Cluster status
 type ClusterStatus string
 
@@ -63,18 +86,51 @@ const (
 	GenericKey = "redis.kun"
 
 	LabelClusterName = GenericKey + "/name"
+	// LabelShard is the index of the shard a redis pod belongs to
+	LabelShard = GenericKey + "/shard"
+	// LabelPodName is the name of the redis pod exposed by a service
+	LabelPodName = GenericKey + "/pod"
+	// LabelClusterNamespace is the namespace of the cluster of a resource created in another namespace
+	LabelClusterNamespace = GenericKey + "/namespace"
 
 	BackupKey         = ResourceSingularBackup + "." + GenericKey
 	LabelBackupStatus = BackupKey + "/status"
+	// LabelBackupName is the name of the backup a job belongs to
+	LabelBackupName = BackupKey + "/name"
+	// LabelBackupSchedule is the name of the schedule which created the backup
+	LabelBackupSchedule = BackupKey + "/schedule"
 
 	AnnotationJobType = GenericKey + "/job-type"
+	// AnnotationTemplateHash is the hash of the pod template the statefulSet was last updated with
+	AnnotationTemplateHash = GenericKey + "/template-hash"
+	// AnnotationPasswordRotatedAt is the time the password was last rotated, it is set on the Secret of the applied
+	// password and starts the grace period during which the previous password is still accepted
+	AnnotationPasswordRotatedAt = GenericKey + "/password-rotated-at"
 
 	JobTypeBackup  = "backup"
 	JobTypeRestore = "restore"
+	JobTypeReplay  = "replay"
 
 	PrometheusExporterPortNumber    = 9100
 	PrometheusExporterTelemetryPath = "/metrics"
 
 	BackupDumpDir  = "/data"
 	UtilVolumeName = "util-volume"
+
+	// TLSVolumeName is the volume of the certificate of the redis nodes, mounted in TLSMountPath
+	TLSVolumeName = "tls"
+	TLSMountPath  = "/tls"
+	// PasswordVolumeName is the volume of spec.passwordSecret, mounted in PasswordMountPath, the shutdown script
+	// reads the current password from it as the environment keeps the password the container was started with
+	PasswordVolumeName = "redis-password"
+	PasswordMountPath  = "/redis-password"
+	// AnnounceVolumeName is the volume of the announce configMap, mounted in AnnounceMountPath, the redis nodes
+	// read their cluster-announce-* config from the file named after their pod when they start
+	AnnounceVolumeName = "redis-announce"
+	AnnounceMountPath  = "/announce"
+	// ACLOperatorUser is the ACL user the operator connects with when spec.users is set
+	ACLOperatorUser = OperatorName
+
+	// TLSDirENV tells the scripts of the redis and backup images to connect over TLS with the certificate in the dir
+	TLSDirENV = "REDIS_TLS_DIR"
 )
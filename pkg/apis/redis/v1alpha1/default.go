@@ -17,9 +17,18 @@ const (
 )
 
 func (in *DistributedRedisCluster) Validate() {
+	in.Default()
 	if in.Spec.MasterSize < minMasterSize {
 		in.Spec.MasterSize = minMasterSize
 	}
+}
+
+// Default sets the defaults of the spec, it is applied by the mutating webhook at admission time and by
+// Validate for the clusters admitted without it.
+func (in *DistributedRedisCluster) Default() {
+	if in.Spec.MasterSize == 0 {
+		in.Spec.MasterSize = minMasterSize
+	}
 
 	//if in.Spec.ClusterReplicas < minClusterReplicas {
 	//	in.Spec.ClusterReplicas = minClusterReplicas
@@ -45,6 +54,9 @@ func (in *DistributedRedisCluster) Validate() {
 		if mon.Prometheus.Port == 0 {
 			mon.Prometheus.Port = PrometheusExporterPortNumber
 		}
+		if mon.Prometheus.MonitorKind == "" {
+			mon.Prometheus.MonitorKind = MonitorKindServiceMonitor
+		}
 		if in.Spec.Annotations == nil {
 			in.Spec.Annotations = make(map[string]string)
 		}
@@ -78,6 +90,59 @@ func DefaultOwnerReferences(cluster *DistributedRedisCluster) []metav1.OwnerRefe
 	}
 }
 
+// ArePodsExposed returns true if every redis pod is exposed by a service of spec.expose.podServiceType.
+func (in *DistributedRedisCluster) ArePodsExposed() bool {
+	return in.Spec.Expose != nil && in.Spec.Expose.PodServiceType != ""
+}
+
+// IsTLSEnabled returns true if the redis nodes of the cluster serve TLS only.
+func (in *DistributedRedisCluster) IsTLSEnabled() bool {
+	return in.Spec.TLS != nil
+}
+
+// TLSSecretName returns the name of the Secret holding the certificate of the redis nodes.
+func (in *DistributedRedisCluster) TLSSecretName() string {
+	if in.Spec.TLS == nil {
+		return ""
+	}
+	if in.Spec.TLS.SecretName != "" {
+		return in.Spec.TLS.SecretName
+	}
+	return fmt.Sprintf("%s-tls", in.Name)
+}
+
+// IsACLEnabled returns true if the ACL users of the redis nodes are managed by the operator.
+func (in *DistributedRedisCluster) IsACLEnabled() bool {
+	return len(in.Spec.Users) > 0
+}
+
+// AppliedPasswordSecretName returns the name of the Secret recording the password last applied to the redis nodes,
+// the password is rotated when it differs from the one of spec.rootPasswordSecret.
+func (in *DistributedRedisCluster) AppliedPasswordSecretName() string {
+	return fmt.Sprintf("%s-password-applied", in.Name)
+}
+
+// IsReservedUserName returns true if the ACL user can't be declared in spec.users, the default user and the
+// user of the operator are managed by the operator.
+func IsReservedUserName(name string) bool {
+	return name == "default" || name == ACLOperatorUser
+}
+
+// ACLOperatorSecretName returns the name of the Secret holding the password of the ACL user of the operator.
+func (in *DistributedRedisCluster) ACLOperatorSecretName() string {
+	return fmt.Sprintf("%s-acl-operator", in.Name)
+}
+
+// Default sets the defaults of the spec, it is applied by the mutating webhook at admission time.
+func (in *RedisClusterBackup) Default() {
+	if in.Spec.Source == "" {
+		in.Spec.Source = BackupSourceMaster
+	}
+	if in.Spec.Consistency == "" {
+		in.Spec.Consistency = BackupConsistencyNone
+	}
+}
+
 func (in *RedisClusterBackup) Validate() error {
 	clusterName := in.Spec.RedisClusterName
 	if clusterName == "" {
@@ -88,6 +153,16 @@ func (in *RedisClusterBackup) Validate() error {
 		return fmt.Errorf("no storage provider is configured")
 	}
 
+	switch in.Spec.Source {
+	case "", BackupSourceMaster, BackupSourceReplica, BackupSourceAuto:
+	default:
+		return fmt.Errorf("bakcup [Source] %s is invalid", in.Spec.Source)
+	}
+
+	if in.Spec.Consistency != "" && in.Spec.Consistency != BackupConsistencyNone && in.Spec.Consistency != BackupConsistencyCoordinated {
+		return fmt.Errorf("bakcup [Consistency] %s is invalid", in.Spec.Consistency)
+	}
+
 	if in.Spec.Azure != nil || in.Spec.Swift != nil {
 		if in.Spec.StorageSecretName == "" {
 			return fmt.Errorf("bakcup [SecretName] is missing")
@@ -120,3 +195,92 @@ func (in *RedisClusterBackup) OSMSecretName() string {
 func (in *RedisClusterBackup) JobName() string {
 	return fmt.Sprintf("redisbackup-%v", in.Name)
 }
+
+func (in *RedisClusterRestore) Validate() error {
+	if in.Spec.RedisClusterName == "" {
+		return fmt.Errorf("restore [RedisClusterName] is missing")
+	}
+	if in.Spec.BackupSource.Name == "" {
+		return fmt.Errorf("restore [BackupSource.Name] is missing")
+	}
+	return nil
+}
+
+func (in *RedisClusterRestore) OSMSecretName() string {
+	return fmt.Sprintf("osmconfig-restore-%v", in.Name)
+}
+
+func (in *RedisClusterRestore) JobName() string {
+	return fmt.Sprintf("redisrestore-%v", in.Name)
+}
+
+// BackupNamespace returns the namespace of the backup to restore, defaults to the namespace of the restore.
+func (in *RedisClusterRestore) BackupNamespace() string {
+	if in.Spec.BackupSource.Namespace != "" {
+		return in.Spec.BackupSource.Namespace
+	}
+	return in.Namespace
+}
+
+// Shard returns the status of the snapshot of the given index, nil if the backup has no such snapshot.
+func (in *RedisClusterBackup) Shard(index int32) *ShardBackupStatus {
+	for i := range in.Status.Shards {
+		if in.Status.Shards[i].Index == index {
+			return &in.Status.Shards[i]
+		}
+	}
+	return nil
+}
+
+// ShardJobName returns the name of the job uploading the snapshot of a shard in Coordinated consistency mode.
+func (in *RedisClusterBackup) ShardJobName(index int) string {
+	return fmt.Sprintf("%s-%d", in.JobName(), index)
+}
+
+func (in *RedisClusterBackup) IsCoordinated() bool {
+	return in.Spec.Consistency == BackupConsistencyCoordinated
+}
+
+// GetCondition returns the condition of the given type, nil if it is not set.
+func (in *DistributedRedisClusterStatus) GetCondition(conditionType ClusterConditionType) *ClusterCondition {
+	for i := range in.Conditions {
+		if in.Conditions[i].Type == conditionType {
+			return &in.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of its type, LastTransitionTime is only changed when the
+// status of the condition changes.
+func (in *DistributedRedisClusterStatus) SetCondition(condition ClusterCondition) {
+	current := in.GetCondition(condition.Type)
+	if current == nil {
+		condition.LastTransitionTime = metav1.Now()
+		in.Conditions = append(in.Conditions, condition)
+		return
+	}
+	if current.Status != condition.Status {
+		condition.LastTransitionTime = metav1.Now()
+	} else {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+	*current = condition
+}
+
+// RemoveCondition removes the condition of the given type.
+func (in *DistributedRedisClusterStatus) RemoveCondition(conditionType ClusterConditionType) {
+	var conditions []ClusterCondition
+	for _, c := range in.Conditions {
+		if c.Type != conditionType {
+			conditions = append(conditions, c)
+		}
+	}
+	in.Conditions = conditions
+}
+
+// IsConditionTrue returns true when the condition of the given type is set and True.
+func (in *DistributedRedisClusterStatus) IsConditionTrue(conditionType ClusterConditionType) bool {
+	c := in.GetCondition(conditionType)
+	return c != nil && c.Status == v1.ConditionTrue
+}
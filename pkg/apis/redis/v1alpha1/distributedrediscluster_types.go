@@ -31,6 +31,78 @@ type DistributedRedisClusterSpec struct {
 	PasswordSecret  *corev1.LocalObjectReference `json:"rootPasswordSecret,omitempty"`
 	Monitor         *AgentSpec                   `json:"monitor,omitempty"`
 	Init            *InitSpec                    `json:"init,omitempty"`
+	Expose          *ExposeSpec                  `json:"expose,omitempty"`
+	// TLS encrypts the client, replication and cluster bus traffic of the redis nodes, it requires redis 6.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+	// Users are the redis 6 ACL users applied to every node. The operator then connects with its own
+	// least-privilege user, and deletes the users which are not declared but the default one.
+	// +optional
+	Users []RedisUser `json:"users,omitempty"`
+	// TopologyKey is the label of the kubernetes nodes the redis nodes are spread by, e.g.
+	// topology.kubernetes.io/zone, so that the masters are in distinct domains and every replica is in
+	// another domain than its master. The nodes are spread by kubernetes node when it is empty.
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// Rebalance tunes how the slots are balanced between the masters and how their keys are moved.
+	// +optional
+	Rebalance *RebalanceSpec `json:"rebalance,omitempty"`
+	// DryRun makes the operator publish the changes it would make to the kubernetes resources and the redis
+	// nodes in status.plan instead of making them, e.g. to review a reshard before approving it by setting DryRun back to false.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RedisUser is a redis 6 ACL user, see https://redis.io/topics/acl.
+type RedisUser struct {
+	Name string `json:"name"`
+	// PasswordSecret holds the password of the user in its "password" key.
+	PasswordSecret corev1.LocalObjectReference `json:"passwordSecret"`
+	// Commands are the command rules of the user, e.g. "+@read", "-@dangerous" or "+get".
+	// The user can't run any command by default.
+	// +optional
+	Commands []string `json:"commands,omitempty"`
+	// Keys are the patterns of the keys the user can access, e.g. "cache:*".
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// TLSSpec defines the certificate of the redis nodes.
+type TLSSpec struct {
+	// SecretName is the name of the Secret holding the tls.crt, tls.key and ca.crt of the redis nodes.
+	// When issuerRef is set, the certificate is requested from cert-manager and stored in this Secret,
+	// which defaults to <cluster name>-tls.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// IssuerRef is the cert-manager Issuer or ClusterIssuer the certificate is requested from.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// IssuerReference references a cert-manager issuer.
+type IssuerReference struct {
+	Name string `json:"name"`
+	// Kind is Issuer or ClusterIssuer, defaults to Issuer.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Group defaults to cert-manager.io.
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// ExposeSpec defines how the redis cluster is reachable by its clients.
+type ExposeSpec struct {
+	// ClusterIP creates a ClusterIP service in front of all the redis pods.
+	// +optional
+	ClusterIP bool `json:"clusterIP,omitempty"`
+	// PodServiceType is the type of the service created for every redis pod, NodePort or LoadBalancer.
+	// Redis announces the address of these services, so the MOVED redirects point at addresses
+	// reachable from outside Kubernetes. No service is created for the pods if empty.
+	// +optional
+	PodServiceType corev1.ServiceType `json:"podServiceType,omitempty"`
+	// Annotations added to the services, e.g. to configure the load balancers.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type AgentSpec struct {
@@ -76,9 +148,58 @@ type PrometheusSpec struct {
 
 	// Interval at which metrics should be scraped
 	Interval string `json:"interval,omitempty"`
+	// MonitorKind is the kind of the prometheus-operator resource created to scrape the exporter,
+	// ServiceMonitor or PodMonitor. Defaults to ServiceMonitor.
+	// +optional
+	MonitorKind MonitorKind `json:"monitorKind,omitempty"`
 	//Annotations map[string]string `json:"annotations,omitempty"`
 }
 
+// RebalanceSpec tunes how the slots are balanced between the masters and how their keys are moved.
+type RebalanceSpec struct {
+	// Strategy balances the number of slots of the masters (Slots, the default) or their number of keys (Keys),
+	// counted with CLUSTER COUNTKEYSINSLOT.
+	// +optional
+	Strategy RebalanceStrategy `json:"strategy,omitempty"`
+	// Weights are the weights of the shards by index, 1 by default. A shard of weight 2 gets twice as many
+	// slots or keys as a shard of weight 1.
+	// +optional
+	Weights map[string]int32 `json:"weights,omitempty"`
+	// Threshold is the imbalance in percent of the share of a master below which the slots are not moved.
+	// +optional
+	Threshold int32 `json:"threshold,omitempty"`
+	// BatchSize is the number of keys moved by each MIGRATE, 10 by default.
+	// +optional
+	BatchSize int32 `json:"batchSize,omitempty"`
+	// MigrateTimeout is the timeout of each MIGRATE in milliseconds, 30000 by default.
+	// +optional
+	MigrateTimeout int32 `json:"migrateTimeout,omitempty"`
+	// KeysPerSecond limits the rate the keys are moved at, unlimited if 0.
+	// +optional
+	KeysPerSecond int64 `json:"keysPerSecond,omitempty"`
+	// BytesPerSecond limits the rate the keys are moved at, measured with MEMORY USAGE, unlimited if unset.
+	// +optional
+	BytesPerSecond *resource.Quantity `json:"bytesPerSecond,omitempty"`
+}
+
+type RebalanceStrategy string
+
+const (
+	// RebalanceStrategySlots balances the number of slots of the masters
+	RebalanceStrategySlots RebalanceStrategy = "Slots"
+	// RebalanceStrategyKeys balances the number of keys of the masters
+	RebalanceStrategyKeys RebalanceStrategy = "Keys"
+)
+
+type MonitorKind string
+
+const (
+	// MonitorKindServiceMonitor scrapes the exporter through the endpoints of the metrics service
+	MonitorKindServiceMonitor MonitorKind = "ServiceMonitor"
+	// MonitorKindPodMonitor scrapes the exporter of the pods directly
+	MonitorKindPodMonitor MonitorKind = "PodMonitor"
+)
+
 type InitSpec struct {
 	BackupSource *BackupSourceSpec `json:"backupSource,omitempty"`
 }
@@ -95,7 +216,7 @@ type RedisStorage struct {
 	Size        resource.Quantity `json:"size"`
 	Type        StorageType       `json:"type"`
 	Class       string            `json:"class"`
-	DeleteClaim bool              `json:"deleteClaim",omitempty"`
+	DeleteClaim bool              `json:"deleteClaim,omitempty"`
 }
 
 // DistributedRedisClusterStatus defines the observed state of DistributedRedisCluster
@@ -113,6 +234,62 @@ type DistributedRedisClusterStatus struct {
 	// The number of restore which reached phase Succeeded.
 	// +optional
 	RestoreSucceeded int32 `json:"restoreSucceeded,omitempty"`
+	// ObservedGeneration is the most recent generation of the spec observed by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the latest available observations of the state of the cluster.
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []ClusterCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// VolumeExpansion reports the progress of the expansion of the data volumes to spec.storage.size.
+	// +optional
+	VolumeExpansion *VolumeExpansionStatus `json:"volumeExpansion,omitempty"`
+	// NodesPlacement is Optimal when the masters are in distinct topology domains and no replica is in
+	// the domain of its master, BestEffort otherwise.
+	// +optional
+	NodesPlacement NodesPlacementInfo `json:"nodesPlacement,omitempty"`
+	// Plan lists the changes the operator would make to the kubernetes resources and the redis nodes, computed
+	// when spec.dryRun is true.
+	// +optional
+	Plan *ClusterPlan `json:"plan,omitempty"`
+}
+
+// ClusterPlan is the list of changes computed in dry-run mode.
+type ClusterPlan struct {
+	// ObservedGeneration is the generation of the spec the plan was computed for.
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// Steps are the changes in the order they would be made.
+	// +optional
+	Steps []string `json:"steps,omitempty"`
+}
+
+// VolumeExpansionStatus reports the progress of the expansion of the data volumes of the cluster.
+type VolumeExpansionStatus struct {
+	// Size is the size the volumes are expanded to.
+	Size resource.Quantity `json:"size"`
+	// Expanded is the number of volumes whose filesystem has been resized.
+	Expanded int32 `json:"expanded"`
+	// Total is the number of data volumes of the cluster.
+	Total int32 `json:"total"`
+	// PendingRestart are the pods to restart for the filesystem of their volume to be resized.
+	// +optional
+	PendingRestart []string `json:"pendingRestart,omitempty"`
+}
+
+// ClusterCondition describes the state of a DistributedRedisCluster at a certain point.
+type ClusterCondition struct {
+	// Type of the condition.
+	Type ClusterConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason is a CamelCase reason for the condition's last transition.
+	Reason string `json:"reason"`
+	// Message is a human readable message indicating details about the transition.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // RedisClusterNode represent a RedisCluster Node
@@ -125,6 +302,10 @@ type RedisClusterNode struct {
 	MasterRef string    `json:"masterRef,omitempty"`
 	PodName   string    `json:"podName"`
 	NodeName  string    `json:"nodeName"`
+	// StatefulSet is the name of the statefulSet of the shard the pod belongs to.
+	StatefulSet string `json:"statefulSet,omitempty"`
+	// Zone is the value of the spec.topologyKey label of the kubernetes node of the pod.
+	Zone string `json:"zone,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -21,6 +21,16 @@ type RedisClusterBackupSpec struct {
 	Storage          *RedisStorage `json:"storage,omitempty"`
 	store.Backend    `json:",inline"`
 	PodSpec          PodSpec `json:"podSpec,omitempty"`
+	// Source defines which node of each shard is dumped, defaults to Master.
+	// +optional
+	Source BackupSource `json:"source,omitempty"`
+	// Consistency defines how the snapshots of the shards are taken, defaults to None.
+	// +optional
+	Consistency BackupConsistency `json:"consistency,omitempty"`
+	// RetainOnDelete keeps the backup data in the object store, and the backup PVC, when the
+	// RedisClusterBackup is deleted.
+	// +optional
+	RetainOnDelete bool `json:"retainOnDelete,omitempty"`
 }
 
 type PodSpec struct {
@@ -134,6 +144,31 @@ type PodSpec struct {
 	Lifecycle *corev1.Lifecycle `json:"lifecycle,omitempty"`
 }
 
+// BackupSource is the node of a shard a backup is taken from
+type BackupSource string
+
+const (
+	// BackupSourceMaster the master of each shard is dumped
+	BackupSourceMaster BackupSource = "master"
+	// BackupSourceReplica the most caught-up replica of each shard is dumped, the backup fails when
+	// a shard has no healthy replica
+	BackupSourceReplica BackupSource = "replica"
+	// BackupSourceAuto the most caught-up replica of each shard is dumped, the master is dumped when
+	// the shard has no healthy replica
+	BackupSourceAuto BackupSource = "auto"
+)
+
+// BackupConsistency is the consistency mode of the snapshots of a backup
+type BackupConsistency string
+
+const (
+	// BackupConsistencyNone each shard is dumped independently when its backup container starts
+	BackupConsistencyNone BackupConsistency = "None"
+	// BackupConsistencyCoordinated a BGSAVE is triggered on every master at nearly the same instant,
+	// the backup jobs then upload the RDB files produced. It requires a persistent storage.
+	BackupConsistencyCoordinated BackupConsistency = "Coordinated"
+)
+
 type BackupPhase string
 
 const (
@@ -158,6 +193,36 @@ type RedisClusterBackupStatus struct {
 	MasterSize      int32        `json:"masterSize,omitempty"`
 	ClusterReplicas int32        `json:"clusterReplicas,omitempty"`
 	ClusterImage    string       `json:"clusterImage,omitempty"`
+	// SaveStartTime is the time the BGSAVE of the shards was started, set in Coordinated consistency mode
+	SaveStartTime *metav1.Time `json:"saveStartTime,omitempty"`
+	// Shards is the snapshot of each shard of the cluster
+	Shards []ShardBackupStatus `json:"shards,omitempty"`
+}
+
+// ShardBackupStatus defines the snapshot of a shard
+type ShardBackupStatus struct {
+	// Index of the snapshot, the snapshot is stored under <backup name>-<index>
+	Index int32 `json:"index"`
+	// NodeID is the redis node id of the node dumped
+	NodeID string `json:"nodeID"`
+	// Role is the role of the node dumped
+	Role RedisRole `json:"role,omitempty"`
+	// PodName is the pod of the node dumped
+	PodName string `json:"podName"`
+	// Slots are the slots owned by the shard
+	Slots []string `json:"slots,omitempty"`
+	// PreviousSaveTime is the LASTSAVE of the node when its BGSAVE was started, set in Coordinated consistency mode
+	PreviousSaveTime *metav1.Time `json:"previousSaveTime,omitempty"`
+	// SaveTime is the time of the last BGSAVE of the node, set in Coordinated consistency mode
+	SaveTime *metav1.Time `json:"saveTime,omitempty"`
+	// ObjectKey is the key of the RDB file of the shard in the bucket
+	ObjectKey string `json:"objectKey,omitempty"`
+	// Size is the size in bytes of the RDB file
+	Size int64 `json:"size,omitempty"`
+	// SHA256 is the hex encoded SHA-256 checksum of the RDB file, verified before the file is restored
+	SHA256 string `json:"sha256,omitempty"`
+	// Keys is the number of keys in the RDB file
+	Keys int64 `json:"keys,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
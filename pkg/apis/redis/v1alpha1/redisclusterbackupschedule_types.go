@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisClusterBackupScheduleSpec defines the desired state of RedisClusterBackupSchedule
+// +k8s:openapi-gen=true
+type RedisClusterBackupScheduleSpec struct {
+	// Schedule in Cron format, see https://en.wikipedia.org/wiki/Cron.
+	Schedule string `json:"schedule"`
+	// Suspend stops the creation of new backups, the retention policy is still applied.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// BackupTemplate is the spec of the RedisClusterBackup created at each schedule.
+	BackupTemplate RedisClusterBackupSpec `json:"backupTemplate"`
+	// Retention defines which backups created by the schedule are kept, all the backups are
+	// kept when it is empty.
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention defines the backups to keep, a backup is kept if any of the rules keeps it.
+type BackupRetention struct {
+	// KeepLast keeps the N last backups.
+	// +optional
+	KeepLast int32 `json:"keepLast,omitempty"`
+	// KeepDaily keeps the last backup of each of the N last days having a backup.
+	// +optional
+	KeepDaily int32 `json:"keepDaily,omitempty"`
+	// KeepWeekly keeps the last backup of each of the N last weeks having a backup.
+	// +optional
+	KeepWeekly int32 `json:"keepWeekly,omitempty"`
+}
+
+// RedisClusterBackupScheduleStatus defines the observed state of RedisClusterBackupSchedule
+// +k8s:openapi-gen=true
+type RedisClusterBackupScheduleStatus struct {
+	// LastScheduleTime is the last time a backup was created.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastBackup is the name of the last backup created.
+	LastBackup string `json:"lastBackup,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterBackupSchedule is the Schema for the redisclusterbackupschedules API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=redisclusterbackupschedules,scope=Namespaced
+type RedisClusterBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterBackupScheduleSpec   `json:"spec,omitempty"`
+	Status RedisClusterBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterBackupScheduleList contains a list of RedisClusterBackupSchedule
+type RedisClusterBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisClusterBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClusterBackupSchedule{}, &RedisClusterBackupScheduleList{})
+}
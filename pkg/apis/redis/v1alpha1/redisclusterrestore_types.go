@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisClusterRestoreSpec defines the desired state of RedisClusterRestore
+// +k8s:openapi-gen=true
+type RedisClusterRestoreSpec struct {
+	// Image of the restore job, defaults to the image of the backup.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// RedisClusterName is the name of the running DistributedRedisCluster to restore into, in the
+	// namespace of the restore.
+	RedisClusterName string `json:"redisClusterName"`
+	// BackupSource is the backup to restore, the number of masters of the backup may differ from the
+	// number of masters of the cluster.
+	BackupSource BackupSourceSpec `json:"backupSource"`
+	// FlushAll deletes all the keys of the cluster before the restore.
+	// +optional
+	FlushAll bool `json:"flushAll,omitempty"`
+	// +optional
+	PodSpec PodSpec `json:"podSpec,omitempty"`
+}
+
+type RestorePhase string
+
+const (
+	// used for Restore that are currently running
+	RestorePhaseRunning RestorePhase = "Running"
+	// used for Restore that are Succeeded
+	RestorePhaseSucceeded RestorePhase = "Succeeded"
+	// used for Restore that are Failed
+	RestorePhaseFailed RestorePhase = "Failed"
+)
+
+// RedisClusterRestoreStatus defines the observed state of RedisClusterRestore
+// +k8s:openapi-gen=true
+type RedisClusterRestoreStatus struct {
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Phase          RestorePhase `json:"phase,omitempty"`
+	Reason         string       `json:"reason,omitempty"`
+	// FlushTime is the time the keys of the cluster were flushed for spec.flushAll, the flush is not
+	// run again once the restore job may have replayed keys.
+	FlushTime *metav1.Time `json:"flushTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterRestore is the Schema for the redisclusterrestores API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=redisclusterrestores,scope=Namespaced
+type RedisClusterRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterRestoreSpec   `json:"spec,omitempty"`
+	Status RedisClusterRestoreStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterRestoreList contains a list of RedisClusterRestore
+type RedisClusterRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisClusterRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClusterRestore{}, &RedisClusterRestoreList{})
+}
@@ -0,0 +1,232 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The DistributedRedisCluster and the RedisClusterBackup implement the Defaulter and Validator interfaces of
+// controller-runtime, so that the defaults are applied and the invalid specs are rejected at admission time
+// when the webhook server of the operator is enabled.
+
+// ValidateCreate implements webhook.Validator.
+func (in *DistributedRedisCluster) ValidateCreate() error {
+	return in.validateSpec()
+}
+
+// ValidateUpdate implements webhook.Validator, the storage of the redis nodes can't be changed but grown,
+// and TLS can't be turned on or off since the nodes of a cluster must all speak the same protocol.
+func (in *DistributedRedisCluster) ValidateUpdate(old runtime.Object) error {
+	if err := in.validateSpec(); err != nil {
+		return err
+	}
+	oldCluster, ok := old.(*DistributedRedisCluster)
+	if !ok {
+		return fmt.Errorf("expect old object to be a %s", DistributedRedisClusterKind)
+	}
+	if oldCluster.IsTLSEnabled() != in.IsTLSEnabled() {
+		return fmt.Errorf("spec.tls can't be added or removed")
+	}
+	return validateStorageUpdate(oldCluster.Spec.Storage, in.Spec.Storage)
+}
+
+// ValidateDelete implements webhook.Validator.
+func (in *DistributedRedisCluster) ValidateDelete() error {
+	return nil
+}
+
+func (in *DistributedRedisCluster) validateSpec() error {
+	if in.Spec.MasterSize < minMasterSize {
+		return fmt.Errorf("spec.masterSize must be at least %d", minMasterSize)
+	}
+	if in.Spec.ClusterReplicas < 0 {
+		return fmt.Errorf("spec.clusterReplicas can't be negative")
+	}
+	if in.Spec.Init != nil {
+		if in.Spec.Init.BackupSource == nil {
+			return fmt.Errorf("spec.init.backupSource is required")
+		}
+		if in.Spec.Init.BackupSource.Name == "" {
+			return fmt.Errorf("spec.init.backupSource.name is required")
+		}
+	}
+	if err := validateStorage(in.Spec.Storage); err != nil {
+		return err
+	}
+	if in.Spec.Expose != nil {
+		switch in.Spec.Expose.PodServiceType {
+		case "", v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+		default:
+			return fmt.Errorf("spec.expose.podServiceType %s is invalid", in.Spec.Expose.PodServiceType)
+		}
+	}
+	if mon := in.Spec.Monitor; mon != nil && mon.Prometheus != nil {
+		switch mon.Prometheus.MonitorKind {
+		case "", MonitorKindServiceMonitor, MonitorKindPodMonitor:
+		default:
+			return fmt.Errorf("spec.monitor.prometheus.monitorKind %s is invalid", mon.Prometheus.MonitorKind)
+		}
+	}
+	if err := in.Spec.TLS.validate(); err != nil {
+		return err
+	}
+	if err := in.Spec.Rebalance.validate(); err != nil {
+		return err
+	}
+	if key := in.Spec.TopologyKey; key != "" {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("spec.topologyKey %s is invalid: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	return validateUsers(in.Spec.Users)
+}
+
+// validateUsers rejects the users which can't be applied with ACL SETUSER, or which would replace the default
+// user or the user of the operator.
+func validateUsers(users []RedisUser) error {
+	names := map[string]bool{}
+	for i, user := range users {
+		switch {
+		case user.Name == "":
+			return fmt.Errorf("spec.users[%d].name is required", i)
+		case strings.ContainsAny(user.Name, " \t\n"):
+			return fmt.Errorf("spec.users[%d].name %q can't contain spaces", i, user.Name)
+		case IsReservedUserName(user.Name):
+			return fmt.Errorf("spec.users[%d].name %s is reserved", i, user.Name)
+		case names[user.Name]:
+			return fmt.Errorf("spec.users[%d].name %s is duplicated", i, user.Name)
+		case user.PasswordSecret.Name == "":
+			return fmt.Errorf("spec.users[%d].passwordSecret.name is required", i)
+		}
+		names[user.Name] = true
+		for _, rule := range append(append([]string{}, user.Commands...), user.Keys...) {
+			if rule == "" || strings.ContainsAny(rule, " \t\n") {
+				return fmt.Errorf("spec.users[%d] has an invalid rule %q", i, rule)
+			}
+		}
+	}
+	return nil
+}
+
+func (in *RebalanceSpec) validate() error {
+	if in == nil {
+		return nil
+	}
+	switch in.Strategy {
+	case "", RebalanceStrategySlots, RebalanceStrategyKeys:
+	default:
+		return fmt.Errorf("spec.rebalance.strategy %s is invalid", in.Strategy)
+	}
+	for shard, weight := range in.Weights {
+		if _, err := strconv.Atoi(shard); err != nil {
+			return fmt.Errorf("spec.rebalance.weights key %s is not a shard index", shard)
+		}
+		if weight < 1 {
+			return fmt.Errorf("spec.rebalance.weights of shard %s must be positive", shard)
+		}
+	}
+	if in.Threshold < 0 || in.Threshold > 100 {
+		return fmt.Errorf("spec.rebalance.threshold must be between 0 and 100")
+	}
+	if in.BatchSize < 0 || in.MigrateTimeout < 0 || in.KeysPerSecond < 0 {
+		return fmt.Errorf("spec.rebalance batchSize, migrateTimeout and keysPerSecond can't be negative")
+	}
+	if in.BytesPerSecond != nil && in.BytesPerSecond.Sign() < 0 {
+		return fmt.Errorf("spec.rebalance.bytesPerSecond can't be negative")
+	}
+	return nil
+}
+
+func (in *TLSSpec) validate() error {
+	if in == nil {
+		return nil
+	}
+	if in.IssuerRef == nil {
+		if in.SecretName == "" {
+			return fmt.Errorf("spec.tls.secretName or spec.tls.issuerRef is required")
+		}
+		return nil
+	}
+	if in.IssuerRef.Name == "" {
+		return fmt.Errorf("spec.tls.issuerRef.name is required")
+	}
+	switch in.IssuerRef.Kind {
+	case "", "Issuer", "ClusterIssuer":
+	default:
+		return fmt.Errorf("spec.tls.issuerRef.kind %s is invalid", in.IssuerRef.Kind)
+	}
+	return nil
+}
+
+// ValidateCreate implements webhook.Validator.
+func (in *RedisClusterBackup) ValidateCreate() error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	return validateStorage(in.Spec.Storage)
+}
+
+// ValidateUpdate implements webhook.Validator, the spec of a backup can't be changed once it has started.
+func (in *RedisClusterBackup) ValidateUpdate(old runtime.Object) error {
+	if err := in.ValidateCreate(); err != nil {
+		return err
+	}
+	oldBackup, ok := old.(*RedisClusterBackup)
+	if !ok {
+		return fmt.Errorf("expect old object to be a %s", RedisClusterBackupKind)
+	}
+	// the backups created before the webhook was enabled are stored without the defaults
+	oldBackup = oldBackup.DeepCopy()
+	oldBackup.Default()
+	if oldBackup.Status.Phase != "" && !reflect.DeepEqual(oldBackup.Spec, in.Spec) {
+		return fmt.Errorf("spec of backup %s is immutable once it is %s", in.Name, oldBackup.Status.Phase)
+	}
+	return validateStorageUpdate(oldBackup.Spec.Storage, in.Spec.Storage)
+}
+
+// ValidateDelete implements webhook.Validator.
+func (in *RedisClusterBackup) ValidateDelete() error {
+	return nil
+}
+
+func validateStorage(storage *RedisStorage) error {
+	if storage == nil {
+		return nil
+	}
+	switch storage.Type {
+	case PersistentClaim:
+		if storage.Size.IsZero() {
+			return fmt.Errorf("storage.size is required for %s storage", PersistentClaim)
+		}
+	case Ephemeral:
+	default:
+		return fmt.Errorf("storage.type %s is invalid", storage.Type)
+	}
+	return nil
+}
+
+// validateStorageUpdate rejects the changes of the storage which can't be applied to the existing volumes.
+func validateStorageUpdate(old, new *RedisStorage) error {
+	if old == nil && new == nil {
+		return nil
+	}
+	if old == nil || new == nil {
+		return fmt.Errorf("storage can't be added or removed after creation")
+	}
+	if old.Type != new.Type {
+		return fmt.Errorf("storage.type can't be changed from %s to %s", old.Type, new.Type)
+	}
+	if old.Class != new.Class {
+		return fmt.Errorf("storage.class can't be changed from %s to %s", old.Class, new.Class)
+	}
+	if new.Size.Cmp(old.Size) < 0 {
+		return fmt.Errorf("storage.size can't be shrunk from %s to %s", old.Size.String(), new.Size.String())
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	store "kmodules.xyz/objectstore-api/api/v1"
+)
+
+func Test_validateStorageUpdate(t *testing.T) {
+	storage := func(storageType StorageType, class, size string) *RedisStorage {
+		return &RedisStorage{Type: storageType, Class: class, Size: resource.MustParse(size)}
+	}
+	tests := []struct {
+		name    string
+		old     *RedisStorage
+		new     *RedisStorage
+		wantErr bool
+	}{
+		{
+			name: "no storage",
+		},
+		{
+			name: "unchanged",
+			old:  storage(PersistentClaim, "standard", "10Gi"),
+			new:  storage(PersistentClaim, "standard", "10Gi"),
+		},
+		{
+			name: "size grown",
+			old:  storage(PersistentClaim, "standard", "10Gi"),
+			new:  storage(PersistentClaim, "standard", "20Gi"),
+		},
+		{
+			name: "same size in another unit",
+			old:  storage(PersistentClaim, "standard", "1Gi"),
+			new:  storage(PersistentClaim, "standard", "1024Mi"),
+		},
+		{
+			name:    "size shrunk",
+			old:     storage(PersistentClaim, "standard", "20Gi"),
+			new:     storage(PersistentClaim, "standard", "10Gi"),
+			wantErr: true,
+		},
+		{
+			name:    "type changed",
+			old:     storage(Ephemeral, "", "0"),
+			new:     storage(PersistentClaim, "", "10Gi"),
+			wantErr: true,
+		},
+		{
+			name:    "class changed",
+			old:     storage(PersistentClaim, "standard", "10Gi"),
+			new:     storage(PersistentClaim, "fast", "10Gi"),
+			wantErr: true,
+		},
+		{
+			name:    "storage added",
+			new:     storage(PersistentClaim, "standard", "10Gi"),
+			wantErr: true,
+		},
+		{
+			name:    "storage removed",
+			old:     storage(PersistentClaim, "standard", "10Gi"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateStorageUpdate(tt.old, tt.new); (err != nil) != tt.wantErr {
+				t.Errorf("validateStorageUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRebalanceSpec_validate(t *testing.T) {
+	negative := resource.MustParse("-1Mi")
+	bytes := resource.MustParse("10Mi")
+	tests := []struct {
+		name    string
+		spec    *RebalanceSpec
+		wantErr bool
+	}{
+		{
+			name: "no rebalance",
+		},
+		{
+			name: "defaults",
+			spec: &RebalanceSpec{},
+		},
+		{
+			name: "keys strategy with limits",
+			spec: &RebalanceSpec{
+				Strategy:       RebalanceStrategyKeys,
+				Weights:        map[string]int32{"0": 1, "1": 2},
+				Threshold:      100,
+				BatchSize:      100,
+				MigrateTimeout: 60000,
+				KeysPerSecond:  1000,
+				BytesPerSecond: &bytes,
+			},
+		},
+		{
+			name:    "unknown strategy",
+			spec:    &RebalanceSpec{Strategy: "Memory"},
+			wantErr: true,
+		},
+		{
+			name:    "weight key not a shard index",
+			spec:    &RebalanceSpec{Weights: map[string]int32{"shard-0": 1}},
+			wantErr: true,
+		},
+		{
+			name:    "zero weight",
+			spec:    &RebalanceSpec{Weights: map[string]int32{"0": 0}},
+			wantErr: true,
+		},
+		{
+			name:    "negative threshold",
+			spec:    &RebalanceSpec{Threshold: -1},
+			wantErr: true,
+		},
+		{
+			name:    "threshold above 100",
+			spec:    &RebalanceSpec{Threshold: 101},
+			wantErr: true,
+		},
+		{
+			name:    "negative batch size",
+			spec:    &RebalanceSpec{BatchSize: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative keys per second",
+			spec:    &RebalanceSpec{KeysPerSecond: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative bytes per second",
+			spec:    &RebalanceSpec{BytesPerSecond: &negative},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDistributedRedisCluster_Default(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            DistributedRedisClusterSpec
+		wantMasterSize  int32
+		wantImage       string
+		wantServiceName string
+		wantMonitorKind MonitorKind
+	}{
+		{
+			name:            "empty spec",
+			wantMasterSize:  minMasterSize,
+			wantImage:       defaultRedisImage,
+			wantServiceName: "test",
+		},
+		{
+			name: "set values are kept",
+			spec: DistributedRedisClusterSpec{
+				MasterSize:  5,
+				Image:       "redis:6.0.9",
+				ServiceName: "redis",
+				Monitor:     &AgentSpec{Prometheus: &PrometheusSpec{MonitorKind: MonitorKindPodMonitor}},
+			},
+			wantMasterSize:  5,
+			wantImage:       "redis:6.0.9",
+			wantServiceName: "redis",
+			wantMonitorKind: MonitorKindPodMonitor,
+		},
+		{
+			name:            "monitor without prometheus",
+			spec:            DistributedRedisClusterSpec{Monitor: &AgentSpec{}},
+			wantMasterSize:  minMasterSize,
+			wantImage:       defaultRedisImage,
+			wantServiceName: "test",
+			wantMonitorKind: MonitorKindServiceMonitor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &DistributedRedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: tt.spec}
+			cluster.Default()
+			if cluster.Spec.MasterSize != tt.wantMasterSize {
+				t.Errorf("Default() masterSize = %d, want %d", cluster.Spec.MasterSize, tt.wantMasterSize)
+			}
+			if cluster.Spec.Image != tt.wantImage {
+				t.Errorf("Default() image = %s, want %s", cluster.Spec.Image, tt.wantImage)
+			}
+			if cluster.Spec.ServiceName != tt.wantServiceName {
+				t.Errorf("Default() serviceName = %s, want %s", cluster.Spec.ServiceName, tt.wantServiceName)
+			}
+			if cluster.Spec.Resources == nil || cluster.Spec.Resources.Size() == 0 {
+				t.Errorf("Default() resources are not set")
+			}
+			if tt.wantMonitorKind == "" {
+				return
+			}
+			prometheus := cluster.Spec.Monitor.Prometheus
+			if prometheus == nil || prometheus.MonitorKind != tt.wantMonitorKind || prometheus.Port != PrometheusExporterPortNumber {
+				t.Errorf("Default() prometheus = %v, want monitorKind %s on port %d", prometheus, tt.wantMonitorKind, PrometheusExporterPortNumber)
+			}
+			if cluster.Spec.Annotations["prometheus.io/scrape"] != "true" {
+				t.Errorf("Default() annotations = %v, want the prometheus.io annotations", cluster.Spec.Annotations)
+			}
+			// the defaults are idempotent, they are applied by the webhook and again by the controller
+			spec := cluster.Spec.DeepCopy()
+			cluster.Default()
+			if cluster.Spec.Image != spec.Image || cluster.Spec.Monitor.Prometheus.Port != spec.Monitor.Prometheus.Port {
+				t.Errorf("Default() is not idempotent, spec = %v, want %v", cluster.Spec, spec)
+			}
+		})
+	}
+}
+
+func TestDistributedRedisCluster_ValidateCreate(t *testing.T) {
+	valid := func() *DistributedRedisCluster {
+		return &DistributedRedisCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Spec:       DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 1},
+		}
+	}
+	tests := []struct {
+		name    string
+		mutate  func(cluster *DistributedRedisCluster)
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			mutate: func(cluster *DistributedRedisCluster) {},
+		},
+		{
+			name:    "too few masters",
+			mutate:  func(cluster *DistributedRedisCluster) { cluster.Spec.MasterSize = 2 },
+			wantErr: true,
+		},
+		{
+			name:    "negative replicas",
+			mutate:  func(cluster *DistributedRedisCluster) { cluster.Spec.ClusterReplicas = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "init without backup source",
+			mutate:  func(cluster *DistributedRedisCluster) { cluster.Spec.Init = &InitSpec{} },
+			wantErr: true,
+		},
+		{
+			name: "persistent storage without size",
+			mutate: func(cluster *DistributedRedisCluster) {
+				cluster.Spec.Storage = &RedisStorage{Type: PersistentClaim}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid topology key",
+			mutate:  func(cluster *DistributedRedisCluster) { cluster.Spec.TopologyKey = "zone name" },
+			wantErr: true,
+		},
+		{
+			name: "invalid rebalance",
+			mutate: func(cluster *DistributedRedisCluster) {
+				cluster.Spec.Rebalance = &RebalanceSpec{Threshold: 200}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := valid()
+			tt.mutate(cluster)
+			if err := cluster.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedisClusterBackup_ValidateUpdate(t *testing.T) {
+	backup := func(phase BackupPhase, source BackupSource) *RedisClusterBackup {
+		return &RedisClusterBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Spec: RedisClusterBackupSpec{
+				RedisClusterName: "cluster",
+				Backend:          store.Backend{S3: &store.S3Spec{Bucket: "bucket"}},
+				Source:           source,
+			},
+			Status: RedisClusterBackupStatus{Phase: phase},
+		}
+	}
+	defaulted := func(b *RedisClusterBackup) *RedisClusterBackup {
+		b.Default()
+		return b
+	}
+	tests := []struct {
+		name    string
+		old     *RedisClusterBackup
+		new     *RedisClusterBackup
+		wantErr bool
+	}{
+		{
+			name: "spec changed before the backup started",
+			old:  backup("", BackupSourceMaster),
+			new:  defaulted(backup("", BackupSourceReplica)),
+		},
+		{
+			name:    "spec changed once the backup started",
+			old:     backup(BackupPhaseRunning, BackupSourceMaster),
+			new:     defaulted(backup(BackupPhaseRunning, BackupSourceReplica)),
+			wantErr: true,
+		},
+		{
+			name: "backup stored without the defaults",
+			old:  backup(BackupPhaseSucceeded, ""),
+			new:  defaulted(backup(BackupPhaseSucceeded, "")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.new.ValidateUpdate(tt.old); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
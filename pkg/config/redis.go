@@ -2,6 +2,7 @@ package config
 
 import (
 	"path"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -26,6 +27,10 @@ const (
 	RedisMaxMemoryDefault = 0
 	// RedisMaxMemoryPolicyDefault default redis max memory evition policy
 	RedisMaxMemoryPolicyDefault = "noeviction"
+	// PlacementFixIntervalDefault default interval the number of changes of the placement of the nodes is limited on
+	PlacementFixIntervalDefault = 5 * time.Minute
+	// MaxPlacementFixesDefault default number of changes of the placement of the nodes of a cluster per interval
+	MaxPlacementFixesDefault = 1
 )
 
 //var redisFlagSet *pflag.FlagSet
@@ -58,6 +63,10 @@ type Redis struct {
 	MaxMemory          uint32
 	MaxMemoryPolicy    string
 	ConfigFiles        []string
+	// PlacementFixInterval and MaxPlacementFixes limit the failovers and re-pairings fixing the placement of
+	// the nodes of a cluster
+	PlacementFixInterval time.Duration
+	MaxPlacementFixes    int
 }
 
 // AddFlags use to add the Redis Config flags to the command line
@@ -73,6 +82,8 @@ func (r *Redis) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&r.ServerPort, "port", RedisServerPortDefault, "redis server listen port")
 	fs.StringVar(&r.ServerIP, "ip", "", "redis server listen ip")
 	fs.StringArrayVar(&r.ConfigFiles, "config-file", []string{}, "Location of redis configuration file that will be include in the ")
+	fs.DurationVar(&r.PlacementFixInterval, "placement-fix-interval", PlacementFixIntervalDefault, "interval the changes fixing the placement of the nodes of a cluster are limited on")
+	fs.IntVar(&r.MaxPlacementFixes, "max-placement-fixes", MaxPlacementFixesDefault, "max number of changes fixing the placement of the nodes of a cluster per interval, disabled if 0")
 }
 
 // GetRenameCommandsFile return the path to the rename command file, or empty string if not define
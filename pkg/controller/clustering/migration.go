@@ -82,7 +82,7 @@ func DispatchSlotToNewMasters(cluster *redisutil.Cluster, admin redisutil.IAdmin
 			}
 
 			log.V(6).Info("3) Migrate Key")
-			nbMigrated, migerr := admin.MigrateKeys(nodesInfo.From.IPPort(), nodesInfo.To, slots, 10, 30000, true)
+			nbMigrated, migerr := admin.MigrateKeys(nodesInfo.From.IPPort(), nodesInfo.To, slots, redisutil.DefaultMigrateOptions)
 			if migerr != nil {
 				log.Error(migerr, "error during MIGRATION")
 			} else {
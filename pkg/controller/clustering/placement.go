@@ -9,8 +9,8 @@ import (
 const unknownVMName = "unknown" // <-- I hope nobody will ever name a VM "unknown" because this will impact the algorythm inside that package. Maybe you should generate a mangled name or amore complex name here to reduce probability.
 
 // PlaceMasters used to select Redis Node knowing on which VM they are running in order to spread as possible
-// the masters on different VMs.
-// Improvement: Use Kube Node labeling instead of the "NodeName", (availability zone and so)
+// the masters on different VMs. Only one master is selected by shard (statefulSet).
+// The VM of a node is its zone when the cluster has a topologyKey, see placementDomain.
 func PlaceMasters(cluster *redisutil.Cluster, currentMaster redisutil.Nodes, allPossibleMasters redisutil.Nodes, nbMaster int32) (redisutil.Nodes, bool, error) {
 	selection := redisutil.Nodes{}
 	selection = append(selection, currentMaster...)
@@ -21,6 +21,13 @@ func PlaceMasters(cluster *redisutil.Cluster, currentMaster redisutil.Nodes, all
 		selection = selection[0:nbMaster]
 	}
 
+	shardWithMaster := map[string]bool{}
+	for _, node := range selection {
+		if node.StatefulSet != "" {
+			shardWithMaster[node.StatefulSet] = true
+		}
+	}
+
 	masterByVM := sortRedisNodeByVM(cluster, allPossibleMasters)
 	vmWithAlreadyMaster := sortRedisNodeByVM(cluster, currentMaster)
 
@@ -34,11 +41,19 @@ func PlaceMasters(cluster *redisutil.Cluster, currentMaster redisutil.Nodes, all
 					continue
 				}
 			}
+			// discard the nodes of the shards which already have a master
+			nodes = nodes.FilterByFunc(func(node *redisutil.Node) bool {
+				return node.StatefulSet == "" || !shardWithMaster[node.StatefulSet]
+			})
+			masterByVM[vmName] = nodes
 			if len(nodes) == 0 {
 				continue
 			}
 			log.Info(fmt.Sprintf("- add node:%s to the master selection", nodes[0].ID))
 			selection = append(selection, nodes[0])
+			if nodes[0].StatefulSet != "" {
+				shardWithMaster[nodes[0].StatefulSet] = true
+			}
 			masterByVM[vmName] = nodes[1:]
 			isProgress = true
 			if len(selection) >= int(nbMaster) {
@@ -71,10 +86,7 @@ func sortRedisNodeByVM(cluster *redisutil.Cluster, nodes redisutil.Nodes) map[st
 			log.Error(err, fmt.Sprintf("[sortRedisNodeByVM] unable fo found the Cluster.Node with redis ID:%s", rnode.ID))
 			continue // if not then next line with cnode.Pod will cause a panic since cnode is nil
 		}
-		vmName := unknownVMName
-		if cnode.NodeName != "" {
-			vmName = cnode.NodeName
-		}
+		vmName := placementDomain(cnode)
 		if _, ok := nodesByVM[vmName]; !ok {
 			nodesByVM[vmName] = redisutil.Nodes{}
 		}
@@ -110,12 +122,9 @@ func PlaceSlaves(cluster *redisutil.Cluster, masters, oldSlaves, newSlaves redis
 	for _, slave := range oldSlaves {
 		for _, master := range masters {
 			if slave.MasterReferent == master.ID {
-				if len(slavesByMaster[slave.MasterReferent]) >= int(replicationFactor) {
-					if node, err := cluster.GetNodeByID(slave.ID); err != nil {
-						vmName := unknownVMName
-						if node.NodeName != "" {
-							vmName = node.NodeName
-						}
+				if len(slavesByMaster[slave.MasterReferent]) >= int(replicationFactor) || !isSameShard(slave, master) {
+					if node, err := cluster.GetNodeByID(slave.ID); err == nil {
+						vmName := placementDomain(node)
 						newSlavesByVM[vmName] = append(newSlavesByVM[vmName], slave)
 					}
 				} else {
@@ -127,6 +136,22 @@ func PlaceSlaves(cluster *redisutil.Cluster, masters, oldSlaves, newSlaves redis
 		}
 	}
 
+	// the slaves of a shard are attached to the master of the same shard
+	for vmName, slaves := range newSlavesByVM {
+		newSlavesByVM[vmName] = slaves.FilterByFunc(func(slave *redisutil.Node) bool {
+			if slave.StatefulSet == "" {
+				return true
+			}
+			for _, master := range masters {
+				if master.StatefulSet == slave.StatefulSet && len(slavesByMaster[master.ID]) < int(replicationFactor) {
+					slavesByMaster[master.ID] = append(slavesByMaster[master.ID], slave)
+					break
+				}
+			}
+			return false
+		})
+	}
+
 	slavesByVMNotUsed := make(map[string]redisutil.Nodes)
 	isSlaveNodeUsed := false
 
@@ -155,9 +180,7 @@ func PlaceSlaves(cluster *redisutil.Cluster, masters, oldSlaves, newSlaves redis
 						log.Error(err, fmt.Sprintf("unable to find in the cluster the slave with id: %s", currentSlave.ID))
 						continue
 					}
-					vmSlaveName := unknownVMName
-					vmSlaveName = vmSlaveNode.NodeName
-					if vmName == vmSlaveName {
+					if vmName == placementDomain(vmSlaveNode) {
 						vmAlreadyUsedForSlave = true
 						break
 					}
@@ -197,10 +220,54 @@ func PlaceSlaves(cluster *redisutil.Cluster, masters, oldSlaves, newSlaves redis
 	return slavesByMaster, bestEffort
 }
 
+// SelectSlaveToPromote returns the slave which takes over the slots of its master when the master is removed:
+// the healthy slave whose VM hosts the fewest of the given slaves, so the slaves left behind are spread on
+// the VMs the same way PlaceSlaves spreads them. It returns nil if no slave is healthy.
+func SelectSlaveToPromote(cluster *redisutil.Cluster, slaves redisutil.Nodes) *redisutil.Node {
+	slavesByVM := sortRedisNodeByVM(cluster, slaves)
+	vmName := func(node *redisutil.Node) string {
+		if cnode, err := cluster.GetNodeByID(node.ID); err == nil {
+			return placementDomain(cnode)
+		}
+		return unknownVMName
+	}
+
+	var selected *redisutil.Node
+	for _, slave := range slaves {
+		if slave.HasStatus(redisutil.NodeStatusFail) || slave.HasStatus(redisutil.NodeStatusPFail) {
+			continue
+		}
+		if selected == nil || len(slavesByVM[vmName(slave)]) < len(slavesByVM[vmName(selected)]) {
+			selected = slave
+		}
+	}
+	return selected
+}
+
+// placementDomain returns the topology domain the node is spread by: its zone when the cluster has a
+// topologyKey, else the kubernetes node it runs on.
+func placementDomain(node *redisutil.Node) string {
+	if node.Zone != "" {
+		return node.Zone
+	}
+	if node.NodeName != "" {
+		return node.NodeName
+	}
+	return unknownVMName
+}
+
+// isSameShard returns false if both nodes belong to a shard and the shards are different.
+func isSameShard(nodeA, nodeB *redisutil.Node) bool {
+	if nodeA.StatefulSet == "" || nodeB.StatefulSet == "" {
+		return true
+	}
+	return nodeA.StatefulSet == nodeB.StatefulSet
+}
+
 func checkIfSameVM(cluster *redisutil.Cluster, redisID, vmName string) bool {
 	nodeVMName := unknownVMName
 	if vmNode, err := cluster.GetNodeByID(redisID); err == nil {
-		nodeVMName = vmNode.NodeName
+		nodeVMName = placementDomain(vmNode)
 	}
 
 	if vmName == nodeVMName {
@@ -0,0 +1,192 @@
+package clustering
+
+import (
+	"testing"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func TestPlaceSlavesWithExtraSlaves(t *testing.T) {
+	masterRole := "master"
+	slaveRole := "slave"
+
+	master1 := &redisutil.Node{ID: "1", Role: masterRole, IP: "1.1.1.1", Port: "1234", Slots: redisutil.BuildSlotSlice(0, 8191), NodeName: "vm1"}
+	master2 := &redisutil.Node{ID: "2", Role: masterRole, IP: "1.1.1.2", Port: "1234", Slots: redisutil.BuildSlotSlice(8192, 16383), NodeName: "vm2"}
+	slave3 := &redisutil.Node{ID: "3", Role: slaveRole, IP: "1.1.1.3", Port: "1234", MasterReferent: "1", NodeName: "vm2"}
+	slave4 := &redisutil.Node{ID: "4", Role: slaveRole, IP: "1.1.1.4", Port: "1234", MasterReferent: "1", NodeName: "vm3"}
+
+	cluster := &redisutil.Cluster{
+		Name:      "clustertest",
+		Namespace: "default",
+		Nodes: map[string]*redisutil.Node{
+			"1": master1,
+			"2": master2,
+			"3": slave3,
+			"4": slave4,
+		},
+	}
+
+	slavesByMaster, _ := PlaceSlaves(cluster, redisutil.Nodes{master1, master2}, redisutil.Nodes{slave3, slave4}, redisutil.Nodes{}, 1)
+	if len(slavesByMaster["1"]) != 1 {
+		t.Errorf("master 1 should have 1 slave, got %d", len(slavesByMaster["1"]))
+	}
+	if len(slavesByMaster["2"]) != 1 {
+		t.Errorf("master 2 should have 1 slave, got %d", len(slavesByMaster["2"]))
+	}
+	for masterID, slaves := range slavesByMaster {
+		for _, slave := range slaves {
+			if checkIfSameVM(cluster, masterID, slave.NodeName) {
+				t.Errorf("slave %s is on the same VM as its master %s", slave.ID, masterID)
+			}
+		}
+	}
+}
+
+func TestPlaceByShard(t *testing.T) {
+	masterRole := "master"
+
+	node1 := &redisutil.Node{ID: "1", Role: masterRole, IP: "1.1.1.1", Port: "1234", NodeName: "vm1", StatefulSet: "drc-test-0"}
+	node2 := &redisutil.Node{ID: "2", Role: masterRole, IP: "1.1.1.2", Port: "1234", NodeName: "vm2", StatefulSet: "drc-test-0"}
+	node3 := &redisutil.Node{ID: "3", Role: masterRole, IP: "1.1.1.3", Port: "1234", NodeName: "vm3", StatefulSet: "drc-test-1"}
+	node4 := &redisutil.Node{ID: "4", Role: masterRole, IP: "1.1.1.4", Port: "1234", NodeName: "vm4", StatefulSet: "drc-test-1"}
+
+	cluster := &redisutil.Cluster{
+		Name:      "clustertest",
+		Namespace: "default",
+		Nodes: map[string]*redisutil.Node{
+			"1": node1,
+			"2": node2,
+			"3": node3,
+			"4": node4,
+		},
+	}
+
+	masters, _, err := PlaceMasters(cluster, redisutil.Nodes{}, redisutil.Nodes{node1, node2, node3, node4}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(masters) != 2 {
+		t.Fatalf("expected 2 masters, got %d", len(masters))
+	}
+	if masters[0].StatefulSet == masters[1].StatefulSet {
+		t.Errorf("masters %s and %s are in the same shard %s", masters[0].ID, masters[1].ID, masters[0].StatefulSet)
+	}
+
+	var slaves redisutil.Nodes
+	for _, node := range cluster.Nodes {
+		if _, err := masters.GetNodeByID(node.ID); err != nil {
+			slaves = append(slaves, node)
+		}
+	}
+	slavesByMaster, _ := PlaceSlaves(cluster, masters, redisutil.Nodes{}, slaves, 1)
+	for _, master := range masters {
+		if len(slavesByMaster[master.ID]) != 1 {
+			t.Fatalf("master %s should have 1 slave, got %d", master.ID, len(slavesByMaster[master.ID]))
+		}
+		if slave := slavesByMaster[master.ID][0]; slave.StatefulSet != master.StatefulSet {
+			t.Errorf("slave %s of shard %s is attached to master %s of shard %s", slave.ID, slave.StatefulSet, master.ID, master.StatefulSet)
+		}
+	}
+}
+
+func TestPlaceByZone(t *testing.T) {
+	masterRole := "master"
+
+	node1 := &redisutil.Node{ID: "1", Role: masterRole, IP: "1.1.1.1", Port: "1234", NodeName: "vm1", Zone: "zone-a"}
+	node2 := &redisutil.Node{ID: "2", Role: masterRole, IP: "1.1.1.2", Port: "1234", NodeName: "vm2", Zone: "zone-a"}
+	node3 := &redisutil.Node{ID: "3", Role: masterRole, IP: "1.1.1.3", Port: "1234", NodeName: "vm3", Zone: "zone-b"}
+	node4 := &redisutil.Node{ID: "4", Role: masterRole, IP: "1.1.1.4", Port: "1234", NodeName: "vm4", Zone: "zone-b"}
+
+	cluster := &redisutil.Cluster{
+		Name:      "clustertest",
+		Namespace: "default",
+		Nodes: map[string]*redisutil.Node{
+			"1": node1,
+			"2": node2,
+			"3": node3,
+			"4": node4,
+		},
+	}
+
+	masters, bestEffort, err := PlaceMasters(cluster, redisutil.Nodes{}, redisutil.Nodes{node1, node2, node3, node4}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bestEffort {
+		t.Errorf("expected an optimal placement of the masters")
+	}
+	if len(masters) != 2 {
+		t.Fatalf("expected 2 masters, got %d", len(masters))
+	}
+	if masters[0].Zone == masters[1].Zone {
+		t.Errorf("masters %s and %s are in the same zone %s", masters[0].ID, masters[1].ID, masters[0].Zone)
+	}
+
+	var slaves redisutil.Nodes
+	for _, node := range cluster.Nodes {
+		if _, err := masters.GetNodeByID(node.ID); err != nil {
+			slaves = append(slaves, node)
+		}
+	}
+	slavesByMaster, bestEffort := PlaceSlaves(cluster, masters, redisutil.Nodes{}, slaves, 1)
+	if bestEffort {
+		t.Errorf("expected an optimal placement of the slaves")
+	}
+	for _, master := range masters {
+		if len(slavesByMaster[master.ID]) != 1 {
+			t.Fatalf("master %s should have 1 slave, got %d", master.ID, len(slavesByMaster[master.ID]))
+		}
+		if slave := slavesByMaster[master.ID][0]; slave.Zone == master.Zone {
+			t.Errorf("slave %s is in the zone %s of its master %s", slave.ID, slave.Zone, master.ID)
+		}
+	}
+}
+
+func TestSelectSlaveToPromote(t *testing.T) {
+	slaveRole := "slave"
+
+	slave1 := &redisutil.Node{ID: "1", Role: slaveRole, IP: "1.1.1.1", Port: "1234", MasterReferent: "0", NodeName: "vm1"}
+	slave2 := &redisutil.Node{ID: "2", Role: slaveRole, IP: "1.1.1.2", Port: "1234", MasterReferent: "0", NodeName: "vm1"}
+	slave3 := &redisutil.Node{ID: "3", Role: slaveRole, IP: "1.1.1.3", Port: "1234", MasterReferent: "0", NodeName: "vm2"}
+	failedSlave := &redisutil.Node{ID: "4", Role: slaveRole, IP: "1.1.1.4", Port: "1234", MasterReferent: "0", NodeName: "vm3",
+		FailStatus: []string{redisutil.NodeStatusFail}}
+
+	cluster := &redisutil.Cluster{
+		Name:      "clustertest",
+		Namespace: "default",
+		Nodes: map[string]*redisutil.Node{
+			"1": slave1,
+			"2": slave2,
+			"3": slave3,
+			"4": failedSlave,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		slaves redisutil.Nodes
+		want   *redisutil.Node
+	}{
+		{
+			name:   "slave alone on its VM",
+			slaves: redisutil.Nodes{slave1, slave2, slave3},
+			want:   slave3,
+		},
+		{
+			name:   "failed slave alone on its VM",
+			slaves: redisutil.Nodes{slave1, slave2, failedSlave},
+			want:   slave1,
+		},
+		{
+			name:   "no healthy slave",
+			slaves: redisutil.Nodes{failedSlave},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectSlaveToPromote(cluster, tt.slaves); got != tt.want {
+				t.Errorf("SelectSlaveToPromote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
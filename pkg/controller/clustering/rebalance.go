@@ -3,6 +3,7 @@ package clustering
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
@@ -34,16 +35,76 @@ func AllocSlots(admin redisutil.IAdmin, newMasterNodes redisutil.Nodes) error {
 	return nil
 }
 
-// RebalancedCluster rebalanced a redis cluster.
-func RebalancedCluster(admin redisutil.IAdmin, newMasterNodes redisutil.Nodes) error {
+// RebalanceOptions tunes how the slots are balanced between the masters and how their keys are moved.
+type RebalanceOptions struct {
+	// ByKeys balances the number of keys of the masters instead of their number of slots.
+	ByKeys bool
+	// Weights are the weights of the masters by statefulSet, 1 by default.
+	Weights map[string]int32
+	// Threshold is the imbalance in percent of the share of a master below which the slots are not moved.
+	Threshold int32
+	// Migrate tunes how the keys of the slots are moved.
+	Migrate redisutil.MigrateOptions
+}
+
+// DefaultRebalanceOptions balances the number of slots of the masters evenly.
+var DefaultRebalanceOptions = RebalanceOptions{Migrate: redisutil.DefaultMigrateOptions}
+
+func (o *RebalanceOptions) weight(node *redisutil.Node) int {
+	if weight := o.Weights[node.StatefulSet]; weight > 0 {
+		return int(weight)
+	}
+	return 1
+}
+
+// withinThreshold returns true if the imbalance of every node is at most threshold percent of its share.
+func withinThreshold(imbalances, shares map[string]int64, threshold int32) bool {
+	for id, imbalance := range imbalances {
+		if imbalance < 0 {
+			imbalance = -imbalance
+		}
+		if imbalance*100 > shares[id]*int64(threshold) {
+			return false
+		}
+	}
+	return true
+}
+
+// RebalancedCluster rebalanced a redis cluster. Every slot owned by the drainedMasterNodes
+// is moved to the newMasterNodes, so they can be removed from the cluster afterwards.
+func RebalancedCluster(admin redisutil.IAdmin, newMasterNodes, drainedMasterNodes redisutil.Nodes, opts RebalanceOptions) error {
+	if opts.ByKeys {
+		return rebalanceByKeys(admin, newMasterNodes, drainedMasterNodes, opts)
+	}
+
 	nbNode := len(newMasterNodes)
+	totalWeight := 0
 	for _, node := range newMasterNodes {
-		expected := int(float64(admin.GetHashMaxSlot()+1) / float64(nbNode))
+		totalWeight += opts.weight(node)
+	}
+	imbalances := map[string]int64{}
+	shares := map[string]int64{}
+	for _, node := range newMasterNodes {
+		expected := int(float64(admin.GetHashMaxSlot()+1) * float64(opts.weight(node)) / float64(totalWeight))
 		node.SetBalance(len(node.Slots) - expected)
+		imbalances[node.ID] = int64(node.Balance())
+		shares[node.ID] = int64(expected)
+	}
+	// a drained master is expected to own no slot at all
+	for _, node := range drainedMasterNodes {
+		node.SetBalance(len(node.Slots))
 	}
+	if len(drainedMasterNodes) == 0 && withinThreshold(imbalances, shares, opts.Threshold) {
+		log.Info("the slots of the masters are balanced within the threshold", "threshold", opts.Threshold)
+		return nil
+	}
+
+	masterNodes := redisutil.Nodes{}
+	masterNodes = append(masterNodes, newMasterNodes...)
+	masterNodes = append(masterNodes, drainedMasterNodes...)
 
 	totalBalance := 0
-	for _, node := range newMasterNodes {
+	for _, node := range masterNodes {
 		totalBalance += node.Balance()
 	}
 
@@ -58,14 +119,14 @@ func RebalancedCluster(admin redisutil.IAdmin, newMasterNodes redisutil.Nodes) e
 	}
 
 	// Sort nodes by their slots balance.
-	sn := newMasterNodes.SortByFunc(func(a, b *redisutil.Node) bool { return a.Balance() < b.Balance() })
+	sn := masterNodes.SortByFunc(func(a, b *redisutil.Node) bool { return a.Balance() < b.Balance() })
 	if log.V(4).Enabled() {
 		for _, node := range sn {
 			log.Info("debug rebalanced master", "node", node.IPPort(), "balance", node.Balance())
 		}
 	}
 
-	log.Info(">>> rebalancing", "nodeNum", nbNode)
+	log.Info(">>> rebalancing", "nodeNum", nbNode, "drainedNodeNum", len(drainedMasterNodes))
 
 	dstIdx := 0
 	srcIdx := len(sn) - 1
@@ -89,9 +150,12 @@ func RebalancedCluster(admin redisutil.IAdmin, newMasterNodes redisutil.Nodes) e
 				log.Error(nil, "*** Assertion failed: Reshard table != number of slots", "table", len(reshardTable), "slots", numSlots)
 			}
 			for _, e := range reshardTable {
-				if err := moveSlot(e, dst, admin); err != nil {
+				if err := moveSlot(e, dst, admin, opts.Migrate); err != nil {
 					return err
 				}
+				// keep the slots of the nodes up to date, a source can be drained to several destinations
+				src.Slots = redisutil.RemoveSlot(src.Slots, e.Slot)
+				dst.Slots = redisutil.AddSlots(dst.Slots, []redisutil.Slot{e.Slot})
 			}
 		}
 
@@ -110,6 +174,112 @@ func RebalancedCluster(admin redisutil.IAdmin, newMasterNodes redisutil.Nodes) e
 	return nil
 }
 
+// rebalanceByKeys moves the slots of the masters with more keys than their share to the masters below their
+// share, the biggest slots first. Every slot of the drained masters is moved.
+func rebalanceByKeys(admin redisutil.IAdmin, newMasterNodes, drainedMasterNodes redisutil.Nodes, opts RebalanceOptions) error {
+	keysBySlot := map[redisutil.Slot]int64{}
+	for _, nodes := range []redisutil.Nodes{newMasterNodes, drainedMasterNodes} {
+		for _, node := range nodes {
+			for _, slot := range node.Slots {
+				count, err := admin.CountKeysInSlot(node.IPPort(), slot)
+				if err != nil {
+					return err
+				}
+				keysBySlot[slot] = count
+			}
+		}
+	}
+
+	moves := planKeyMoves(newMasterNodes, drainedMasterNodes, keysBySlot, opts)
+	log.Info(">>> rebalancing by keys", "nodeNum", len(newMasterNodes), "drainedNodeNum", len(drainedMasterNodes), "slots", len(moves))
+	for _, move := range moves {
+		if err := moveSlot(&MovedNode{Source: move.from, Slot: move.slot}, move.to, admin, opts.Migrate); err != nil {
+			return err
+		}
+		move.to.Slots = redisutil.AddSlots(move.to.Slots, []redisutil.Slot{move.slot})
+	}
+	return nil
+}
+
+// slotMove is the move of a slot from a master to another.
+type slotMove struct {
+	slot     redisutil.Slot
+	from, to *redisutil.Node
+}
+
+// planKeyMoves returns the moves of the slots balancing the number of keys of the masters, keysBySlot is the
+// number of keys of each slot. A slot is only moved when it reduces the imbalance of both masters, except the
+// slots of the drained masters which are all moved.
+func planKeyMoves(newMasterNodes, drainedMasterNodes redisutil.Nodes, keysBySlot map[redisutil.Slot]int64, opts RebalanceOptions) []slotMove {
+	keysOf := func(node *redisutil.Node) int64 {
+		var keys int64
+		for _, slot := range node.Slots {
+			keys += keysBySlot[slot]
+		}
+		return keys
+	}
+	var totalKeys int64
+	totalWeight := 0
+	for _, node := range newMasterNodes {
+		totalKeys += keysOf(node)
+		totalWeight += opts.weight(node)
+	}
+	excess := map[string]int64{}
+	shares := map[string]int64{}
+	for _, node := range drainedMasterNodes {
+		totalKeys += keysOf(node)
+		excess[node.ID] = keysOf(node)
+	}
+	for _, node := range newMasterNodes {
+		shares[node.ID] = totalKeys * int64(opts.weight(node)) / int64(totalWeight)
+		excess[node.ID] = keysOf(node) - shares[node.ID]
+	}
+	if len(drainedMasterNodes) == 0 && withinThreshold(excess, shares, opts.Threshold) {
+		log.Info("the keys of the masters are balanced within the threshold", "threshold", opts.Threshold)
+		return nil
+	}
+
+	abs := func(n int64) int64 {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	sources := redisutil.Nodes{}
+	sources = append(sources, newMasterNodes...)
+	sources = sources.SortByFunc(func(a, b *redisutil.Node) bool { return excess[a.ID] > excess[b.ID] })
+	sources = append(append(redisutil.Nodes{}, drainedMasterNodes...), sources...)
+
+	var moves []slotMove
+	for srcIdx, src := range sources {
+		drained := srcIdx < len(drainedMasterNodes)
+		slots := append([]redisutil.Slot{}, src.Slots...)
+		sort.SliceStable(slots, func(i, j int) bool { return keysBySlot[slots[i]] > keysBySlot[slots[j]] })
+		for _, slot := range slots {
+			if !drained && excess[src.ID] <= 0 {
+				break
+			}
+			var dst *redisutil.Node
+			for _, node := range newMasterNodes {
+				if node.ID != src.ID && (dst == nil || excess[node.ID] < excess[dst.ID]) {
+					dst = node
+				}
+			}
+			if dst == nil {
+				break
+			}
+			keys := keysBySlot[slot]
+			if !drained && abs(excess[src.ID]-keys)+abs(excess[dst.ID]+keys) >= abs(excess[src.ID])+abs(excess[dst.ID]) {
+				continue
+			}
+			moves = append(moves, slotMove{slot: slot, from: src, to: dst})
+			excess[src.ID] -= keys
+			excess[dst.ID] += keys
+		}
+	}
+	return moves
+}
+
 type MovedNode struct {
 	Source *redisutil.Node
 	Slot   redisutil.Slot
@@ -149,14 +319,14 @@ func computeReshardTable(src redisutil.Nodes, numSlots int) []*MovedNode {
 	return moved
 }
 
-func moveSlot(source *MovedNode, target *redisutil.Node, admin redisutil.IAdmin) error {
-	if err := admin.SetSlot(target.IPPort(), "IMPORTING", source.Slot, target.ID); err != nil {
+func moveSlot(source *MovedNode, target *redisutil.Node, admin redisutil.IAdmin, opts redisutil.MigrateOptions) error {
+	if err := admin.SetSlot(target.IPPort(), "IMPORTING", source.Slot, source.Source.ID); err != nil {
 		return err
 	}
-	if err := admin.SetSlot(source.Source.IPPort(), "MIGRATING", source.Slot, source.Source.ID); err != nil {
+	if err := admin.SetSlot(source.Source.IPPort(), "MIGRATING", source.Slot, target.ID); err != nil {
 		return err
 	}
-	if _, err := admin.MigrateKeysInSlot(source.Source.IPPort(), target, source.Slot, 10, 30000, true); err != nil {
+	if _, err := admin.MigrateKeysInSlot(source.Source.IPPort(), target, source.Slot, opts); err != nil {
 		return err
 	}
 	if err := admin.SetSlot(target.IPPort(), "NODE", source.Slot, target.ID); err != nil {
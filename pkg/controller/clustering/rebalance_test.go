@@ -38,3 +38,55 @@ func Test_computeReshardTable(t *testing.T) {
 		})
 	}
 }
+
+func Test_planKeyMoves(t *testing.T) {
+	newNode := func(id, statefulSet string, slots ...redisutil.Slot) *redisutil.Node {
+		return &redisutil.Node{ID: id, IP: "10.1.1." + id, Port: "6379", Role: "master", Slots: slots, StatefulSet: statefulSet}
+	}
+	keysBySlot := map[redisutil.Slot]int64{0: 600, 1: 300, 2: 100, 3: 0, 4: 0}
+
+	t.Run("balance keys", func(t *testing.T) {
+		node1 := newNode("1", "drc-test-0", 0, 1, 2)
+		node2 := newNode("2", "drc-test-1", 3, 4)
+		moves := planKeyMoves(redisutil.Nodes{node1, node2}, nil, keysBySlot, DefaultRebalanceOptions)
+		if len(moves) != 1 || moves[0].slot != 0 || moves[0].to != node2 {
+			t.Errorf("expected slot 0 to move to node 2, got %v", moves)
+		}
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		node1 := newNode("1", "drc-test-0", 0, 1, 2)
+		node2 := newNode("2", "drc-test-1", 3, 4)
+		opts := DefaultRebalanceOptions
+		opts.Weights = map[string]int32{"drc-test-0": 3}
+		moves := planKeyMoves(redisutil.Nodes{node1, node2}, nil, keysBySlot, opts)
+		if len(moves) != 1 || moves[0].slot != 1 {
+			t.Errorf("expected slot 1 to move to node 2, got %v", moves)
+		}
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		node1 := newNode("1", "drc-test-0", 0, 2)
+		node2 := newNode("2", "drc-test-1", 1, 3, 4)
+		opts := DefaultRebalanceOptions
+		opts.Threshold = 50
+		if moves := planKeyMoves(redisutil.Nodes{node1, node2}, nil, keysBySlot, opts); len(moves) != 0 {
+			t.Errorf("expected no move within the threshold, got %v", moves)
+		}
+	})
+
+	t.Run("drained", func(t *testing.T) {
+		node1 := newNode("1", "drc-test-0", 0)
+		node2 := newNode("2", "drc-test-1", 1)
+		drained := newNode("3", "drc-test-2", 2, 3, 4)
+		moves := planKeyMoves(redisutil.Nodes{node1, node2}, redisutil.Nodes{drained}, keysBySlot, DefaultRebalanceOptions)
+		if len(moves) != 3 {
+			t.Fatalf("expected every slot of the drained master to move, got %v", moves)
+		}
+		for _, move := range moves {
+			if move.from != drained || move.to == drained {
+				t.Errorf("unexpected move of slot %d from %s to %s", move.slot, move.from.ID, move.to.ID)
+			}
+		}
+	})
+}
@@ -3,6 +3,10 @@ package distributedrediscluster
 import (
 	"context"
 
+	sdkk8sutil "github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -19,9 +23,10 @@ import (
 	"github.com/ucloud/redis-cluster-operator/pkg/config"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/heal"
 	clustermanger "github.com/ucloud/redis-cluster-operator/pkg/controller/manager"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
-	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 )
 
 var log = logf.Log.WithName("controller_distributedrediscluster")
@@ -44,7 +49,14 @@ func newReconciler(mgr manager.Manager) reconcile.Reconciler {
 	reconiler := &ReconcileDistributedRedisCluster{client: mgr.GetClient(), scheme: mgr.GetScheme()}
 	reconiler.statefulSetController = k8sutil.NewStatefulSetController(reconiler.client)
 	reconiler.crController = k8sutil.NewCRControl(reconiler.client)
-	reconiler.ensurer = clustermanger.NewEnsureResource(reconiler.client, log)
+	reconiler.podController = k8sutil.NewPodController(reconiler.client)
+	reconiler.serviceController = k8sutil.NewServiceController(reconiler.client)
+	reconiler.pvcController = k8sutil.NewPvcController(reconiler.client)
+	reconiler.nodeController = k8sutil.NewNodeController(reconiler.client)
+	reconiler.storageClassController = k8sutil.NewStorageClassController(reconiler.client)
+	// the namespace is empty when the operator is cluster-scoped
+	reconiler.watchNamespace, _ = sdkk8sutil.GetWatchNamespace()
+	reconiler.ensurer = clustermanger.NewEnsureResource(reconiler.client, reconiler.watchNamespace, log)
 	reconiler.checker = clustermanger.NewCheck(reconiler.client)
 	return reconiler
 }
@@ -87,6 +99,40 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to the Secrets of the passwords, so that they are rolled out to the redis nodes
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: secretToClusters(mgr.GetClient()),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the resources owned by a cluster, so that failures and manual changes are
+	// handled without waiting for the next periodic reconcile
+	owned := []runtime.Object{
+		&appsv1.StatefulSet{},
+		&corev1.ConfigMap{},
+		&corev1.Service{},
+		&policyv1beta1.PodDisruptionBudget{},
+	}
+	for _, obj := range owned {
+		err = c.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &redisv1alpha1.DistributedRedisCluster{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch for changes to the redis pods, which are owned by the statefulSets
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(podToCluster),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -97,12 +143,19 @@ var _ reconcile.Reconciler = &ReconcileDistributedRedisCluster{}
 type ReconcileDistributedRedisCluster struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client                client.Client
-	scheme                *runtime.Scheme
-	ensurer               clustermanger.IEnsureResource
-	checker               clustermanger.ICheck
-	statefulSetController k8sutil.IStatefulSetControl
-	crController          k8sutil.ICustomResource
+	client                 client.Client
+	scheme                 *runtime.Scheme
+	ensurer                clustermanger.IEnsureResource
+	checker                clustermanger.ICheck
+	statefulSetController  k8sutil.IStatefulSetControl
+	crController           k8sutil.ICustomResource
+	podController          k8sutil.IPodControl
+	serviceController      k8sutil.IServiceControl
+	pvcController          k8sutil.IPvcControl
+	nodeController         k8sutil.INodeControl
+	storageClassController k8sutil.IStorageClassControl
+	// watchNamespace is the namespace watched by the operator, empty when it is cluster-scoped
+	watchNamespace string
 }
 
 // Reconcile reads that state of the cluster for a DistributedRedisCluster object and makes changes based on the state read
@@ -119,15 +172,28 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
+			metrics.DeleteCluster(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
+	if instance.GetDeletionTimestamp() != nil {
+		if err := r.finalizeCluster(reqLogger, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+
 	ctx := &syncContext{
 		cluster:   instance,
 		reqLogger: reqLogger,
 	}
+	if instance.Spec.DryRun {
+		ctx.plan = &redisutil.Plan{}
+		// the plan is published whether the reconcile completes or not
+		defer r.publishPlan(ctx)
+	}
 
 	err = r.ensureCluster(ctx)
 	if err != nil {
@@ -137,24 +203,32 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 			return reconcile.Result{}, nil
 		}
 		reqLogger.WithValues("err", err).Info("ensureCluster")
+		r.observeClusterInfos(reqLogger, instance)
 		new := instance.Status.DeepCopy()
 		SetClusterScaling(new, err.Error())
 		r.updateClusterIfNeed(instance, new)
 		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	redisClusterPods, err := r.statefulSetController.GetStatefulSetPods(instance.Namespace, statefulsets.ClusterStatefulSetName(instance.Name))
+	redisClusterPods, err := r.getClusterPods(instance)
 	if err != nil {
-		return reconcile.Result{}, Kubernetes.Wrap(err, "GetStatefulSetPods")
+		return reconcile.Result{}, Kubernetes.Wrap(err, "getClusterPods")
 	}
 
-	ctx.pods = clusterPods(redisClusterPods.Items)
+	zones, err := r.getNodeZones(instance, redisClusterPods)
+	if err != nil {
+		return reconcile.Result{}, Kubernetes.Wrap(err, "getNodeZones")
+	}
+
+	ctx.pods = clusterPods(redisClusterPods)
 	reqLogger.V(6).Info("debug cluster pods", "", ctx.pods)
 	ctx.healer = clustermanger.NewHealer(&heal.CheckAndHeal{
 		Logger:     reqLogger,
 		PodControl: k8sutil.NewPodController(r.client),
 		Pods:       ctx.pods,
-		DryRun:     false,
+		Zones:      zones,
+		DryRun:     instance.Spec.DryRun,
+		Plan:       ctx.plan,
 	})
 	err = r.waitPodReady(ctx)
 	if err != nil {
@@ -163,24 +237,72 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 			return reconcile.Result{}, err
 		}
 		reqLogger.WithValues("err", err).Info("waitPodReady")
+		r.observeClusterInfos(reqLogger, instance)
 		new := instance.Status.DeepCopy()
 		SetClusterScaling(new, err.Error())
 		r.updateClusterIfNeed(instance, new)
 		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	password, err := getClusterPassword(r.client, instance)
+	auth, err := redisadmin.GetAuth(r.client, instance)
+	if err != nil {
+		return reconcile.Result{}, Kubernetes.Wrap(err, "GetAuth")
+	}
+
+	tlsConfig, err := redisadmin.GetTLSConfig(r.client, instance)
 	if err != nil {
-		return reconcile.Result{}, Kubernetes.Wrap(err, "getClusterPassword")
+		return reconcile.Result{}, Kubernetes.Wrap(err, "GetTLSConfig")
 	}
 
-	admin, err := newRedisAdmin(ctx.pods, password, config.RedisConf())
+	announceAddrs, err := r.getAnnounceAddrs(instance, ctx.pods)
+	if err != nil {
+		switch GetType(err) {
+		case Kubernetes:
+			return reconcile.Result{}, err
+		}
+		reqLogger.WithValues("err", err).Info("getAnnounceAddrs")
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+	if err := r.ensureAnnounceConfigMap(ctx, announceAddrs); err != nil {
+		return reconcile.Result{}, Kubernetes.Wrap(err, "ensureAnnounceConfigMap")
+	}
+
+	admin, err := newRedisAdmin(ctx.pods, auth, tlsConfig, config.RedisConf(), announceAddrs)
 	if err != nil {
 		return reconcile.Result{}, Redis.Wrap(err, "newRedisAdmin")
 	}
+	admin = newMetricsAdmin(admin, instance)
 	defer admin.Close()
+	if instance.Spec.DryRun {
+		// the changes of the redis nodes are only planned from here
+		admin = redisutil.NewPlanAdmin(admin, ctx.plan)
+	}
+
+	if err := setAnnounceConfig(admin, ctx.pods, announceAddrs); err != nil {
+		return reconcile.Result{}, Redis.Wrap(err, "setAnnounceConfig")
+	}
+
+	if err := r.rotatePassword(ctx, admin, auth); err != nil {
+		switch GetType(err) {
+		case Kubernetes:
+			return reconcile.Result{}, err
+		case Requeue:
+			reqLogger.WithValues("err", err).Info("requeue")
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+		return reconcile.Result{}, Redis.Wrap(err, "rotatePassword")
+	}
+
+	if err := r.syncACLUsers(instance, admin); err != nil {
+		switch GetType(err) {
+		case Kubernetes:
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, Redis.Wrap(err, "syncACLUsers")
+	}
 
 	clusterInfos, err := admin.GetClusterInfos()
+	metrics.ObserveClusterInfos(instance.Namespace, instance.Name, clusterInfos)
 	if err != nil {
 		if clusterInfos.Status == redisutil.ClusterInfosPartial {
 			return reconcile.Result{}, Redis.Wrap(err, "GetClusterInfos")
@@ -214,6 +336,7 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 	if instance.Spec.Init != nil && instance.Status.RestoreSucceeded <= 0 {
 		reqLogger.Info("update restore redis cluster cr")
 		instance.Status.RestoreSucceeded = 1
+		setClusterConditions(instance, &instance.Status)
 		if err := r.crController.UpdateCRStatus(instance); err != nil {
 			return reconcile.Result{}, err
 		}
@@ -223,20 +346,56 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		return reconcile.Result{}, nil
 	}
 
-	status := buildClusterStatus(clusterInfos, redisClusterPods.Items, &instance.Status)
+	status := buildClusterStatus(clusterInfos, redisClusterPods, zones, &instance.Status)
 	reqLogger.V(4).Info("buildClusterStatus", "status", status)
 	r.updateClusterIfNeed(instance, status)
 
 	instance.Status = *status
 	if needClusterOperation(instance, reqLogger) {
 		reqLogger.Info(">>>>>> clustering")
+		new := instance.Status.DeepCopy()
+		SetClusterRebalancing(new, "dispatching masters and slots")
+		r.updateClusterIfNeed(instance, new)
 		err = r.sync(ctx)
 		if err != nil {
+			switch GetType(err) {
+			case Requeue:
+				reqLogger.WithValues("err", err).Info("requeue")
+				new := instance.Status.DeepCopy()
+				SetClusterScaling(new, err.Error())
+				r.updateClusterIfNeed(instance, new)
+				return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			}
 			new := instance.Status.DeepCopy()
 			SetClusterFailed(new, err.Error())
 			r.updateClusterIfNeed(instance, new)
 			return reconcile.Result{}, err
 		}
+	} else {
+		err = r.rollingUpdate(ctx)
+		if err != nil {
+			switch GetType(err) {
+			case Requeue:
+				reqLogger.WithValues("err", err).Info("requeue")
+				new := instance.Status.DeepCopy()
+				SetClusterRollingUpdate(new, err.Error())
+				r.updateClusterIfNeed(instance, new)
+				return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			}
+			new := instance.Status.DeepCopy()
+			SetClusterFailed(new, err.Error())
+			r.updateClusterIfNeed(instance, new)
+			return reconcile.Result{}, err
+		}
+		err = r.expandVolumes(ctx)
+		if err != nil {
+			switch GetType(err) {
+			case Requeue:
+				reqLogger.WithValues("err", err).Info("requeue")
+				return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			}
+			return reconcile.Result{}, err
+		}
 	}
 
 	newClusterInfos, err := admin.GetClusterInfos()
@@ -245,8 +404,12 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 			return reconcile.Result{}, Redis.Wrap(err, "GetClusterInfos")
 		}
 	}
-	newStatus := buildClusterStatus(newClusterInfos, redisClusterPods.Items, &instance.Status)
-	SetClusterOK(newStatus, "OK")
+	newStatus := buildClusterStatus(newClusterInfos, redisClusterPods, zones, &instance.Status)
+	if len(ctx.plan.Steps()) == 0 {
+		SetClusterOK(newStatus, "OK")
+	}
+	setClusterPlan(instance, newStatus, ctx.plan)
 	r.updateClusterIfNeed(instance, newStatus)
+	metrics.ObserveClusterInfos(instance.Namespace, instance.Name, newClusterInfos)
 	return reconcile.Result{RequeueAfter: requeueEnsure}, nil
 }
@@ -2,18 +2,35 @@ package distributedrediscluster
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/clustering"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/monitors"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
@@ -23,7 +40,10 @@ var (
 	}
 )
 
-const passwordKey = "password"
+const (
+	// monitorFinalizer deletes the monitors created in another namespace than the one of the cluster
+	monitorFinalizer = "finalizer.monitor.redis.kun"
+)
 
 func getLabels(cluster *redisv1alpha1.DistributedRedisCluster) map[string]string {
 	dynLabels := map[string]string{
@@ -32,47 +52,342 @@ func getLabels(cluster *redisv1alpha1.DistributedRedisCluster) map[string]string
 	return utils.MergeLabels(defaultLabels, dynLabels, cluster.Labels)
 }
 
-func getClusterPassword(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (string, error) {
-	if cluster.Spec.PasswordSecret == nil {
-		return "", nil
+// getACLUsers returns the ACL users of spec.users with the password of their Secret.
+func getACLUsers(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) ([]redisutil.ACLUser, error) {
+	users := make([]redisutil.ACLUser, 0, len(cluster.Spec.Users))
+	for _, user := range cluster.Spec.Users {
+		password, err := redisadmin.GetSecretPassword(client, cluster.Namespace, user.PasswordSecret.Name)
+		if err != nil {
+			return nil, err
+		}
+		rules := make([]string, 0, len(user.Keys)+len(user.Commands))
+		for _, key := range user.Keys {
+			rules = append(rules, "~"+key)
+		}
+		rules = append(rules, user.Commands...)
+		users = append(users, redisutil.ACLUser{
+			Name:     user.Name,
+			Password: password,
+			Rules:    rules,
+		})
+	}
+	return users, nil
+}
+
+// secretToClusters maps a Secret to the clusters of its namespace which read a password from it.
+func secretToClusters(c client.Client) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		clusters := &redisv1alpha1.DistributedRedisClusterList{}
+		if err := c.List(context.TODO(), clusters, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+			log.Error(err, "unable to list the clusters of the secret", "namespace", obj.Meta.GetNamespace(), "name", obj.Meta.GetName())
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, cluster := range clusters.Items {
+			if usesSecret(&cluster, obj.Meta.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: cluster.Namespace,
+					Name:      cluster.Name,
+				}})
+			}
+		}
+		return requests
+	}
+}
+
+// podToCluster maps a redis pod to the cluster it belongs to.
+func podToCluster(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	name, ok := labels[redisv1alpha1.LabelClusterName]
+	if !ok || labels[redisv1alpha1.LabelManagedByKey] != redisv1alpha1.OperatorName {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: obj.Meta.GetNamespace(),
+		Name:      name,
+	}}}
+}
+
+// usesSecret returns true if the password of the default user or of an ACL user of the cluster is read from the Secret.
+func usesSecret(cluster *redisv1alpha1.DistributedRedisCluster, name string) bool {
+	if cluster.Spec.PasswordSecret != nil && cluster.Spec.PasswordSecret.Name == name {
+		return true
+	}
+	for _, user := range cluster.Spec.Users {
+		if user.PasswordSecret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// announceAddr is the address a redis node announces to the other nodes and to the clients
+type announceAddr struct {
+	IP      string
+	Port    int32
+	BusPort int32
+}
+
+// getAnnounceAddrs returns the announce address of each pod, indexed by pod name, when the pods
+// are exposed by a service of spec.expose.podServiceType.
+func (r *ReconcileDistributedRedisCluster) getAnnounceAddrs(cluster *redisv1alpha1.DistributedRedisCluster, pods []*corev1.Pod) (map[string]*announceAddr, error) {
+	addrs := map[string]*announceAddr{}
+	if cluster.Spec.Expose == nil || cluster.Spec.Expose.PodServiceType == "" {
+		return addrs, nil
+	}
+	for _, pod := range pods {
+		svc, err := r.serviceController.GetService(pod.Namespace, pod.Name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, Requeue.Wrap(err, "getAnnounceAddrs")
+			}
+			return nil, Kubernetes.Wrap(err, "getAnnounceAddrs")
+		}
+		addr := &announceAddr{}
+		switch svc.Spec.Type {
+		case corev1.ServiceTypeNodePort:
+			addr.IP = pod.Status.HostIP
+			for _, port := range svc.Spec.Ports {
+				switch port.Name {
+				case "client":
+					addr.Port = port.NodePort
+				case "gossip":
+					addr.BusPort = port.NodePort
+				}
+			}
+		case corev1.ServiceTypeLoadBalancer:
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" {
+					addr.IP = ingress.IP
+					break
+				}
+			}
+			for _, port := range svc.Spec.Ports {
+				switch port.Name {
+				case "client":
+					addr.Port = port.Port
+				case "gossip":
+					addr.BusPort = port.Port
+				}
+			}
+		}
+		if addr.IP == "" || addr.Port == 0 || addr.BusPort == 0 {
+			return nil, Requeue.Wrap(fmt.Errorf("service %s has no address assigned yet", svc.Name), "getAnnounceAddrs")
+		}
+		addrs[pod.Name] = addr
+	}
+	return addrs, nil
+}
+
+// ensureAnnounceConfigMap writes the announce address of each pod in the announce configMap, fix-ip.sh
+// passes it to redis-server so that a restarted node announces it from the start. The IP of a node port
+// service is the IP of the kubernetes node the pod runs on, it is only known when the pod starts.
+func (r *ReconcileDistributedRedisCluster) ensureAnnounceConfigMap(ctx *syncContext, announceAddrs map[string]*announceAddr) error {
+	cluster := ctx.cluster
+	if !cluster.ArePodsExposed() {
+		return nil
+	}
+	announces := map[string]string{}
+	for podName, addr := range announceAddrs {
+		config := fmt.Sprintf("ANNOUNCE_PORT=%d\nANNOUNCE_BUS_PORT=%d\n", addr.Port, addr.BusPort)
+		if cluster.Spec.Expose.PodServiceType != corev1.ServiceTypeNodePort {
+			config = fmt.Sprintf("ANNOUNCE_IP=%s\n", addr.IP) + config
+		}
+		announces[podName] = config
+	}
+	var c client.Client = r.client
+	if cluster.Spec.DryRun {
+		c = &planClient{Client: r.client, plan: ctx.plan}
+	}
+	cmController := k8sutil.NewConfigMapController(c)
+	newCm := configmaps.NewAnnounceConfigMapForCR(cluster, getLabels(cluster), announces)
+	cm, err := cmController.GetConfigMap(cluster.Namespace, newCm.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			ctx.reqLogger.Info("creating the announce configMap", "ConfigMap.Name", newCm.Name)
+			return cmController.CreateConfigMap(newCm)
+		}
+		return err
+	}
+	if reflect.DeepEqual(cm.Data, newCm.Data) {
+		return nil
+	}
+	ctx.reqLogger.Info("updating the announce configMap", "ConfigMap.Name", newCm.Name)
+	cm.Data = newCm.Data
+	return cmController.UpdateConfigMap(cm)
+}
+
+// setAnnounceConfig sets the cluster-announce-* config of each pod, the config is reset
+// when the pod has no announce address.
+func setAnnounceConfig(admin redisutil.IAdmin, pods []*corev1.Pod, announceAddrs map[string]*announceAddr) error {
+	for _, pod := range pods {
+		config := map[string]string{
+			"cluster-announce-ip":       "",
+			"cluster-announce-port":     "0",
+			"cluster-announce-bus-port": "0",
+		}
+		if addr, ok := announceAddrs[pod.Name]; ok {
+			config["cluster-announce-ip"] = addr.IP
+			config["cluster-announce-port"] = strconv.Itoa(int(addr.Port))
+			config["cluster-announce-bus-port"] = strconv.Itoa(int(addr.BusPort))
+		}
+		if err := admin.SetNodeConfigIfNeed(podRedisAddr(pod), config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// podRedisAddr returns the address of the redis server of the pod
+func podRedisAddr(pod *corev1.Pod) string {
+	redisPort := redisutil.DefaultRedisPort
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "redis" {
+			for _, port := range container.Ports {
+				if port.Name == "client" {
+					redisPort = fmt.Sprintf("%d", port.ContainerPort)
+				}
+			}
+		}
+	}
+	return net.JoinHostPort(pod.Status.PodIP, redisPort)
+}
+
+// saveAppliedPassword records the password applied to the redis nodes and the previous password they still accept,
+// and the time of the rotation when the password replaces another one.
+func saveAppliedPassword(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster, password, previousPassword string) error {
+	data := map[string][]byte{
+		redisadmin.PasswordKey: []byte(password),
+	}
+	if previousPassword != "" {
+		data[redisadmin.PreviousPasswordKey] = []byte(previousPassword)
 	}
 	secret := &corev1.Secret{}
 	err := client.Get(context.TODO(), types.NamespacedName{
-		Name:      cluster.Spec.PasswordSecret.Name,
+		Name:      cluster.AppliedPasswordSecretName(),
 		Namespace: cluster.Namespace,
 	}, secret)
+	if errors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            cluster.AppliedPasswordSecretName(),
+				Namespace:       cluster.Namespace,
+				Labels:          getLabels(cluster),
+				OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+			},
+			Data: data,
+		}
+		return client.Create(context.TODO(), secret)
+	}
 	if err != nil {
-		return "", err
+		return err
+	}
+	if reflect.DeepEqual(secret.Data, data) {
+		return nil
+	}
+	if string(secret.Data[redisadmin.PasswordKey]) != password {
+		// the grace period of the previous password starts
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[redisv1alpha1.AnnotationPasswordRotatedAt] = time.Now().UTC().Format(time.RFC3339)
 	}
-	return string(secret.Data[passwordKey]), nil
+	secret.Data = data
+	return client.Update(context.TODO(), secret)
 }
 
 // newRedisAdmin builds and returns new redis.Admin from the list of pods
-func newRedisAdmin(pods []*corev1.Pod, password string, cfg *config.Redis) (redisutil.IAdmin, error) {
+func newRedisAdmin(pods []*corev1.Pod, auth *redisadmin.Auth, tlsConfig *tls.Config, cfg *config.Redis, announceAddrs map[string]*announceAddr) (redisutil.IAdmin, error) {
 	nodesAddrs := []string{}
+	announces := map[string]string{}
 	for _, pod := range pods {
-		redisPort := redisutil.DefaultRedisPort
-		for _, container := range pod.Spec.Containers {
-			if container.Name == "redis" {
-				for _, port := range container.Ports {
-					if port.Name == "client" {
-						redisPort = fmt.Sprintf("%d", port.ContainerPort)
-					}
-				}
-			}
+		addr := podRedisAddr(pod)
+		log.V(4).Info("append redis admin addr", "addr", addr)
+		nodesAddrs = append(nodesAddrs, addr)
+		if announce, ok := announceAddrs[pod.Name]; ok {
+			announces[net.JoinHostPort(announce.IP, strconv.Itoa(int(announce.Port)))] = addr
 		}
-		log.V(4).Info("append redis admin addr", "addr", pod.Status.PodIP, "port", redisPort)
-		nodesAddrs = append(nodesAddrs, net.JoinHostPort(pod.Status.PodIP, redisPort))
 	}
 	adminConfig := redisutil.AdminOptions{
 		ConnectionTimeout:  time.Duration(cfg.DialTimeout) * time.Millisecond,
 		RenameCommandsFile: cfg.GetRenameCommandsFile(),
-		Password:           password,
+		TLSConfig:          tlsConfig,
+		AnnounceAddrs:      announces,
 	}
+	auth.SetCredentials(&adminConfig)
 
 	return redisutil.NewAdmin(nodesAddrs, &adminConfig), nil
 }
 
+// metricsAdmin counts the slot migrations and the failovers run by the admin of a cluster.
+type metricsAdmin struct {
+	redisutil.IAdmin
+	namespace string
+	name      string
+}
+
+func newMetricsAdmin(admin redisutil.IAdmin, cluster *redisv1alpha1.DistributedRedisCluster) redisutil.IAdmin {
+	return &metricsAdmin{IAdmin: admin, namespace: cluster.Namespace, name: cluster.Name}
+}
+
+// rebalanceOptions returns the options the slots of the cluster are balanced and moved with.
+func rebalanceOptions(cluster *redisv1alpha1.DistributedRedisCluster) clustering.RebalanceOptions {
+	opts := clustering.DefaultRebalanceOptions
+	spec := cluster.Spec.Rebalance
+	if spec == nil {
+		return opts
+	}
+	opts.ByKeys = spec.Strategy == redisv1alpha1.RebalanceStrategyKeys
+	opts.Threshold = spec.Threshold
+	if len(spec.Weights) > 0 {
+		opts.Weights = map[string]int32{}
+		for shard, weight := range spec.Weights {
+			if index, err := strconv.Atoi(shard); err == nil {
+				opts.Weights[statefulsets.ClusterStatefulSetName(cluster.Name, index)] = weight
+			}
+		}
+	}
+	if spec.BatchSize > 0 {
+		opts.Migrate.Batch = int(spec.BatchSize)
+	}
+	if spec.MigrateTimeout > 0 {
+		opts.Migrate.Timeout = int(spec.MigrateTimeout)
+	}
+	opts.Migrate.KeysPerSecond = spec.KeysPerSecond
+	if spec.BytesPerSecond != nil {
+		opts.Migrate.BytesPerSecond = spec.BytesPerSecond.Value()
+	}
+	return opts
+}
+
+func (a *metricsAdmin) MigrateKeys(addr string, dest *redisutil.Node, slots []redisutil.Slot, opts redisutil.MigrateOptions) (int, error) {
+	nbMigrated, err := a.IAdmin.MigrateKeys(addr, dest, slots, opts)
+	metrics.SlotsMigrated(a.namespace, a.name, len(slots), nbMigrated)
+	return nbMigrated, err
+}
+
+func (a *metricsAdmin) MigrateKeysInSlot(addr string, dest *redisutil.Node, slot redisutil.Slot, opts redisutil.MigrateOptions) (int, error) {
+	nbMigrated, err := a.IAdmin.MigrateKeysInSlot(addr, dest, slot, opts)
+	metrics.SlotsMigrated(a.namespace, a.name, 1, nbMigrated)
+	return nbMigrated, err
+}
+
+func (a *metricsAdmin) StartFailover(addr string) error {
+	if err := a.IAdmin.StartFailover(addr); err != nil {
+		return err
+	}
+	metrics.Failover(a.namespace, a.name)
+	return nil
+}
+
+func (a *metricsAdmin) Failover(addr string) error {
+	if err := a.IAdmin.Failover(addr); err != nil {
+		return err
+	}
+	metrics.Failover(a.namespace, a.name)
+	return nil
+}
+
 func makeCluster(cluster *redisv1alpha1.DistributedRedisCluster, clusterInfos *redisutil.ClusterInfos) error {
 	logger := log.WithValues("namespace", cluster.Namespace, "name", cluster.Name)
 	mastersCount := int(cluster.Spec.MasterSize)
@@ -140,12 +455,161 @@ func newRedisCluster(infos *redisutil.ClusterInfos, cluster *redisv1alpha1.Distr
 		if rNode, ok := rCluster.Nodes[node.ID]; ok {
 			rNode.PodName = node.PodName
 			rNode.NodeName = node.NodeName
+			rNode.StatefulSet = node.StatefulSet
+			rNode.Zone = node.Zone
 		}
 	}
 
 	return rCluster, nodes, nil
 }
 
+// plannedNodes returns the nodes of the pods required by the spec which are not running, the pods of the
+// statefulSets only planned in dry-run mode. They are masters without slots, named after their pod.
+func plannedNodes(cluster *redisv1alpha1.DistributedRedisCluster, pods []*corev1.Pod) redisutil.Nodes {
+	running := map[string]bool{}
+	for _, pod := range pods {
+		running[pod.Name] = true
+	}
+	var nodes redisutil.Nodes
+	for shard := 0; shard < int(cluster.Spec.MasterSize); shard++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, shard)
+		for i := 0; i <= int(cluster.Spec.ClusterReplicas); i++ {
+			podName := fmt.Sprintf("%s-%d", name, i)
+			if running[podName] {
+				continue
+			}
+			node := redisutil.NewDefaultNode()
+			node.ID = podName
+			node.IP = podName
+			node.Role = redisutil.RedisMasterRole
+			node.PodName = podName
+			node.StatefulSet = name
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// getClusterPods returns the pods of every statefulSet of the cluster, including the legacy statefulSet
+// holding every pod of the clusters created before each shard had its own statefulSet.
+func (r *ReconcileDistributedRedisCluster) getClusterPods(cluster *redisv1alpha1.DistributedRedisCluster) ([]corev1.Pod, error) {
+	ssList, err := r.statefulSetController.ListStatefulSetByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pods []corev1.Pod
+	for _, ss := range ssList.Items {
+		podList, err := r.statefulSetController.GetStatefulSetPods(ss.Namespace, ss.Name)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, podList.Items...)
+	}
+	return pods, nil
+}
+
+// observeClusterInfos sets the metrics of the cluster from the view of its running nodes when the reconcile stops
+// before the admin of the cluster is built, so that the slots and the flagged nodes are not left stale while the
+// pods are not ready.
+func (r *ReconcileDistributedRedisCluster) observeClusterInfos(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) {
+	pods, err := r.getClusterPods(cluster)
+	if err != nil {
+		reqLogger.V(4).Info("observeClusterInfos", "err", err)
+		return
+	}
+	var running []*corev1.Pod
+	for _, pod := range clusterPods(pods) {
+		if pod.Status.PodIP != "" && pod.DeletionTimestamp == nil {
+			running = append(running, pod)
+		}
+	}
+	auth, err := redisadmin.GetAuth(r.client, cluster)
+	if err != nil {
+		reqLogger.V(4).Info("observeClusterInfos", "err", err)
+		return
+	}
+	tlsConfig, err := redisadmin.GetTLSConfig(r.client, cluster)
+	if err != nil {
+		reqLogger.V(4).Info("observeClusterInfos", "err", err)
+		return
+	}
+	admin, err := newRedisAdmin(running, auth, tlsConfig, config.RedisConf(), nil)
+	if err != nil {
+		reqLogger.V(4).Info("observeClusterInfos", "err", err)
+		return
+	}
+	defer admin.Close()
+	// the infos of the unreachable nodes are missing, the view of the others still flags them
+	clusterInfos, _ := admin.GetClusterInfos()
+	metrics.ObserveClusterInfos(cluster.Namespace, cluster.Name, clusterInfos)
+}
+
+// ensureMonitorFinalizer adds monitorFinalizer to a cluster whose monitor is created in another namespace, the
+// finalizer is kept until the cluster is deleted so that a monitor left by a namespace change is deleted too.
+// The monitor is not created in dry-run mode.
+func (r *ReconcileDistributedRedisCluster) ensureMonitorFinalizer(cluster *redisv1alpha1.DistributedRedisCluster) error {
+	mon := cluster.Spec.Monitor
+	if cluster.Spec.DryRun || mon == nil || mon.Prometheus == nil || monitors.Namespace(cluster) == cluster.Namespace {
+		return nil
+	}
+	for _, f := range cluster.GetFinalizers() {
+		if f == monitorFinalizer {
+			return nil
+		}
+	}
+	cluster.SetFinalizers(append(cluster.GetFinalizers(), monitorFinalizer))
+	return r.client.Update(context.TODO(), cluster)
+}
+
+// finalizeCluster deletes the monitors of a deleted cluster and removes monitorFinalizer.
+func (r *ReconcileDistributedRedisCluster) finalizeCluster(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) error {
+	var finalizers []string
+	for _, f := range cluster.GetFinalizers() {
+		if f != monitorFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(cluster.GetFinalizers()) {
+		return nil
+	}
+	reqLogger.Info("Deleting the monitors of the cluster")
+	if err := r.ensurer.DeleteRedisMonitors(cluster); err != nil {
+		return err
+	}
+	cluster.SetFinalizers(finalizers)
+	return r.client.Update(context.TODO(), cluster)
+}
+
+// getNodeZones returns the value of the spec.topologyKey label of the kubernetes nodes the pods run on, by
+// node name. It is empty when the cluster has no topologyKey, and the zone of a deleted node is unknown.
+func (r *ReconcileDistributedRedisCluster) getNodeZones(cluster *redisv1alpha1.DistributedRedisCluster, pods []corev1.Pod) (map[string]string, error) {
+	zones := map[string]string{}
+	if cluster.Spec.TopologyKey == "" {
+		return zones, nil
+	}
+	for _, pod := range pods {
+		nodeName := pod.Spec.NodeName
+		if nodeName == "" {
+			continue
+		}
+		if _, ok := zones[nodeName]; ok {
+			continue
+		}
+		node, err := r.nodeController.GetNode(nodeName)
+		if errors.IsNotFound(err) {
+			zones[nodeName] = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		zones[nodeName] = node.Labels[cluster.Spec.TopologyKey]
+	}
+	return zones, nil
+}
+
 func clusterPods(pods []corev1.Pod) []*corev1.Pod {
 	var podSlice []*corev1.Pod
 	for _, pod := range pods {
@@ -155,6 +619,97 @@ func clusterPods(pods []corev1.Pod) []*corev1.Pod {
 	return podSlice
 }
 
+// selectSyncedSlave returns the healthy slave whose replication link is up with the highest replication
+// offset, a slave still doing a full resync after a restart is never selected. It returns nil if no slave
+// is in sync.
+func selectSyncedSlave(admin redisutil.IAdmin, slaves redisutil.Nodes) *redisutil.Node {
+	var selected *redisutil.Node
+	var selectedOffset int64
+	for _, slave := range slaves {
+		if slave.HasStatus(redisutil.NodeStatusFail) || slave.HasStatus(redisutil.NodeStatusPFail) {
+			continue
+		}
+		if up, err := admin.IsMasterLinkUp(slave.IPPort()); err != nil || !up {
+			continue
+		}
+		offset, err := admin.GetReplicationOffset(slave.IPPort())
+		if err != nil {
+			continue
+		}
+		if selected == nil || offset > selectedOffset {
+			selected, selectedOffset = slave, offset
+		}
+	}
+	return selected
+}
+
+// podOrdinal returns the ordinal of a statefulSet pod, parsed from the pod name.
+func podOrdinal(podName string) (int, error) {
+	idx := strings.LastIndex(podName, "-")
+	if idx < 0 {
+		return 0, fmt.Errorf("unable to parse ordinal from pod name: %s", podName)
+	}
+	return strconv.Atoi(podName[idx+1:])
+}
+
+// splitNodesByShard returns the redis nodes running on the pods that will be removed, either because their
+// shard is beyond spec.masterSize or because their ordinal is beyond spec.clusterReplicas, and the ones that
+// will be kept.
+func splitNodesByShard(cluster *redisv1alpha1.DistributedRedisCluster, nodes redisutil.Nodes) (removed redisutil.Nodes, kept redisutil.Nodes) {
+	shards := map[string]bool{}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		shards[statefulsets.ClusterStatefulSetName(cluster.Name, i)] = true
+	}
+	for _, node := range nodes {
+		if node.StatefulSet == "" {
+			kept = append(kept, node)
+			continue
+		}
+		ordinal, err := podOrdinal(node.PodName)
+		if shards[node.StatefulSet] && (err != nil || ordinal <= int(cluster.Spec.ClusterReplicas)) {
+			kept = append(kept, node)
+			continue
+		}
+		removed = append(removed, node)
+	}
+	return removed, kept
+}
+
+// checkKeptNodesHealthy returns an error if a kept node is failing while a removed node of the same shard is healthy.
+func checkKeptNodesHealthy(removed, kept redisutil.Nodes) error {
+	isFailing := func(node *redisutil.Node) bool {
+		return node.HasStatus(redisutil.NodeStatusFail) || node.HasStatus(redisutil.NodeStatusPFail)
+	}
+	for _, node := range kept {
+		if node.StatefulSet == "" || !isFailing(node) {
+			continue
+		}
+		for _, removedNode := range removed {
+			if removedNode.StatefulSet == node.StatefulSet && !isFailing(removedNode) {
+				return fmt.Errorf("node %s of pod %s is failing, the healthy node of pod %s would be removed",
+					node.ID, node.PodName, removedNode.PodName)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNodesForgotten returns an error if a node of the cluster is unreachable or still knows one of the removed nodes.
+func checkNodesForgotten(admin redisutil.IAdmin, removed redisutil.Nodes) error {
+	infos, err := admin.GetClusterInfos()
+	if infos == nil || infos.Status == redisutil.ClusterInfosPartial {
+		return fmt.Errorf("unable to check that the removed nodes are forgotten: %v", err)
+	}
+	for addr, nodeInfos := range infos.Infos {
+		for _, friend := range nodeInfos.Friends {
+			if _, err := removed.GetNodeByID(friend.ID); err == nil {
+				return fmt.Errorf("node %s still knows the removed node %s", addr, friend.ID)
+			}
+		}
+	}
+	return nil
+}
+
 func needClusterOperation(cluster *redisv1alpha1.DistributedRedisCluster, reqLogger logr.Logger) bool {
 	if compareIntValue("NumberOfMaster", &cluster.Status.NumberOfMaster, &cluster.Spec.MasterSize) {
 		reqLogger.V(4).Info("needClusterOperation---NumberOfMaster")
@@ -171,5 +726,56 @@ func needClusterOperation(cluster *redisv1alpha1.DistributedRedisCluster, reqLog
 		return true
 	}
 
+	expectNodeNum := cluster.Spec.MasterSize * (cluster.Spec.ClusterReplicas + 1)
+	if int32(len(cluster.Status.Nodes)) > expectNodeNum {
+		reqLogger.V(4).Info("needClusterOperation---len(Nodes)")
+		return true
+	}
+
 	return false
 }
+
+// planClient is a client recording the changes of the kubernetes resources in a plan instead of making them, the
+// resources are still read from the apiserver.
+type planClient struct {
+	client.Client
+	plan *redisutil.Plan
+}
+
+func (c *planClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.plan.Add("create %s", planObject(obj))
+	return nil
+}
+
+func (c *planClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.plan.Add("update %s", planObject(obj))
+	return nil
+}
+
+func (c *planClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.plan.Add("patch %s", planObject(obj))
+	return nil
+}
+
+func (c *planClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.plan.Add("delete %s", planObject(obj))
+	return nil
+}
+
+func (c *planClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.plan.Add("delete all the %s", planObject(obj))
+	return nil
+}
+
+// planObject returns the kind and the name of an object, e.g. "StatefulSet drc-example-0".
+func planObject(obj runtime.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil || accessor.GetName() == "" {
+		return kind
+	}
+	return fmt.Sprintf("%s %s", kind, accessor.GetName())
+}
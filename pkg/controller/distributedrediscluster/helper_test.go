@@ -0,0 +1,187 @@
+package distributedrediscluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
+)
+
+func passwordSecret(name, password string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{redisadmin.PasswordKey: []byte(password)},
+	}
+}
+
+func rotatedSecret(name, password, previous, rotatedAt string) *corev1.Secret {
+	secret := passwordSecret(name, password)
+	secret.Data[redisadmin.PreviousPasswordKey] = []byte(previous)
+	secret.Annotations = map[string]string{redisv1alpha1.AnnotationPasswordRotatedAt: rotatedAt}
+	return secret
+}
+
+func passwordCluster() *redisv1alpha1.DistributedRedisCluster {
+	return &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			PasswordSecret: &corev1.LocalObjectReference{Name: "test-password"},
+		},
+	}
+}
+
+func Test_saveAppliedPassword(t *testing.T) {
+	cluster := passwordCluster()
+	tests := []struct {
+		name          string
+		objs          []runtime.Object
+		password      string
+		previous      string
+		wantRotatedAt bool
+	}{
+		{
+			name:     "first record",
+			password: "new",
+		},
+		{
+			name:     "same password",
+			objs:     []runtime.Object{passwordSecret(cluster.AppliedPasswordSecretName(), "new")},
+			password: "new",
+		},
+		{
+			name:          "rotation",
+			objs:          []runtime.Object{passwordSecret(cluster.AppliedPasswordSecretName(), "old")},
+			password:      "new",
+			previous:      "old",
+			wantRotatedAt: true,
+		},
+		{
+			name:     "previous password removed",
+			objs:     []runtime.Object{rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", "2020-01-01T00:00:00Z")},
+			password: "new",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, tt.objs...)
+			if err := saveAppliedPassword(client, cluster, tt.password, tt.previous); err != nil {
+				t.Fatalf("saveAppliedPassword() error = %v", err)
+			}
+			secret := &corev1.Secret{}
+			if err := client.Get(context.TODO(), types.NamespacedName{
+				Name:      cluster.AppliedPasswordSecretName(),
+				Namespace: cluster.Namespace,
+			}, secret); err != nil {
+				t.Fatalf("applied password secret not found: %v", err)
+			}
+			if got := string(secret.Data[redisadmin.PasswordKey]); got != tt.password {
+				t.Errorf("saveAppliedPassword() password = %s, want %s", got, tt.password)
+			}
+			if got := string(secret.Data[redisadmin.PreviousPasswordKey]); got != tt.previous {
+				t.Errorf("saveAppliedPassword() previous password = %s, want %s", got, tt.previous)
+			}
+			if rotatedAt := secret.Annotations[redisv1alpha1.AnnotationPasswordRotatedAt]; (rotatedAt != "" && rotatedAt != "2020-01-01T00:00:00Z") != tt.wantRotatedAt {
+				t.Errorf("saveAppliedPassword() annotations = %v, want the rotation time %v", secret.Annotations, tt.wantRotatedAt)
+			}
+		})
+	}
+}
+
+func Test_getNodeZones(t *testing.T) {
+	cluster := passwordCluster()
+	cluster.Spec.TopologyKey = "topology.kubernetes.io/zone"
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{cluster.Spec.TopologyKey: "zone-a"}},
+	}
+	pods := []corev1.Pod{
+		{Spec: corev1.PodSpec{NodeName: "node-a"}},
+		{Spec: corev1.PodSpec{NodeName: "node-deleted"}},
+		{Spec: corev1.PodSpec{}},
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+	r := &ReconcileDistributedRedisCluster{client: client, nodeController: k8sutil.NewNodeController(client)}
+
+	zones, err := r.getNodeZones(cluster, pods)
+	if err != nil {
+		t.Fatalf("getNodeZones() error = %v", err)
+	}
+	want := map[string]string{"node-a": "zone-a", "node-deleted": ""}
+	if !reflect.DeepEqual(zones, want) {
+		t.Errorf("getNodeZones() = %v, want %v", zones, want)
+	}
+}
+
+func TestReconcileDistributedRedisCluster_ensureAnnounceConfigMap(t *testing.T) {
+	addrs := map[string]*announceAddr{
+		"drc-test-0-0": {IP: "10.0.0.1", Port: 30001, BusPort: 30002},
+	}
+	tests := []struct {
+		name           string
+		podServiceType corev1.ServiceType
+		dryRun         bool
+		want           map[string]string
+	}{
+		{
+			name:           "node port services",
+			podServiceType: corev1.ServiceTypeNodePort,
+			want:           map[string]string{"drc-test-0-0": "ANNOUNCE_PORT=30001\nANNOUNCE_BUS_PORT=30002\n"},
+		},
+		{
+			name:           "load balancer services",
+			podServiceType: corev1.ServiceTypeLoadBalancer,
+			want:           map[string]string{"drc-test-0-0": "ANNOUNCE_IP=10.0.0.1\nANNOUNCE_PORT=30001\nANNOUNCE_BUS_PORT=30002\n"},
+		},
+		{
+			name:           "dry-run",
+			podServiceType: corev1.ServiceTypeNodePort,
+			dryRun:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := passwordCluster()
+			cluster.Spec.Expose = &redisv1alpha1.ExposeSpec{PodServiceType: tt.podServiceType}
+			cluster.Spec.DryRun = tt.dryRun
+			client := fake.NewFakeClientWithScheme(scheme.Scheme)
+			r := &ReconcileDistributedRedisCluster{client: client}
+			ctx := &syncContext{cluster: cluster, reqLogger: log, plan: &redisutil.Plan{}}
+
+			if err := r.ensureAnnounceConfigMap(ctx, addrs); err != nil {
+				t.Fatalf("ensureAnnounceConfigMap() error = %v", err)
+			}
+
+			cm := &corev1.ConfigMap{}
+			err := client.Get(context.TODO(), types.NamespacedName{
+				Name:      configmaps.AnnounceConfigMapName(cluster.Name),
+				Namespace: cluster.Namespace,
+			}, cm)
+			if tt.dryRun {
+				if err == nil {
+					t.Errorf("ensureAnnounceConfigMap() created the configMap in dry-run mode")
+				}
+				if len(ctx.plan.Steps()) == 0 {
+					t.Errorf("ensureAnnounceConfigMap() planned nothing in dry-run mode")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("announce configMap not found: %v", err)
+			}
+			if !reflect.DeepEqual(cm.Data, tt.want) {
+				t.Errorf("ensureAnnounceConfigMap() data = %q, want %q", cm.Data, tt.want)
+			}
+		})
+	}
+}
@@ -6,36 +6,146 @@ import (
 	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 )
 
 func SetClusterFailed(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
 	status.Status = redisv1alpha1.ClusterStatusKO
 	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "ClusterFailed", reason)
 }
 
 func SetClusterOK(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
 	status.Status = redisv1alpha1.ClusterStatusOK
 	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionTrue, "ClusterOK", reason)
+	setCondition(status, redisv1alpha1.ClusterConditionScaling, corev1.ConditionFalse, "ScalingComplete", "")
+	setCondition(status, redisv1alpha1.ClusterConditionRebalancing, corev1.ConditionFalse, "RebalancingComplete", "")
+	setCondition(status, redisv1alpha1.ClusterConditionUpgrading, corev1.ConditionFalse, "UpgradeComplete", "")
 }
 
 func SetClusterCreating(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
 	status.Status = redisv1alpha1.ClusterStatusCreating
 	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "ClusterCreating", reason)
 }
 
 func SetClusterScaling(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
 	status.Status = redisv1alpha1.ClusterStatusScaling
 	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "ClusterScaling", reason)
+	setCondition(status, redisv1alpha1.ClusterConditionScaling, corev1.ConditionTrue, "Scaling", reason)
 }
 
-func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []corev1.Pod, oldStatus *redisv1alpha1.DistributedRedisClusterStatus) *redisv1alpha1.DistributedRedisClusterStatus {
+func SetClusterRebalancing(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
+	status.Status = redisv1alpha1.ClusterStatusRebalancing
+	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "ClusterRebalancing", reason)
+	setCondition(status, redisv1alpha1.ClusterConditionRebalancing, corev1.ConditionTrue, "Rebalancing", reason)
+}
+
+func SetClusterRollingUpdate(status *redisv1alpha1.DistributedRedisClusterStatus, reason string) {
+	status.Status = redisv1alpha1.ClusterStatusRollingUpdate
+	status.Reason = reason
+	setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "RollingUpdate", reason)
+	setCondition(status, redisv1alpha1.ClusterConditionUpgrading, corev1.ConditionTrue, "RollingUpdate", reason)
+}
+
+// setClusterPlan sets the plan computed in dry-run mode, the cluster is not ready while changes are planned. The plan
+// is removed when spec.dryRun is unset.
+func setClusterPlan(cluster *redisv1alpha1.DistributedRedisCluster, status *redisv1alpha1.DistributedRedisClusterStatus, plan *redisutil.Plan) {
+	if !cluster.Spec.DryRun {
+		status.Plan = nil
+		return
+	}
+	status.Plan = &redisv1alpha1.ClusterPlan{
+		ObservedGeneration: cluster.Generation,
+		Steps:              plan.Steps(),
+	}
+	if len(status.Plan.Steps) > 0 {
+		setCondition(status, redisv1alpha1.ClusterConditionReady, corev1.ConditionFalse, "DryRun",
+			fmt.Sprintf("%d changes planned", len(status.Plan.Steps)))
+	}
+}
+
+func setCondition(status *redisv1alpha1.DistributedRedisClusterStatus, conditionType redisv1alpha1.ClusterConditionType,
+	conditionStatus corev1.ConditionStatus, reason, message string) {
+	status.SetCondition(redisv1alpha1.ClusterCondition{
+		Type:    conditionType,
+		Status:  conditionStatus,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// setClusterConditions sets the conditions observed from the nodes of the cluster and its spec, and the
+// generation of the spec they were observed upon.
+func setClusterConditions(cluster *redisv1alpha1.DistributedRedisCluster, status *redisv1alpha1.DistributedRedisClusterStatus) {
+	// in dry-run mode the spec is observed once it requires no change
+	plan := status.Plan
+	if !cluster.Spec.DryRun || (plan != nil && plan.ObservedGeneration == cluster.Generation && len(plan.Steps) == 0) {
+		status.ObservedGeneration = cluster.Generation
+	}
+
+	if len(status.Nodes) > 0 {
+		assigned := 0
+		for _, node := range status.Nodes {
+			if node.Role != redisv1alpha1.RedisClusterNodeRoleMaster {
+				continue
+			}
+			for _, slotRange := range node.Slots {
+				slots, _, _, err := redisutil.DecodeSlotRange(slotRange)
+				if err != nil {
+					continue
+				}
+				assigned += len(slots)
+			}
+		}
+		total := redisutil.DefaultHashMaxSlots + 1
+		if assigned >= total {
+			setCondition(status, redisv1alpha1.ClusterConditionSlotsCovered, corev1.ConditionTrue, "AllSlotsAssigned", "")
+		} else {
+			setCondition(status, redisv1alpha1.ClusterConditionSlotsCovered, corev1.ConditionFalse, "SlotsUnassigned",
+				fmt.Sprintf("%d of %d slots are not assigned", total-assigned, total))
+		}
+
+		if status.NumberOfMaster >= cluster.Spec.MasterSize && status.MinReplicationFactor >= cluster.Spec.ClusterReplicas {
+			setCondition(status, redisv1alpha1.ClusterConditionReplicasHealthy, corev1.ConditionTrue, "ReplicasReady", "")
+		} else {
+			setCondition(status, redisv1alpha1.ClusterConditionReplicasHealthy, corev1.ConditionFalse, "ReplicasMissing",
+				fmt.Sprintf("%d masters with at least %d replicas, expected %d masters with %d replicas",
+					status.NumberOfMaster, status.MinReplicationFactor, cluster.Spec.MasterSize, cluster.Spec.ClusterReplicas))
+		}
+	}
+
+	if cluster.Spec.Init == nil {
+		status.RemoveCondition(redisv1alpha1.ClusterConditionRestoreComplete)
+	} else if status.RestoreSucceeded > 0 {
+		setCondition(status, redisv1alpha1.ClusterConditionRestoreComplete, corev1.ConditionTrue, "RestoreSucceeded", "")
+	} else {
+		setCondition(status, redisv1alpha1.ClusterConditionRestoreComplete, corev1.ConditionFalse, "Restoring",
+			fmt.Sprintf("restoring backup %s", cluster.Spec.Init.BackupSource.Name))
+	}
+}
+
+// buildClusterStatus builds the status of the cluster from its nodes, zones are the topology domains of the
+// kubernetes nodes the pods run on.
+func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []corev1.Pod, zones map[string]string,
+	oldStatus *redisv1alpha1.DistributedRedisClusterStatus) *redisv1alpha1.DistributedRedisClusterStatus {
 	status := &redisv1alpha1.DistributedRedisClusterStatus{
-		Status:           oldStatus.Status,
-		Reason:           oldStatus.Reason,
-		RestoreSucceeded: oldStatus.RestoreSucceeded,
+		Status:             oldStatus.Status,
+		Reason:             oldStatus.Reason,
+		RestoreSucceeded:   oldStatus.RestoreSucceeded,
+		ObservedGeneration: oldStatus.ObservedGeneration,
+		VolumeExpansion:    oldStatus.VolumeExpansion.DeepCopy(),
+		Plan:               oldStatus.Plan.DeepCopy(),
+	}
+	for _, c := range oldStatus.Conditions {
+		status.Conditions = append(status.Conditions, *c.DeepCopy())
 	}
 
 	nbMaster := int32(0)
@@ -45,9 +155,13 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []corev1.Pod,
 		newNode := redisv1alpha1.RedisClusterNode{
 			PodName:  pod.Name,
 			NodeName: pod.Spec.NodeName,
+			Zone:     zones[pod.Spec.NodeName],
 			IP:       pod.Status.PodIP,
 			Slots:    []string{},
 		}
+		if owner := metav1.GetControllerOf(&pod); owner != nil {
+			newNode.StatefulSet = owner.Name
+		}
 		redisNodes, err := clusterInfos.GetNodes().GetNodesByFunc(func(node *redisutil.Node) bool {
 			return node.IP == pod.Status.PodIP
 		})
@@ -98,11 +212,44 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []corev1.Pod,
 	}
 	status.MaxReplicationFactor = int32(maxReplicationFactor)
 	status.MinReplicationFactor = int32(minReplicationFactor)
+	status.NodesPlacement = nodesPlacement(status.Nodes)
 
 	return status
 }
 
+// nodesPlacement returns Optimal if the masters are in distinct topology domains and no replica is in the
+// domain of its master. The domain of a node is its zone, or its kubernetes node without topologyKey.
+func nodesPlacement(nodes []redisv1alpha1.RedisClusterNode) redisv1alpha1.NodesPlacementInfo {
+	domainOf := func(node redisv1alpha1.RedisClusterNode) string {
+		if node.Zone != "" {
+			return node.Zone
+		}
+		return node.NodeName
+	}
+	masterDomains := map[string]string{}
+	domainsWithMaster := map[string]bool{}
+	for _, node := range nodes {
+		if node.Role != redisv1alpha1.RedisClusterNodeRoleMaster || len(node.Slots) == 0 {
+			continue
+		}
+		domain := domainOf(node)
+		if domainsWithMaster[domain] {
+			return redisv1alpha1.NodesPlacementInfoBestEffort
+		}
+		domainsWithMaster[domain] = true
+		masterDomains[node.ID] = domain
+	}
+	for _, node := range nodes {
+		if domain, ok := masterDomains[node.MasterRef]; ok && domain == domainOf(node) {
+			return redisv1alpha1.NodesPlacementInfoBestEffort
+		}
+	}
+	return redisv1alpha1.NodesPlacementInfoOptimal
+}
+
 func (r *ReconcileDistributedRedisCluster) updateClusterIfNeed(cluster *redisv1alpha1.DistributedRedisCluster, newStatus *redisv1alpha1.DistributedRedisClusterStatus) {
+	setClusterConditions(cluster, newStatus)
+	metrics.ObserveClusterStatus(cluster.Namespace, cluster.Name, newStatus)
 	if compareStatus(&cluster.Status, newStatus) {
 		log.WithValues("namespace", cluster.Namespace, "name", cluster.Name).
 			V(3).Info("status changed")
@@ -111,6 +258,13 @@ func (r *ReconcileDistributedRedisCluster) updateClusterIfNeed(cluster *redisv1a
 	}
 }
 
+// publishPlan publishes the plan computed in dry-run mode in the status of the cluster.
+func (r *ReconcileDistributedRedisCluster) publishPlan(ctx *syncContext) {
+	new := ctx.cluster.Status.DeepCopy()
+	setClusterPlan(ctx.cluster, new, ctx.plan)
+	r.updateClusterIfNeed(ctx.cluster, new)
+}
+
 func compareStatus(old, new *redisv1alpha1.DistributedRedisClusterStatus) bool {
 	if compareStringValue("ClusterStatus", string(old.Status), string(new.Status)) {
 		return true
@@ -132,6 +286,28 @@ func compareStatus(old, new *redisv1alpha1.DistributedRedisClusterStatus) bool {
 		return true
 	}
 
+	if compareStringValue("NodesPlacement", string(old.NodesPlacement), string(new.NodesPlacement)) {
+		return true
+	}
+
+	if old.ObservedGeneration != new.ObservedGeneration {
+		log.V(4).Info(fmt.Sprintf("compare status.observedGeneration: %d - %d", old.ObservedGeneration, new.ObservedGeneration))
+		return true
+	}
+
+	if compareConditions(old.Conditions, new.Conditions) {
+		return true
+	}
+
+	if compareVolumeExpansion(old.VolumeExpansion, new.VolumeExpansion) {
+		return true
+	}
+
+	if !reflect.DeepEqual(old.Plan, new.Plan) {
+		log.V(4).Info("compare status.plan")
+		return true
+	}
+
 	for _, nodeA := range old.Nodes {
 		found := false
 		for _, nodeB := range new.Nodes {
@@ -150,6 +326,50 @@ func compareStatus(old, new *redisv1alpha1.DistributedRedisClusterStatus) bool {
 	return false
 }
 
+func compareConditions(old, new []redisv1alpha1.ClusterCondition) bool {
+	if compareInts("len(Conditions)", int32(len(old)), int32(len(new))) {
+		return true
+	}
+	for _, conditionA := range old {
+		found := false
+		for _, conditionB := range new {
+			if conditionA.Type != conditionB.Type {
+				continue
+			}
+			found = true
+			name := "Condition." + string(conditionA.Type)
+			if compareStringValue(name+".Status", string(conditionA.Status), string(conditionB.Status)) ||
+				compareStringValue(name+".Reason", conditionA.Reason, conditionB.Reason) ||
+				compareStringValue(name+".Message", conditionA.Message, conditionB.Message) {
+				return true
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
+
+func compareVolumeExpansion(old, new *redisv1alpha1.VolumeExpansionStatus) bool {
+	if old == nil && new == nil {
+		return false
+	} else if old == nil || new == nil {
+		return true
+	}
+	if old.Size.Cmp(new.Size) != 0 {
+		log.V(4).Info(fmt.Sprintf("compare status.volumeExpansion.size: %s - %s", old.Size.String(), new.Size.String()))
+		return true
+	}
+	if compareInts("volumeExpansion.expanded", old.Expanded, new.Expanded) {
+		return true
+	}
+	if compareInts("volumeExpansion.total", old.Total, new.Total) {
+		return true
+	}
+	return !reflect.DeepEqual(old.PendingRestart, new.PendingRestart)
+}
+
 func compareNodes(nodeA, nodeB *redisv1alpha1.RedisClusterNode) bool {
 	if compareStringValue("Node.IP", nodeA.IP, nodeB.IP) {
 		return true
@@ -160,6 +380,12 @@ func compareNodes(nodeA, nodeB *redisv1alpha1.RedisClusterNode) bool {
 	if compareStringValue("Node.PodName", nodeA.PodName, nodeB.PodName) {
 		return true
 	}
+	if compareStringValue("Node.StatefulSet", nodeA.StatefulSet, nodeB.StatefulSet) {
+		return true
+	}
+	if compareStringValue("Node.Zone", nodeA.Zone, nodeB.Zone) {
+		return true
+	}
 	if compareStringValue("Node.Port", nodeA.Port, nodeB.Port) {
 		return true
 	}
@@ -0,0 +1,303 @@
+package distributedrediscluster
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func Test_setCondition(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	ready := func(status corev1.ConditionStatus, reason string) redisv1alpha1.ClusterCondition {
+		return redisv1alpha1.ClusterCondition{
+			Type:               redisv1alpha1.ClusterConditionReady,
+			Status:             status,
+			Reason:             reason,
+			LastTransitionTime: past,
+		}
+	}
+	tests := []struct {
+		name           string
+		conditions     []redisv1alpha1.ClusterCondition
+		status         corev1.ConditionStatus
+		reason         string
+		wantLen        int
+		wantTransition bool
+	}{
+		{
+			name:           "new condition",
+			status:         corev1.ConditionTrue,
+			reason:         "ClusterOK",
+			wantLen:        1,
+			wantTransition: true,
+		},
+		{
+			name:           "same status keeps the transition time",
+			conditions:     []redisv1alpha1.ClusterCondition{ready(corev1.ConditionFalse, "ClusterCreating")},
+			status:         corev1.ConditionFalse,
+			reason:         "ClusterScaling",
+			wantLen:        1,
+			wantTransition: false,
+		},
+		{
+			name:           "status change sets the transition time",
+			conditions:     []redisv1alpha1.ClusterCondition{ready(corev1.ConditionFalse, "ClusterScaling")},
+			status:         corev1.ConditionTrue,
+			reason:         "ClusterOK",
+			wantLen:        1,
+			wantTransition: true,
+		},
+		{
+			name: "other conditions are kept",
+			conditions: []redisv1alpha1.ClusterCondition{
+				{Type: redisv1alpha1.ClusterConditionScaling, Status: corev1.ConditionTrue, LastTransitionTime: past},
+				ready(corev1.ConditionTrue, "ClusterOK"),
+			},
+			status:         corev1.ConditionTrue,
+			reason:         "ClusterOK",
+			wantLen:        2,
+			wantTransition: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &redisv1alpha1.DistributedRedisClusterStatus{Conditions: tt.conditions}
+			setCondition(status, redisv1alpha1.ClusterConditionReady, tt.status, tt.reason, "")
+			if len(status.Conditions) != tt.wantLen {
+				t.Fatalf("setCondition() conditions = %v, want %d conditions", status.Conditions, tt.wantLen)
+			}
+			got := status.GetCondition(redisv1alpha1.ClusterConditionReady)
+			if got == nil {
+				t.Fatalf("setCondition() condition %s not set", redisv1alpha1.ClusterConditionReady)
+			}
+			if got.Status != tt.status || got.Reason != tt.reason {
+				t.Errorf("setCondition() condition = %v, want status %s and reason %s", got, tt.status, tt.reason)
+			}
+			if transition := !got.LastTransitionTime.Equal(&past); transition != tt.wantTransition {
+				t.Errorf("setCondition() lastTransitionTime = %v, transition %v, want %v", got.LastTransitionTime, transition, tt.wantTransition)
+			}
+		})
+	}
+}
+
+func Test_compareConditions(t *testing.T) {
+	condition := func(conditionType redisv1alpha1.ClusterConditionType, status corev1.ConditionStatus, reason, message string) redisv1alpha1.ClusterCondition {
+		return redisv1alpha1.ClusterCondition{
+			Type:               conditionType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: metav1.Now(),
+		}
+	}
+	ready := condition(redisv1alpha1.ClusterConditionReady, corev1.ConditionTrue, "ClusterOK", "")
+	scaling := condition(redisv1alpha1.ClusterConditionScaling, corev1.ConditionFalse, "ScalingComplete", "")
+	tests := []struct {
+		name string
+		old  []redisv1alpha1.ClusterCondition
+		new  []redisv1alpha1.ClusterCondition
+		want bool
+	}{
+		{
+			name: "no conditions",
+			want: false,
+		},
+		{
+			name: "same conditions",
+			old:  []redisv1alpha1.ClusterCondition{ready, scaling},
+			new:  []redisv1alpha1.ClusterCondition{ready, scaling},
+			want: false,
+		},
+		{
+			name: "order is ignored",
+			old:  []redisv1alpha1.ClusterCondition{ready, scaling},
+			new:  []redisv1alpha1.ClusterCondition{scaling, ready},
+			want: false,
+		},
+		{
+			name: "transition time is ignored",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new: []redisv1alpha1.ClusterCondition{
+				{Type: ready.Type, Status: ready.Status, Reason: ready.Reason, LastTransitionTime: metav1.NewTime(time.Unix(0, 0))},
+			},
+			want: false,
+		},
+		{
+			name: "condition added",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new:  []redisv1alpha1.ClusterCondition{ready, scaling},
+			want: true,
+		},
+		{
+			name: "condition replaced",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new:  []redisv1alpha1.ClusterCondition{scaling},
+			want: true,
+		},
+		{
+			name: "status changed",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new:  []redisv1alpha1.ClusterCondition{condition(ready.Type, corev1.ConditionFalse, ready.Reason, "")},
+			want: true,
+		},
+		{
+			name: "reason changed",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new:  []redisv1alpha1.ClusterCondition{condition(ready.Type, ready.Status, "ClusterCreating", "")},
+			want: true,
+		},
+		{
+			name: "message changed",
+			old:  []redisv1alpha1.ClusterCondition{ready},
+			new:  []redisv1alpha1.ClusterCondition{condition(ready.Type, ready.Status, ready.Reason, "scaling")},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareConditions(tt.old, tt.new); got != tt.want {
+				t.Errorf("compareConditions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setClusterConditions(t *testing.T) {
+	masterNodes := []redisv1alpha1.RedisClusterNode{
+		{ID: "1", Role: redisv1alpha1.RedisClusterNodeRoleMaster, Slots: []string{"0-8191"}},
+		{ID: "2", Role: redisv1alpha1.RedisClusterNodeRoleMaster, Slots: []string{"8192-16383"}},
+	}
+	tests := []struct {
+		name           string
+		generation     int64
+		dryRun         bool
+		status         redisv1alpha1.DistributedRedisClusterStatus
+		want           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus
+		wantGeneration int64
+		wantUpdate     bool
+	}{
+		{
+			name:       "observed generation unchanged",
+			generation: 2,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration: 2,
+			},
+			want:           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{},
+			wantGeneration: 2,
+			wantUpdate:     false,
+		},
+		{
+			name:       "new generation of the spec",
+			generation: 3,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration: 2,
+			},
+			want:           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{},
+			wantGeneration: 3,
+			wantUpdate:     true,
+		},
+		{
+			name:       "slots covered and replicas healthy",
+			generation: 1,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration:   1,
+				NumberOfMaster:       2,
+				MinReplicationFactor: 1,
+				Nodes:                masterNodes,
+			},
+			want: map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{
+				redisv1alpha1.ClusterConditionSlotsCovered:    corev1.ConditionTrue,
+				redisv1alpha1.ClusterConditionReplicasHealthy: corev1.ConditionTrue,
+			},
+			wantGeneration: 1,
+			wantUpdate:     true,
+		},
+		{
+			name:       "slots unassigned and replicas missing",
+			generation: 1,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration:   1,
+				NumberOfMaster:       1,
+				MinReplicationFactor: 0,
+				Nodes:                masterNodes[:1],
+			},
+			want: map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{
+				redisv1alpha1.ClusterConditionSlotsCovered:    corev1.ConditionFalse,
+				redisv1alpha1.ClusterConditionReplicasHealthy: corev1.ConditionFalse,
+			},
+			wantGeneration: 1,
+			wantUpdate:     true,
+		},
+		{
+			name:       "changes planned in dry-run mode",
+			generation: 3,
+			dryRun:     true,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration: 2,
+				Plan:               &redisv1alpha1.ClusterPlan{ObservedGeneration: 3, Steps: []string{"create StatefulSet drc-test-2"}},
+			},
+			want:           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{},
+			wantGeneration: 2,
+			wantUpdate:     false,
+		},
+		{
+			name:       "plan of a previous generation",
+			generation: 3,
+			dryRun:     true,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration: 2,
+				Plan:               &redisv1alpha1.ClusterPlan{ObservedGeneration: 2},
+			},
+			want:           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{},
+			wantGeneration: 2,
+			wantUpdate:     false,
+		},
+		{
+			name:       "nothing planned in dry-run mode",
+			generation: 3,
+			dryRun:     true,
+			status: redisv1alpha1.DistributedRedisClusterStatus{
+				ObservedGeneration: 2,
+				Plan:               &redisv1alpha1.ClusterPlan{ObservedGeneration: 3},
+			},
+			want:           map[redisv1alpha1.ClusterConditionType]corev1.ConditionStatus{},
+			wantGeneration: 3,
+			wantUpdate:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &redisv1alpha1.DistributedRedisCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Generation: tt.generation},
+				Spec:       redisv1alpha1.DistributedRedisClusterSpec{MasterSize: 2, ClusterReplicas: 1, DryRun: tt.dryRun},
+			}
+			old := tt.status.DeepCopy()
+			status := tt.status.DeepCopy()
+			setClusterConditions(cluster, status)
+			if status.ObservedGeneration != tt.wantGeneration {
+				t.Errorf("setClusterConditions() observedGeneration = %d, want %d", status.ObservedGeneration, tt.wantGeneration)
+			}
+			if len(status.Conditions) != len(tt.want) {
+				t.Errorf("setClusterConditions() conditions = %v, want %v", status.Conditions, tt.want)
+			}
+			for conditionType, want := range tt.want {
+				if got := status.GetCondition(conditionType); got == nil || got.Status != want {
+					t.Errorf("setClusterConditions() condition %s = %v, want %s", conditionType, got, want)
+				}
+			}
+			if got := compareStatus(old, status); got != tt.wantUpdate {
+				t.Errorf("compareStatus() = %v, want %v", got, tt.wantUpdate)
+			}
+			// the conditions of an unchanged cluster don't trigger another update
+			again := status.DeepCopy()
+			setClusterConditions(cluster, again)
+			if compareStatus(status, again) {
+				t.Errorf("compareStatus() = true for the same cluster, want false")
+			}
+		})
+	}
+}
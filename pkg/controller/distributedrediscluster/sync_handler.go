@@ -2,21 +2,32 @@ package distributedrediscluster
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/clustering"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/manager"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/monitors"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 )
 
 const (
 	requeueAfter  = 10 * time.Second
 	requeueEnsure = 60 * time.Second
+	// passwordGracePeriod is the time the previous password is still accepted after a rotation, for the clients to
+	// switch to the new one
+	passwordGracePeriod = 5 * time.Minute
 )
 
 type syncContext struct {
@@ -26,6 +37,8 @@ type syncContext struct {
 	healer       manager.IHeal
 	pods         []*corev1.Pod
 	reqLogger    logr.Logger
+	// plan records the changes computed in dry-run mode, it is nil otherwise
+	plan *redisutil.Plan
 }
 
 func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error {
@@ -36,6 +49,11 @@ func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error
 		}
 		return StopRetry.Wrap(err, "stop retry")
 	}
+	ensurer := r.ensurer
+	if cluster.Spec.DryRun {
+		// the changes of the kubernetes resources are only planned
+		ensurer = manager.NewEnsureResource(&planClient{Client: r.client, plan: ctx.plan}, r.watchNamespace, ctx.reqLogger)
+	}
 	labels := getLabels(cluster)
 	var backup *redisv1alpha1.RedisClusterBackup
 	var err error
@@ -45,16 +63,34 @@ func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error
 			return err
 		}
 	}
-	if err := r.ensurer.EnsureRedisConfigMap(cluster, labels); err != nil {
+	if err := ensurer.EnsureRedisConfigMap(cluster, labels); err != nil {
 		return Kubernetes.Wrap(err, "EnsureRedisConfigMap")
 	}
-	if err := r.ensurer.EnsureRedisStatefulset(cluster, backup, labels); err != nil {
-		return Kubernetes.Wrap(err, "EnsureRedisStatefulset")
+	if err := ensurer.EnsureRedisCertificate(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisCertificate")
+	}
+	if err := ensurer.EnsureRedisACLSecret(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisACLSecret")
+	}
+	if err := ensurer.EnsureRedisStatefulsets(cluster, backup, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisStatefulsets")
 	}
-	if err := r.ensurer.EnsureRedisHeadLessSvc(cluster, labels); err != nil {
+	if err := ensurer.EnsureRedisHeadLessSvc(cluster, labels); err != nil {
 		return Kubernetes.Wrap(err, "EnsureRedisHeadLessSvc")
 	}
-	if err := r.ensurer.EnsureRedisOSMSecret(cluster, backup, labels); err != nil {
+	if err := ensurer.EnsureRedisSvc(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisSvc")
+	}
+	if err := ensurer.EnsureRedisPodSvcs(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisPodSvcs")
+	}
+	if err := r.ensureMonitorFinalizer(cluster); err != nil {
+		return Kubernetes.Wrap(err, "ensureMonitorFinalizer")
+	}
+	if err := ensurer.EnsureRedisMonitor(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisMonitor")
+	}
+	if err := ensurer.EnsureRedisOSMSecret(cluster, backup, labels); err != nil {
 		if k8sutil.IsRequestRetryable(err) {
 			return Kubernetes.Wrap(err, "EnsureRedisOSMSecret")
 		}
@@ -68,7 +104,17 @@ func (r *ReconcileDistributedRedisCluster) waitPodReady(ctx *syncContext) error
 		return Kubernetes.Wrap(err, "FixTerminatingPods")
 	}
 	if err := r.checker.CheckRedisNodeNum(ctx.cluster); err != nil {
-		return Requeue.Wrap(err, "CheckRedisNodeNum")
+		if !ctx.cluster.Spec.DryRun {
+			return Requeue.Wrap(err, "CheckRedisNodeNum")
+		}
+		// the planned statefulSets are not created, their nodes are planned from the spec
+		ctx.reqLogger.Info("planning with the running pods", "reason", err.Error())
+	}
+	// wait for the pods removed by a scale down to disappear
+	for _, pod := range ctx.pods {
+		if pod.DeletionTimestamp != nil {
+			return Requeue.Wrap(fmt.Errorf("pod %s is terminating", pod.Name), "waitPodReady")
+		}
 	}
 
 	return nil
@@ -98,6 +144,30 @@ func (r *ReconcileDistributedRedisCluster) validate(cluster *redisv1alpha1.Distr
 		}
 	}
 	cluster.Validate()
+	if mon := cluster.Spec.Monitor; mon != nil {
+		switch mon.Prometheus.MonitorKind {
+		case redisv1alpha1.MonitorKindServiceMonitor, redisv1alpha1.MonitorKindPodMonitor:
+		default:
+			return fmt.Errorf("unknown monitorKind %q, must be %s or %s", mon.Prometheus.MonitorKind,
+				redisv1alpha1.MonitorKindServiceMonitor, redisv1alpha1.MonitorKindPodMonitor)
+		}
+		// the Role of a namespace-scoped operator only grants access to the monitors of its namespace
+		if ns := monitors.Namespace(cluster); r.watchNamespace != "" && ns != cluster.Namespace {
+			return fmt.Errorf("monitor namespace %s requires the operator to be cluster-scoped", ns)
+		}
+	}
+	// the nodes are cluster-scoped, the Role of a namespace-scoped operator can't read their labels
+	if cluster.Spec.TopologyKey != "" && r.watchNamespace != "" {
+		return fmt.Errorf("topologyKey requires the operator to be cluster-scoped")
+	}
+	if tls := cluster.Spec.TLS; tls != nil && tls.SecretName == "" && tls.IssuerRef == nil {
+		return fmt.Errorf("tls requires a secretName or an issuerRef")
+	}
+	for _, user := range cluster.Spec.Users {
+		if redisv1alpha1.IsReservedUserName(user.Name) {
+			return fmt.Errorf("user %s is reserved", user.Name)
+		}
+	}
 	return nil
 }
 
@@ -121,6 +191,10 @@ func (r *ReconcileDistributedRedisCluster) waitForClusterJoin(ctx *syncContext)
 	if err != nil {
 		return Redis.Wrap(err, "AttachNodeToCluster")
 	}
+	if ctx.cluster.Spec.DryRun {
+		// the nodes don't meet in dry-run mode, the plan goes on with their current view
+		return nil
+	}
 	// Give one second for the join to start, in order to avoid that
 	// waiting for cluster join will find all the nodes agree about
 	// the config as they are still empty with unassigned slots.
@@ -290,6 +364,21 @@ func (r *ReconcileDistributedRedisCluster) sync(ctx *syncContext) error {
 	if err != nil {
 		return Cluster.Wrap(err, "newRedisCluster")
 	}
+	if cluster.Spec.DryRun {
+		for _, node := range plannedNodes(cluster, ctx.pods) {
+			rCluster.Nodes[node.ID] = node
+			nodes = append(nodes, node)
+		}
+	}
+
+	expectPodNum := cNbMaster * (cReplicaFactor + 1)
+	if int32(len(ctx.pods)) > expectPodNum {
+		ctx.reqLogger.Info("Scaling down cluster", "pods", len(ctx.pods), "expect", expectPodNum)
+		new := cluster.Status.DeepCopy()
+		SetClusterScaling(new, "scaling down")
+		r.updateClusterIfNeed(cluster, new)
+		return r.scalingDown(ctx, rCluster, nodes)
+	}
 
 	//currentMasterNodes := nodes.FilterByFunc(redisutil.IsMasterWithSlot)
 	//if len(currentMasterNodes) == int(cluster.Spec.MasterSize) {
@@ -347,10 +436,11 @@ func (r *ReconcileDistributedRedisCluster) sync(ctx *syncContext) error {
 			return Cluster.Wrap(err, "AttachingSlavesToMaster")
 		}
 
-		if err := clustering.RebalancedCluster(admin, newMasters); err != nil {
+		if err := clustering.RebalancedCluster(admin, newMasters, nil, rebalanceOptions(cluster)); err != nil {
 			return Cluster.Wrap(err, "RebalancedCluster")
 		}
-	} else if cluster.Status.MinReplicationFactor < cluster.Spec.ClusterReplicas {
+	} else if cluster.Status.MinReplicationFactor < cluster.Spec.ClusterReplicas ||
+		cluster.Status.MaxReplicationFactor > cluster.Spec.ClusterReplicas {
 		newRedisSlavesByMaster, bestEffort := clustering.PlaceSlaves(rCluster, newMasters, currentSlaveNodes, newSlave, cReplicaFactor)
 		if bestEffort {
 			rCluster.NodesPlacement = redisv1alpha1.NodesPlacementInfoBestEffort
@@ -363,3 +453,472 @@ func (r *ReconcileDistributedRedisCluster) sync(ctx *syncContext) error {
 
 	return nil
 }
+
+// scalingDown moves every slot off the redis nodes running on the pods that will be removed,
+// forgets them and only then shrinks or deletes their statefulSets. It also migrates the clusters
+// created with a single statefulSet, whose nodes are all removed.
+func (r *ReconcileDistributedRedisCluster) scalingDown(ctx *syncContext, rCluster *redisutil.Cluster, nodes redisutil.Nodes) error {
+	cluster := ctx.cluster
+	admin := ctx.admin
+	removedNodes, keptNodes := splitNodesByShard(cluster, nodes)
+	ctx.reqLogger.Info("scaling down", "removedNodes", removedNodes, "keptNodes", keptNodes)
+	// the statefulSets always remove their highest ordinals, wait for a failing node to recover rather
+	// than keep it and remove the healthy nodes of its shard.
+	if err := checkKeptNodesHealthy(removedNodes, keptNodes); err != nil {
+		return Requeue.Wrap(err, "checkKeptNodesHealthy")
+	}
+
+	// the statefulSets always remove their highest ordinals, hand the slots of the removed masters over
+	// to the kept slave chosen by placement, so no slot has to be migrated when only the replicas are
+	// scaled down and the slaves left behind stay spread on the VMs.
+	for _, master := range removedNodes.FilterByFunc(redisutil.IsMasterWithSlot) {
+		slaves := keptNodes.FilterByFunc(func(node *redisutil.Node) bool {
+			return redisutil.IsSlave(node) && node.MasterReferent == master.ID
+		})
+		slave := clustering.SelectSlaveToPromote(rCluster, slaves)
+		if slave == nil {
+			continue
+		}
+		ctx.reqLogger.Info("failover", "master", master.IPPort(), "slave", slave.IPPort())
+		if err := admin.StartFailover(slave.IPPort()); err != nil {
+			return Redis.Wrap(err, "StartFailover")
+		}
+		slave.SetReferentMaster("")
+		slave.SetRole(redisutil.RedisMasterRole)
+		slave.Slots, master.Slots = master.Slots, []redisutil.Slot{}
+		master.SetReferentMaster(slave.ID)
+		master.SetRole(redisutil.RedisSlaveRole)
+	}
+
+	// the kept nodes need enough masters to take over the slots, promote slaves
+	// of the removed masters first.
+	keptMasterNum := int32(len(keptNodes.FilterByFunc(redisutil.IsMasterWithSlot)) + len(keptNodes.FilterByFunc(redisutil.IsMasterWithNoSlot)))
+	if keptMasterNum < cluster.Spec.MasterSize {
+		keptSlaves := keptNodes.FilterByFunc(redisutil.IsSlave).SortByFunc(func(a, b *redisutil.Node) bool {
+			_, errA := removedNodes.GetNodeByID(a.MasterReferent)
+			_, errB := removedNodes.GetNodeByID(b.MasterReferent)
+			return errA == nil && errB != nil
+		})
+		for _, slave := range keptSlaves {
+			if keptMasterNum >= cluster.Spec.MasterSize {
+				break
+			}
+			ctx.reqLogger.Info("detach slave", "slave", slave.IPPort(), "master", slave.MasterReferent)
+			if err := admin.DetachSlave(slave); err != nil {
+				return Redis.Wrap(err, "DetachSlave")
+			}
+			keptMasterNum++
+		}
+	}
+
+	newMasters, curMasters, _, err := clustering.DispatchMasters(rCluster, keptNodes, cluster.Spec.MasterSize)
+	if err != nil {
+		return Cluster.Wrap(err, "DispatchMasters")
+	}
+	drainedMasters := nodes.FilterByFunc(func(node *redisutil.Node) bool {
+		if !redisutil.IsMasterWithSlot(node) {
+			return false
+		}
+		_, err := newMasters.GetNodeByID(node.ID)
+		return err != nil
+	})
+	ctx.reqLogger.V(4).Info("DispatchMasters Info", "newMasters", newMasters, "curMasters", curMasters, "drainedMasters", drainedMasters)
+	if len(drainedMasters) > 0 {
+		if err := clustering.RebalancedCluster(admin, newMasters, drainedMasters, rebalanceOptions(cluster)); err != nil {
+			return Cluster.Wrap(err, "RebalancedCluster")
+		}
+	}
+
+	// re-pair the kept nodes, the slaves are spread on the VMs the same way as when the cluster is created.
+	oldSlaves := keptNodes.FilterByFunc(func(node *redisutil.Node) bool {
+		if !redisutil.IsSlave(node) {
+			return false
+		}
+		_, err := newMasters.GetNodeByID(node.MasterReferent)
+		return err == nil
+	})
+	newSlaves := keptNodes.FilterByFunc(func(node *redisutil.Node) bool {
+		if _, err := newMasters.GetNodeByID(node.ID); err == nil {
+			return false
+		}
+		_, err := oldSlaves.GetNodeByID(node.ID)
+		return err != nil
+	})
+	newRedisSlavesByMaster, bestEffort := clustering.PlaceSlaves(rCluster, newMasters, oldSlaves, newSlaves, cluster.Spec.ClusterReplicas)
+	if bestEffort {
+		rCluster.NodesPlacement = redisv1alpha1.NodesPlacementInfoBestEffort
+	}
+	if err := clustering.AttachingSlavesToMaster(rCluster, admin, newRedisSlavesByMaster); err != nil {
+		return Cluster.Wrap(err, "AttachingSlavesToMaster")
+	}
+
+	// the removed nodes own no slot now, detach them from their master and reset them so that they
+	// don't gossip their view of the cluster, then make the rest of the cluster forget them. Their
+	// pods are only removed once every kept node has forgotten them.
+	for _, node := range removedNodes {
+		if node.HasStatus(redisutil.NodeStatusFail) {
+			ctx.reqLogger.Info("removed node is failing, it is only forgotten", "node", node.IPPort(), "pod", node.PodName)
+			admin.Connections().Remove(node.IPPort())
+			continue
+		}
+		ctx.reqLogger.Info("detach node", "node", node.IPPort(), "pod", node.PodName)
+		if err := admin.ResetNode(node.IPPort(), redisutil.ResetHard); err != nil {
+			return Redis.Wrap(err, "ResetNode")
+		}
+		admin.Connections().Remove(node.IPPort())
+	}
+	for _, node := range removedNodes {
+		ctx.reqLogger.Info("forget node", "node", node.ID, "pod", node.PodName)
+		if err := admin.ForgetNode(node.ID); err != nil {
+			return Redis.Wrap(err, "ForgetNode")
+		}
+	}
+	if !cluster.Spec.DryRun {
+		if err := checkNodesForgotten(admin, removedNodes); err != nil {
+			return Requeue.Wrap(err, "checkNodesForgotten")
+		}
+	}
+
+	return r.shrinkStatefulSets(cluster, ctx.plan)
+}
+
+// shrinkStatefulSets deletes the statefulSets which are not a shard of the cluster anymore and
+// scales the others down to spec.clusterReplicas+1. The changes are only added to the plan in dry-run mode.
+func (r *ReconcileDistributedRedisCluster) shrinkStatefulSets(cluster *redisv1alpha1.DistributedRedisCluster, plan *redisutil.Plan) error {
+	shards := map[string]bool{}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		shards[statefulsets.ClusterStatefulSetName(cluster.Name, i)] = true
+	}
+	ssList, err := r.statefulSetController.ListStatefulSetByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
+	if err != nil {
+		return Kubernetes.Wrap(err, "ListStatefulSetByLabels")
+	}
+	size := cluster.Spec.ClusterReplicas + 1
+	for i := range ssList.Items {
+		ss := &ssList.Items[i]
+		if !shards[ss.Name] {
+			if cluster.Spec.DryRun {
+				plan.Add("delete statefulSet %s", ss.Name)
+				continue
+			}
+			log.Info("deleting statefulSet", "StatefulSet.Namespace", ss.Namespace, "StatefulSet.Name", ss.Name)
+			if err := r.statefulSetController.DeleteStatefulSet(ss); err != nil {
+				return Kubernetes.Wrap(err, "DeleteStatefulSet")
+			}
+			continue
+		}
+		if *ss.Spec.Replicas > size {
+			if cluster.Spec.DryRun {
+				plan.Add("scale statefulSet %s down to %d replicas", ss.Name, size)
+				continue
+			}
+			ss.Spec.Replicas = &size
+			if err := r.statefulSetController.UpdateStatefulSet(ss); err != nil {
+				return Kubernetes.Wrap(err, "UpdateStatefulSet")
+			}
+		}
+	}
+	return nil
+}
+
+// rollingUpdate restarts one of the pods running an outdated template. Slaves are restarted first, then
+// every master is failed over to one of its slaves and restarted as a slave.
+func (r *ReconcileDistributedRedisCluster) rollingUpdate(ctx *syncContext) error {
+	cluster := ctx.cluster
+	ssList, err := r.statefulSetController.ListStatefulSetByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
+	if err != nil {
+		return Kubernetes.Wrap(err, "ListStatefulSetByLabels")
+	}
+	updateRevisions := map[string]string{}
+	for _, ss := range ssList.Items {
+		updateRevisions[ss.Name] = ss.Status.UpdateRevision
+	}
+	var outdatedPods []*corev1.Pod
+	for _, pod := range ctx.pods {
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || updateRevisions[owner.Name] == "" {
+			continue
+		}
+		if pod.Labels[appsv1.StatefulSetRevisionLabel] != updateRevisions[owner.Name] {
+			outdatedPods = append(outdatedPods, pod)
+		}
+	}
+	if len(outdatedPods) == 0 {
+		return nil
+	}
+	ctx.reqLogger.Info("rolling update", "outdatedPods", len(outdatedPods))
+	return r.restartPods(ctx, outdatedPods, "rollingUpdate")
+}
+
+// restartPods restarts one of the given pods. Slaves are restarted first, then every master is failed over
+// to its slave in sync with the highest replication offset, and restarted as a slave by a next pass. A Requeue
+// error is returned when a pod is restarted or a master is failed over.
+func (r *ReconcileDistributedRedisCluster) restartPods(ctx *syncContext, pods []*corev1.Pod, operation string) error {
+	cluster := ctx.cluster
+	admin := ctx.admin
+	if cluster.Spec.DryRun {
+		for _, pod := range pods {
+			ctx.plan.Add("%s: restart pod %s", operation, pod.Name)
+		}
+		return nil
+	}
+	if ctx.clusterInfos.Status != redisutil.ClusterInfosConsistent {
+		return Requeue.Wrap(fmt.Errorf("cluster view is %s", ctx.clusterInfos.Status), operation)
+	}
+
+	rCluster, nodes, err := newRedisCluster(ctx.clusterInfos, cluster)
+	if err != nil {
+		return Cluster.Wrap(err, "newRedisCluster")
+	}
+
+	for _, pod := range pods {
+		node, err := rCluster.GetNodeByPodName(pod.Name)
+		if err == nil && !redisutil.IsSlave(node) {
+			continue
+		}
+		ctx.reqLogger.Info("restart slave pod", "pod", pod.Name)
+		if err := r.podController.DeletePod(pod); err != nil {
+			return Kubernetes.Wrap(err, "DeletePod")
+		}
+		return Requeue.Wrap(fmt.Errorf("restarting slave pod %s", pod.Name), operation)
+	}
+
+	for _, pod := range pods {
+		master, err := rCluster.GetNodeByPodName(pod.Name)
+		if err != nil {
+			continue
+		}
+		slaves := nodes.FilterByFunc(func(node *redisutil.Node) bool {
+			return redisutil.IsSlave(node) && node.MasterReferent == master.ID
+		})
+		if len(slaves) > 0 {
+			slave := selectSyncedSlave(admin, slaves)
+			if slave == nil {
+				return Requeue.Wrap(fmt.Errorf("no slave of master %s is in sync", master.IPPort()), operation)
+			}
+			// the failover is not waited for, the pod is restarted as a slave by a next pass
+			ctx.reqLogger.Info("failover", "master", master.IPPort(), "slave", slave.IPPort())
+			if err := admin.Failover(slave.IPPort()); err != nil {
+				return Redis.Wrap(err, "Failover")
+			}
+			return Requeue.Wrap(fmt.Errorf("failing over master pod %s", pod.Name), operation)
+		}
+		ctx.reqLogger.Info("master has no slave, its slots are unavailable until the pod is restarted", "pod", pod.Name)
+		ctx.reqLogger.Info("restart master pod", "pod", pod.Name)
+		if err := r.podController.DeletePod(pod); err != nil {
+			return Kubernetes.Wrap(err, "DeletePod")
+		}
+		return Requeue.Wrap(fmt.Errorf("restarting master pod %s", pod.Name), operation)
+	}
+	return nil
+}
+
+// expandVolumes expands the data volumes of the redis pods up to spec.storage.size. The volumes whose
+// filesystem is only resized when their pod restarts, the storage class doesn't support online expansion,
+// are restarted like in a rolling update. The progress is reported in status.volumeExpansion.
+func (r *ReconcileDistributedRedisCluster) expandVolumes(ctx *syncContext) error {
+	cluster := ctx.cluster
+	storage := cluster.Spec.Storage
+	if storage == nil || storage.Type != redisv1alpha1.PersistentClaim {
+		return nil
+	}
+	pvcList, err := r.pvcController.ListPvcByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
+	if err != nil {
+		return Kubernetes.Wrap(err, "ListPvcByLabels")
+	}
+
+	pods := map[string]*corev1.Pod{}
+	for _, pod := range ctx.pods {
+		pods[pod.Name] = pod
+	}
+	progress := &redisv1alpha1.VolumeExpansionStatus{Size: storage.Size}
+	var pendingRestart []*corev1.Pod
+	var notExpandable []string
+	expandable := map[string]bool{}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		podName := strings.TrimPrefix(pvc.Name, statefulsets.DataClaimName(""))
+		// the claims retained by the removed shards are not expanded
+		if _, ok := pods[podName]; !ok {
+			continue
+		}
+		progress.Total++
+		request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if request.Cmp(storage.Size) < 0 {
+			class := ""
+			if pvc.Spec.StorageClassName != nil {
+				class = *pvc.Spec.StorageClassName
+			}
+			allowed, ok := expandable[class]
+			if !ok {
+				if allowed, err = r.volumeExpansionAllowed(class); err != nil {
+					return Kubernetes.Wrap(err, "GetStorageClass")
+				}
+				expandable[class] = allowed
+			}
+			if !allowed {
+				// the apiserver would reject the new size of the claim
+				notExpandable = append(notExpandable, class)
+				continue
+			}
+			if cluster.Spec.DryRun {
+				ctx.plan.Add("expand volume %s from %s to %s", pvc.Name, request.String(), storage.Size.String())
+				continue
+			}
+			ctx.reqLogger.Info("expanding volume", "pvc", pvc.Name, "from", request.String(), "to", storage.Size.String())
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = storage.Size
+			if err := r.pvcController.UpdatePvc(pvc); err != nil {
+				return Kubernetes.Wrap(err, "UpdatePvc")
+			}
+			continue
+		}
+		capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+		if capacity.Cmp(storage.Size) >= 0 {
+			progress.Expanded++
+			continue
+		}
+		for _, condition := range pvc.Status.Conditions {
+			if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+				pendingRestart = append(pendingRestart, pods[podName])
+				progress.PendingRestart = append(progress.PendingRestart, podName)
+			}
+		}
+	}
+
+	if cluster.Spec.DryRun {
+		// the progress of a plan is not reported
+		return r.restartPods(ctx, pendingRestart, "expandVolumes")
+	}
+
+	status := cluster.Status.DeepCopy()
+	if len(notExpandable) > 0 {
+		status.VolumeExpansion = progress
+		setCondition(status, redisv1alpha1.ClusterConditionResizing, corev1.ConditionFalse, "ExpansionNotAllowed",
+			fmt.Sprintf("%d volumes can't be expanded to %s, the storage class %q doesn't allow volume expansion",
+				len(notExpandable), storage.Size.String(), notExpandable[0]))
+		r.updateClusterIfNeed(cluster, status)
+		return nil
+	}
+	if progress.Expanded == progress.Total {
+		if status.VolumeExpansion == nil {
+			// no volume was ever expanded
+			return nil
+		}
+		status.VolumeExpansion = progress
+		setCondition(status, redisv1alpha1.ClusterConditionResizing, corev1.ConditionFalse, "VolumesExpanded",
+			fmt.Sprintf("%d volumes expanded to %s", progress.Total, storage.Size.String()))
+		r.updateClusterIfNeed(cluster, status)
+		return nil
+	}
+	status.VolumeExpansion = progress
+	setCondition(status, redisv1alpha1.ClusterConditionResizing, corev1.ConditionTrue, "ExpandingVolumes",
+		fmt.Sprintf("%d of %d volumes expanded to %s", progress.Expanded, progress.Total, storage.Size.String()))
+	r.updateClusterIfNeed(cluster, status)
+
+	if len(pendingRestart) > 0 {
+		if err := r.restartPods(ctx, pendingRestart, "expandVolumes"); err != nil {
+			return err
+		}
+	}
+	return Requeue.Newf("%d of %d volumes expanded", progress.Expanded, progress.Total)
+}
+
+// volumeExpansionAllowed returns true if the claims of the storage class can be expanded. The claims of the default
+// storage class, and those of a storage class the operator is not allowed to read, are left to the apiserver.
+func (r *ReconcileDistributedRedisCluster) volumeExpansionAllowed(class string) (bool, error) {
+	if class == "" {
+		return true, nil
+	}
+	storageClass, err := r.storageClassController.GetStorageClass(class)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if errors.IsForbidden(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// syncACLUsers applies spec.users to every node, so that the nodes added or replaced get the users too.
+func (r *ReconcileDistributedRedisCluster) syncACLUsers(cluster *redisv1alpha1.DistributedRedisCluster, admin redisutil.IAdmin) error {
+	if !cluster.IsACLEnabled() {
+		return nil
+	}
+	users, err := getACLUsers(r.client, cluster)
+	if err != nil {
+		return Kubernetes.Wrap(err, "getACLUsers")
+	}
+	return admin.SetACLUsers(users, []string{redisv1alpha1.ACLOperatorUser})
+}
+
+// rotatePassword adds the password of spec.rootPasswordSecret to the running nodes, then records it as applied. The
+// nodes keep accepting the previous password until the end of the grace period, it is removed by a later reconcile.
+// The restarted pods read the password from the Secret, so they agree with the running ones.
+func (r *ReconcileDistributedRedisCluster) rotatePassword(ctx *syncContext, admin redisutil.IAdmin, auth *redisadmin.Auth) error {
+	if !auth.Applied {
+		// the nodes were started with the current password
+		if ctx.cluster.Spec.DryRun {
+			return nil
+		}
+		if err := saveAppliedPassword(r.client, ctx.cluster, auth.Password, ""); err != nil {
+			return Kubernetes.Wrap(err, "saveAppliedPassword")
+		}
+		return nil
+	}
+	if auth.Rotating() {
+		// a node left with the old password could no longer be reached once the rotation is recorded
+		if nbConnected := len(admin.Connections().GetAll()); nbConnected != len(ctx.pods) {
+			return Requeue.Newf("%d of %d redis nodes connected, wait to rotate the password", nbConnected, len(ctx.pods))
+		}
+		ctx.reqLogger.Info("rotating the password of the redis nodes")
+		if auth.PreviousPassword != "" && auth.PreviousPassword != auth.Password {
+			// only the applied password and the new one are accepted
+			if err := admin.RemovePassword(auth.PreviousPassword); err != nil {
+				return Redis.Wrap(err, "RemovePassword")
+			}
+		}
+		if err := admin.SetPassword(auth.Password); err != nil {
+			return Redis.Wrap(err, "SetPassword")
+		}
+		if ctx.cluster.Spec.DryRun {
+			// the password is recorded once it is applied
+			return nil
+		}
+		if err := saveAppliedPassword(r.client, ctx.cluster, auth.Password, auth.AppliedPassword); err != nil {
+			return Kubernetes.Wrap(err, "saveAppliedPassword")
+		}
+		return nil
+	}
+	if auth.PreviousPassword == "" || time.Since(auth.RotatedAt) < passwordGracePeriod {
+		return nil
+	}
+	// the nodes not connected would keep the previous password, it is removed by a later reconcile
+	if nbConnected := len(admin.Connections().GetAll()); nbConnected != len(ctx.pods) {
+		ctx.reqLogger.Info("waiting for every redis node to remove the previous password",
+			"connected", nbConnected, "nodes", len(ctx.pods))
+		return nil
+	}
+	ctx.reqLogger.Info("removing the previous password of the redis nodes")
+	if err := admin.RemovePassword(auth.PreviousPassword); err != nil {
+		return Redis.Wrap(err, "RemovePassword")
+	}
+	if ctx.cluster.Spec.DryRun {
+		return nil
+	}
+	if err := saveAppliedPassword(r.client, ctx.cluster, auth.Password, ""); err != nil {
+		return Kubernetes.Wrap(err, "saveAppliedPassword")
+	}
+	return nil
+}
@@ -0,0 +1,1060 @@
+package distributedrediscluster
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/apis"
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/heal"
+	clustermanger "github.com/ucloud/redis-cluster-operator/pkg/controller/manager"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+func TestReconcileDistributedRedisCluster_ensureCluster_dryRun(t *testing.T) {
+	cluster := passwordCluster()
+	cluster.Spec.MasterSize = 3
+	cluster.Spec.ClusterReplicas = 1
+	cluster.Spec.DryRun = true
+	cluster.Spec.Expose = &redisv1alpha1.ExposeSpec{PodServiceType: corev1.ServiceTypeNodePort}
+	cluster.Default()
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	if err := monitoringv1.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	client := fake.NewFakeClientWithScheme(s, passwordSecret("test-password", "password"))
+	r := &ReconcileDistributedRedisCluster{client: client}
+	ctx := &syncContext{cluster: cluster, reqLogger: log, plan: &redisutil.Plan{}}
+
+	if err := r.ensureCluster(ctx); err != nil {
+		t.Fatalf("ensureCluster() error = %v", err)
+	}
+
+	for _, list := range []runtime.Object{&appsv1.StatefulSetList{}, &corev1.ServiceList{}, &corev1.ConfigMapList{}} {
+		if err := client.List(context.TODO(), list, ctrlclient.InNamespace(cluster.Namespace)); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if items := reflect.ValueOf(list).Elem().FieldByName("Items"); items.Len() != 0 {
+			t.Errorf("ensureCluster() created %d %T in dry-run mode", items.Len(), list)
+		}
+	}
+	steps := map[string]bool{}
+	for _, step := range ctx.plan.Steps() {
+		steps[step] = true
+	}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		if step := "create StatefulSet " + statefulsets.ClusterStatefulSetName(cluster.Name, i); !steps[step] {
+			t.Errorf("ensureCluster() plan = %q, want the step %q", ctx.plan.Steps(), step)
+		}
+	}
+}
+
+func TestReconcileDistributedRedisCluster_dryRun_scaleUp(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	nodes := redisutil.Nodes{
+		newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 8191)),
+		newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+		newTestNode("3", "drc-test-1-0", "drc-test-1", "vm2", master, "", redisutil.BuildSlotSlice(8192, 16383)),
+		newTestNode("4", "drc-test-1-1", "drc-test-1", "vm1", slave, "3", nil),
+	}
+	cluster := passwordCluster()
+	cluster.Generation = 2
+	cluster.Spec.MasterSize = 3
+	cluster.Spec.ClusterReplicas = 1
+	cluster.Spec.DryRun = true
+	cluster.Default()
+	cluster.Status = redisv1alpha1.DistributedRedisClusterStatus{
+		ObservedGeneration:   1,
+		NumberOfMaster:       2,
+		MinReplicationFactor: 1,
+		MaxReplicationFactor: 1,
+	}
+	var pods []*corev1.Pod
+	for _, node := range nodes {
+		cluster.Status.Nodes = append(cluster.Status.Nodes, redisv1alpha1.RedisClusterNode{
+			ID: node.ID, PodName: node.PodName, StatefulSet: node.StatefulSet, NodeName: node.NodeName})
+		pods = append(pods, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: node.PodName, Namespace: cluster.Namespace}})
+	}
+	s := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{scheme.AddToScheme, monitoringv1.AddToScheme, apis.AddToScheme} {
+		if err := addToScheme(s); err != nil {
+			t.Fatalf("AddToScheme() error = %v", err)
+		}
+	}
+	client := fake.NewFakeClientWithScheme(s, cluster, passwordSecret("test-password", "password"),
+		newTestStatefulSet("drc-test-0", 2), newTestStatefulSet("drc-test-1", 2))
+	r := &ReconcileDistributedRedisCluster{
+		client:       client,
+		crController: k8sutil.NewCRControl(client),
+		checker:      clustermanger.NewCheck(client),
+	}
+	plan := &redisutil.Plan{}
+	ctx := &syncContext{cluster: cluster, pods: pods, reqLogger: log, plan: plan}
+	ctx.healer = clustermanger.NewHealer(&heal.CheckAndHeal{
+		Logger:     log,
+		PodControl: k8sutil.NewPodController(client),
+		Pods:       pods,
+		DryRun:     true,
+		Plan:       plan,
+	})
+	admin := newFakeAdmin(nodes)
+	ctx.admin = redisutil.NewPlanAdmin(admin, plan)
+	ctx.clusterInfos, _ = admin.GetClusterInfos()
+
+	if err := r.ensureCluster(ctx); err != nil {
+		t.Fatalf("ensureCluster() error = %v", err)
+	}
+	if err := r.waitPodReady(ctx); err != nil {
+		t.Fatalf("waitPodReady() error = %v, want the planned statefulSet ignored", err)
+	}
+	if !needClusterOperation(cluster, log) {
+		t.Fatalf("needClusterOperation() = false, want the new shard planned")
+	}
+	if err := r.sync(ctx); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	r.publishPlan(ctx)
+
+	ssList := &appsv1.StatefulSetList{}
+	if err := client.List(context.TODO(), ssList, ctrlclient.InNamespace(cluster.Namespace)); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(ssList.Items) != 2 {
+		t.Errorf("dry-run created %d statefulSets, want 2", len(ssList.Items))
+	}
+	if got := len(admin.slots("1")) + len(admin.slots("3")); got != redisutil.DefaultHashMaxSlots+1 {
+		t.Errorf("dry-run moved %d slots, want none", redisutil.DefaultHashMaxSlots+1-got)
+	}
+	got := &redisv1alpha1.DistributedRedisCluster{}
+	if err := client.Get(context.TODO(), types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status.Plan == nil || got.Status.Plan.ObservedGeneration != cluster.Generation {
+		t.Fatalf("published plan = %+v, want the plan of generation %d", got.Status.Plan, cluster.Generation)
+	}
+	steps := map[string]bool{}
+	moved := false
+	for _, step := range got.Status.Plan.Steps {
+		steps[step] = true
+		if strings.HasPrefix(step, "move the slots ") && strings.HasSuffix(step, " to drc-test-2-0:6379") {
+			moved = true
+		}
+	}
+	for _, step := range []string{"create StatefulSet drc-test-2", "replicate drc-test-2-1:6379 from master drc-test-2-0"} {
+		if !steps[step] {
+			t.Errorf("published plan = %q, want the step %q", got.Status.Plan.Steps, step)
+		}
+	}
+	if !moved {
+		t.Errorf("published plan = %q, want slots moved to the new master drc-test-2-0", got.Status.Plan.Steps)
+	}
+	if ready := got.Status.GetCondition(redisv1alpha1.ClusterConditionReady); ready == nil ||
+		ready.Status != corev1.ConditionFalse || ready.Reason != "DryRun" {
+		t.Errorf("Ready condition = %+v, want False with the reason DryRun", ready)
+	}
+	if got.Status.ObservedGeneration != 1 {
+		t.Errorf("observedGeneration = %d, want 1 until the plan is applied", got.Status.ObservedGeneration)
+	}
+}
+
+func TestReconcileDistributedRedisCluster_rotatePassword(t *testing.T) {
+	cluster := passwordCluster()
+	rotated := time.Now().UTC().Format(time.RFC3339)
+	expired := time.Now().Add(-passwordGracePeriod - time.Minute).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name             string
+		dryRun           bool
+		objs             []runtime.Object
+		wantSteps        []string
+		wantApplied      bool
+		wantAppliedPass  string
+		wantPreviousPass string
+	}{
+		{
+			name:            "password never applied",
+			objs:            []runtime.Object{passwordSecret("test-password", "new")},
+			wantApplied:     true,
+			wantAppliedPass: "new",
+		},
+		{
+			name:   "password never applied in dry-run mode",
+			dryRun: true,
+			objs:   []runtime.Object{passwordSecret("test-password", "new")},
+		},
+		{
+			name: "password rotated",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				passwordSecret(cluster.AppliedPasswordSecretName(), "old"),
+			},
+			wantSteps:        []string{"set the password of the nodes"},
+			wantApplied:      true,
+			wantAppliedPass:  "new",
+			wantPreviousPass: "old",
+		},
+		{
+			name:   "password rotated in dry-run mode",
+			dryRun: true,
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				passwordSecret(cluster.AppliedPasswordSecretName(), "old"),
+			},
+			wantSteps:       []string{"set the password of the nodes"},
+			wantApplied:     true,
+			wantAppliedPass: "old",
+		},
+		{
+			name: "password rotated again during the grace period",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "newer"),
+				rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", rotated),
+			},
+			wantSteps:        []string{"remove the previous password of the nodes", "set the password of the nodes"},
+			wantApplied:      true,
+			wantAppliedPass:  "newer",
+			wantPreviousPass: "new",
+		},
+		{
+			name: "grace period running",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", rotated),
+			},
+			wantApplied:      true,
+			wantAppliedPass:  "new",
+			wantPreviousPass: "old",
+		},
+		{
+			name: "grace period ended",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", expired),
+			},
+			wantSteps:       []string{"remove the previous password of the nodes"},
+			wantApplied:     true,
+			wantAppliedPass: "new",
+		},
+		{
+			name:   "grace period ended in dry-run mode",
+			dryRun: true,
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", expired),
+			},
+			wantSteps:        []string{"remove the previous password of the nodes"},
+			wantApplied:      true,
+			wantAppliedPass:  "new",
+			wantPreviousPass: "old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := passwordCluster()
+			cluster.Spec.DryRun = tt.dryRun
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, tt.objs...)
+			r := &ReconcileDistributedRedisCluster{client: client}
+			plan := &redisutil.Plan{}
+			ctx := &syncContext{cluster: cluster, reqLogger: log, plan: plan}
+			admin := redisutil.NewPlanAdmin(redisutil.NewAdmin(nil, nil), plan)
+			defer admin.Close()
+			auth, err := redisadmin.GetAuth(client, cluster)
+			if err != nil {
+				t.Fatalf("GetAuth() error = %v", err)
+			}
+
+			if err := r.rotatePassword(ctx, admin, auth); err != nil {
+				t.Fatalf("rotatePassword() error = %v", err)
+			}
+
+			if got := plan.Steps(); !reflect.DeepEqual(got, tt.wantSteps) {
+				t.Errorf("rotatePassword() plan = %q, want %q", got, tt.wantSteps)
+			}
+			// the applied password is not recorded in dry-run mode
+			auth, err = redisadmin.GetAuth(client, cluster)
+			if err != nil {
+				t.Fatalf("GetAuth() error = %v", err)
+			}
+			if auth.Applied != tt.wantApplied || (tt.wantApplied && auth.AppliedPassword != tt.wantAppliedPass) {
+				t.Errorf("rotatePassword() applied = %v with %s, want %v with %s",
+					auth.Applied, auth.AppliedPassword, tt.wantApplied, tt.wantAppliedPass)
+			}
+			if auth.PreviousPassword != tt.wantPreviousPass {
+				t.Errorf("rotatePassword() previous password = %s, want %s", auth.PreviousPassword, tt.wantPreviousPass)
+			}
+		})
+	}
+}
+
+func TestReconcileDistributedRedisCluster_validate(t *testing.T) {
+	tests := []struct {
+		name           string
+		watchNamespace string
+		topologyKey    string
+		wantErr        bool
+	}{
+		{
+			name:        "topologyKey with a cluster-scoped operator",
+			topologyKey: "topology.kubernetes.io/zone",
+		},
+		{
+			name:           "topologyKey with a namespace-scoped operator",
+			watchNamespace: "default",
+			topologyKey:    "topology.kubernetes.io/zone",
+			wantErr:        true,
+		},
+		{
+			name:           "no topologyKey with a namespace-scoped operator",
+			watchNamespace: "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := passwordCluster()
+			cluster.Spec.TopologyKey = tt.topologyKey
+			cluster.Default()
+			r := &ReconcileDistributedRedisCluster{watchNamespace: tt.watchNamespace}
+			if err := r.validate(cluster); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeAdmin is an in-memory redis cluster, its nodes are changed by the commands sent by the admin.
+// The nodes given to the controller are copies, like the view the controller has of real nodes.
+type fakeAdmin struct {
+	redisutil.IAdmin
+	nodes       map[string]*redisutil.Node
+	slotOwners  map[redisutil.Slot]string
+	offsets     map[string]int64
+	linkDown    map[string]bool
+	removed     map[string]bool
+	forgotten   map[string]bool
+	failovers   []string
+	resetErrors []string
+}
+
+func newFakeAdmin(nodes redisutil.Nodes) *fakeAdmin {
+	a := &fakeAdmin{
+		nodes:      map[string]*redisutil.Node{},
+		slotOwners: map[redisutil.Slot]string{},
+		offsets:    map[string]int64{},
+		linkDown:   map[string]bool{},
+		removed:    map[string]bool{},
+		forgotten:  map[string]bool{},
+	}
+	for _, node := range nodes {
+		a.nodes[node.IPPort()] = cloneNode(node)
+		for _, slot := range node.Slots {
+			a.slotOwners[slot] = node.ID
+		}
+	}
+	return a
+}
+
+func cloneNode(node *redisutil.Node) *redisutil.Node {
+	clone := *node
+	clone.Slots = append([]redisutil.Slot{}, node.Slots...)
+	return &clone
+}
+
+func (a *fakeAdmin) nodeByID(id string) *redisutil.Node {
+	for _, node := range a.nodes {
+		if node.ID == id {
+			return node
+		}
+	}
+	return nil
+}
+
+// slots returns the slots owned by the node
+func (a *fakeAdmin) slots(id string) []redisutil.Slot {
+	var slots []redisutil.Slot
+	for slot, owner := range a.slotOwners {
+		if owner == id {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+func (a *fakeAdmin) Connections() redisutil.IAdminConnections {
+	return &fakeConnections{admin: a}
+}
+
+func (a *fakeAdmin) GetClusterInfos() (*redisutil.ClusterInfos, error) {
+	infos := redisutil.NewClusterInfos()
+	for addr, node := range a.nodes {
+		if a.removed[addr] {
+			continue
+		}
+		nodeInfos := &redisutil.NodeInfos{Node: cloneNode(node)}
+		for _, friend := range a.nodes {
+			if friend.ID != node.ID && !a.forgotten[friend.ID] {
+				nodeInfos.Friends = append(nodeInfos.Friends, cloneNode(friend))
+			}
+		}
+		infos.Infos[addr] = nodeInfos
+	}
+	infos.Status = redisutil.ClusterInfosConsistent
+	return infos, nil
+}
+
+func (a *fakeAdmin) StartFailover(addr string) error {
+	slave := a.nodes[addr]
+	master := a.nodeByID(slave.MasterReferent)
+	if master == nil || a.linkDown[addr] {
+		return fmt.Errorf("failover of node %s not done", addr)
+	}
+	a.failovers = append(a.failovers, addr)
+	for _, slot := range a.slots(master.ID) {
+		a.slotOwners[slot] = slave.ID
+	}
+	for _, node := range a.nodes {
+		if node.MasterReferent == master.ID {
+			node.MasterReferent = slave.ID
+		}
+	}
+	slave.SetRole(redisutil.RedisMasterRole)
+	slave.MasterReferent = ""
+	master.SetRole(redisutil.RedisSlaveRole)
+	master.MasterReferent = slave.ID
+	return nil
+}
+
+// Failover promotes the slave at once, the restarted pods are checked on the next pass
+func (a *fakeAdmin) Failover(addr string) error {
+	return a.StartFailover(addr)
+}
+
+func (a *fakeAdmin) DetachSlave(slave *redisutil.Node) error {
+	node := a.nodes[slave.IPPort()]
+	node.SetRole(redisutil.RedisMasterRole)
+	node.MasterReferent = ""
+	slave.SetReferentMaster("")
+	slave.SetRole(redisutil.RedisMasterRole)
+	return nil
+}
+
+func (a *fakeAdmin) AttachSlaveToMaster(slave *redisutil.Node, masterID string) error {
+	node := a.nodes[slave.IPPort()]
+	node.SetRole(redisutil.RedisSlaveRole)
+	node.MasterReferent = masterID
+	return nil
+}
+
+func (a *fakeAdmin) GetHashMaxSlot() redisutil.Slot {
+	return redisutil.DefaultHashMaxSlots
+}
+
+func (a *fakeAdmin) SetSlot(addr, action string, slot redisutil.Slot, nodeID string) error {
+	if action == "NODE" {
+		a.slotOwners[slot] = nodeID
+	}
+	return nil
+}
+
+func (a *fakeAdmin) MigrateKeysInSlot(addr string, dest *redisutil.Node, slot redisutil.Slot, opts redisutil.MigrateOptions) (int, error) {
+	return 0, nil
+}
+
+// ResetNode fails like redis when the node is a master still owning slots, its keys would be lost
+func (a *fakeAdmin) ResetNode(addr string, mode string) error {
+	node := a.nodes[addr]
+	if slots := a.slots(node.ID); len(slots) > 0 {
+		a.resetErrors = append(a.resetErrors, addr)
+		return fmt.Errorf("node %s still owns %d slots", addr, len(slots))
+	}
+	node.SetRole(redisutil.RedisMasterRole)
+	node.MasterReferent = ""
+	return nil
+}
+
+func (a *fakeAdmin) ForgetNode(id string) error {
+	a.forgotten[id] = true
+	return nil
+}
+
+func (a *fakeAdmin) GetNodeConfig(addr string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (a *fakeAdmin) GetReplicationOffset(addr string) (int64, error) {
+	return a.offsets[addr], nil
+}
+
+func (a *fakeAdmin) IsMasterLinkUp(addr string) (bool, error) {
+	return !a.linkDown[addr], nil
+}
+
+// fakeConnections are the connections of a fakeAdmin
+type fakeConnections struct {
+	redisutil.IAdminConnections
+	admin *fakeAdmin
+}
+
+func (c *fakeConnections) Remove(addr string) {
+	c.admin.removed[addr] = true
+}
+
+func (c *fakeConnections) GetAll() map[string]redisutil.IClient {
+	clients := map[string]redisutil.IClient{}
+	for addr := range c.admin.nodes {
+		if !c.admin.removed[addr] {
+			clients[addr] = nil
+		}
+	}
+	return clients
+}
+
+// checkedStatefulSetControl calls check before the statefulSets are shrunk or deleted
+type checkedStatefulSetControl struct {
+	k8sutil.IStatefulSetControl
+	check func()
+}
+
+func (c *checkedStatefulSetControl) UpdateStatefulSet(ss *appsv1.StatefulSet) error {
+	c.check()
+	return c.IStatefulSetControl.UpdateStatefulSet(ss)
+}
+
+func (c *checkedStatefulSetControl) DeleteStatefulSet(ss *appsv1.StatefulSet) error {
+	c.check()
+	return c.IStatefulSetControl.DeleteStatefulSet(ss)
+}
+
+// deletedPodControl records the deleted pods
+type deletedPodControl struct {
+	k8sutil.IPodControl
+	deleted []string
+}
+
+func (c *deletedPodControl) DeletePod(pod *corev1.Pod) error {
+	c.deleted = append(c.deleted, pod.Name)
+	return nil
+}
+
+func newTestNode(id, podName, statefulSet, vm, role, master string, slots []redisutil.Slot) *redisutil.Node {
+	return &redisutil.Node{ID: id, IP: "10.0.0." + id, Port: "6379", Role: role, MasterReferent: master, Slots: slots,
+		PodName: podName, StatefulSet: statefulSet, NodeName: vm}
+}
+
+func newTestStatefulSet(name string, replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{redisv1alpha1.LabelClusterName: "test"}},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
+func TestReconcileDistributedRedisCluster_scalingDown(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	tests := []struct {
+		name             string
+		masterSize       int32
+		clusterReplicas  int32
+		nodes            redisutil.Nodes
+		statefulSets     []runtime.Object
+		wantForgotten    []string
+		wantStatefulSets map[string]int32
+	}{
+		{
+			name:            "masterSize scaled down",
+			masterSize:      2,
+			clusterReplicas: 1,
+			nodes: redisutil.Nodes{
+				newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 5460)),
+				newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+				newTestNode("3", "drc-test-1-0", "drc-test-1", "vm2", master, "", redisutil.BuildSlotSlice(5461, 10922)),
+				newTestNode("4", "drc-test-1-1", "drc-test-1", "vm3", slave, "3", nil),
+				newTestNode("5", "drc-test-2-0", "drc-test-2", "vm3", master, "", redisutil.BuildSlotSlice(10923, 16383)),
+				newTestNode("6", "drc-test-2-1", "drc-test-2", "vm1", slave, "5", nil),
+			},
+			statefulSets: []runtime.Object{
+				newTestStatefulSet("drc-test-0", 2), newTestStatefulSet("drc-test-1", 2), newTestStatefulSet("drc-test-2", 2),
+			},
+			wantForgotten:    []string{"5", "6"},
+			wantStatefulSets: map[string]int32{"drc-test-0": 2, "drc-test-1": 2},
+		},
+		{
+			name:            "clusterReplicas scaled down with a removed master",
+			masterSize:      2,
+			clusterReplicas: 0,
+			nodes: redisutil.Nodes{
+				newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 8191)),
+				newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+				newTestNode("3", "drc-test-1-0", "drc-test-1", "vm2", slave, "4", nil),
+				newTestNode("4", "drc-test-1-1", "drc-test-1", "vm3", master, "", redisutil.BuildSlotSlice(8192, 16383)),
+			},
+			statefulSets:     []runtime.Object{newTestStatefulSet("drc-test-0", 2), newTestStatefulSet("drc-test-1", 2)},
+			wantForgotten:    []string{"2", "4"},
+			wantStatefulSets: map[string]int32{"drc-test-0": 1, "drc-test-1": 1},
+		},
+		{
+			name:            "legacy statefulSet migrated",
+			masterSize:      2,
+			clusterReplicas: 0,
+			nodes: redisutil.Nodes{
+				newTestNode("1", "drc-test-0", "drc-test", "vm1", master, "", redisutil.BuildSlotSlice(0, 8191)),
+				newTestNode("2", "drc-test-1", "drc-test", "vm2", master, "", redisutil.BuildSlotSlice(8192, 16383)),
+				newTestNode("3", "drc-test-0-0", "drc-test-0", "vm3", master, "", nil),
+				newTestNode("4", "drc-test-1-0", "drc-test-1", "vm4", master, "", nil),
+			},
+			statefulSets: []runtime.Object{
+				newTestStatefulSet("drc-test", 2), newTestStatefulSet("drc-test-0", 1), newTestStatefulSet("drc-test-1", 1),
+			},
+			wantForgotten:    []string{"1", "2"},
+			wantStatefulSets: map[string]int32{"drc-test-0": 1, "drc-test-1": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := passwordCluster()
+			cluster.Spec.MasterSize = tt.masterSize
+			cluster.Spec.ClusterReplicas = tt.clusterReplicas
+			admin := newFakeAdmin(tt.nodes)
+			rCluster := &redisutil.Cluster{Name: cluster.Name, Namespace: cluster.Namespace, Nodes: map[string]*redisutil.Node{}}
+			for _, node := range tt.nodes {
+				rCluster.Nodes[node.ID] = node
+			}
+			removedNodes, _ := splitNodesByShard(cluster, tt.nodes)
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, tt.statefulSets...)
+			shrunk := false
+			ssController := &checkedStatefulSetControl{
+				IStatefulSetControl: k8sutil.NewStatefulSetController(client),
+				check: func() {
+					shrunk = true
+					for _, node := range removedNodes {
+						if slots := admin.slots(node.ID); len(slots) > 0 {
+							t.Errorf("removed node %s owns %d slots when the statefulSets are shrunk", node.ID, len(slots))
+						}
+						if !admin.forgotten[node.ID] {
+							t.Errorf("removed node %s is not forgotten when the statefulSets are shrunk", node.ID)
+						}
+					}
+				},
+			}
+			r := &ReconcileDistributedRedisCluster{client: client, statefulSetController: ssController}
+			ctx := &syncContext{cluster: cluster, admin: admin, reqLogger: log}
+
+			if err := r.scalingDown(ctx, rCluster, tt.nodes); err != nil {
+				t.Fatalf("scalingDown() error = %v", err)
+			}
+
+			if !shrunk {
+				t.Errorf("scalingDown() did not shrink the statefulSets")
+			}
+			if len(admin.resetErrors) > 0 {
+				t.Errorf("scalingDown() reset the nodes %v still owning slots", admin.resetErrors)
+			}
+			for _, id := range tt.wantForgotten {
+				if !admin.forgotten[id] {
+					t.Errorf("scalingDown() did not forget the node %s", id)
+				}
+			}
+			if len(admin.forgotten) != len(tt.wantForgotten) {
+				t.Errorf("scalingDown() forgot %v, want %v", admin.forgotten, tt.wantForgotten)
+			}
+			if len(admin.slotOwners) != redisutil.DefaultHashMaxSlots+1 {
+				t.Errorf("scalingDown() left %d slots assigned, want %d", len(admin.slotOwners), redisutil.DefaultHashMaxSlots+1)
+			}
+			ssList := &appsv1.StatefulSetList{}
+			if err := client.List(context.TODO(), ssList, ctrlclient.InNamespace(cluster.Namespace)); err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			got := map[string]int32{}
+			for _, ss := range ssList.Items {
+				got[ss.Name] = *ss.Spec.Replicas
+			}
+			if !reflect.DeepEqual(got, tt.wantStatefulSets) {
+				t.Errorf("scalingDown() statefulSets = %v, want %v", got, tt.wantStatefulSets)
+			}
+		})
+	}
+}
+
+func TestReconcileDistributedRedisCluster_scalingDown_failingReplica(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	cluster := passwordCluster()
+	cluster.Spec.MasterSize = 1
+	cluster.Spec.ClusterReplicas = 1
+	failingSlave := newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil)
+	failingSlave.FailStatus = []string{redisutil.NodeStatusFail}
+	nodes := redisutil.Nodes{
+		newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 16383)),
+		failingSlave,
+		newTestNode("3", "drc-test-0-2", "drc-test-0", "vm3", slave, "1", nil),
+	}
+	admin := newFakeAdmin(nodes)
+	rCluster := &redisutil.Cluster{Name: cluster.Name, Namespace: cluster.Namespace, Nodes: map[string]*redisutil.Node{}}
+	for _, node := range nodes {
+		rCluster.Nodes[node.ID] = node
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, newTestStatefulSet("drc-test-0", 3))
+	r := &ReconcileDistributedRedisCluster{client: client, statefulSetController: k8sutil.NewStatefulSetController(client)}
+	ctx := &syncContext{cluster: cluster, admin: admin, reqLogger: log}
+
+	err := r.scalingDown(ctx, rCluster, nodes)
+	if GetType(err) != Requeue {
+		t.Fatalf("scalingDown() error = %v, want a Requeue error", err)
+	}
+	if len(admin.forgotten) > 0 {
+		t.Errorf("scalingDown() forgot %v while the kept replica is failing", admin.forgotten)
+	}
+	ss, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, "drc-test-0")
+	if err != nil {
+		t.Fatalf("GetStatefulSet() error = %v", err)
+	}
+	if *ss.Spec.Replicas != 3 {
+		t.Errorf("scalingDown() shrank the statefulSet to %d replicas while the kept replica is failing", *ss.Spec.Replicas)
+	}
+}
+
+// newTestRestartContext returns the context of a pass over the nodes, with the pods of the nodes owned by
+// their statefulSet at the given revision.
+func newTestRestartContext(nodes redisutil.Nodes, revision string) (*syncContext, *fakeAdmin) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	}
+	admin := newFakeAdmin(nodes)
+	ctx := &syncContext{cluster: cluster, admin: admin, reqLogger: log}
+	for _, node := range nodes {
+		cluster.Status.Nodes = append(cluster.Status.Nodes, redisv1alpha1.RedisClusterNode{
+			ID: node.ID, PodName: node.PodName, StatefulSet: node.StatefulSet, NodeName: node.NodeName})
+		controller := true
+		ctx.pods = append(ctx.pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            node.PodName,
+				Namespace:       cluster.Namespace,
+				Labels:          map[string]string{appsv1.StatefulSetRevisionLabel: revision},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: node.StatefulSet, Controller: &controller}},
+			},
+		})
+	}
+	ctx.clusterInfos, _ = admin.GetClusterInfos()
+	return ctx, admin
+}
+
+func TestReconcileDistributedRedisCluster_restartPods(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	nodes := func() redisutil.Nodes {
+		return redisutil.Nodes{
+			newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 8191)),
+			newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+			newTestNode("3", "drc-test-0-2", "drc-test-0", "vm3", slave, "1", nil),
+			newTestNode("4", "drc-test-1-0", "drc-test-1", "vm1", master, "", redisutil.BuildSlotSlice(8192, 16383)),
+		}
+	}
+	tests := []struct {
+		name          string
+		pods          []string
+		offsets       map[string]int64
+		linkDown      []string
+		wantDeleted   []string
+		wantFailovers []string
+	}{
+		{
+			name:        "slave restarted first",
+			pods:        []string{"drc-test-0-0", "drc-test-0-1"},
+			wantDeleted: []string{"drc-test-0-1"},
+		},
+		{
+			name:          "master failed over to the slave with the highest offset",
+			pods:          []string{"drc-test-0-0"},
+			offsets:       map[string]int64{"10.0.0.2:6379": 100, "10.0.0.3:6379": 200},
+			wantFailovers: []string{"10.0.0.3:6379"},
+		},
+		{
+			name:          "slave with the link down not promoted",
+			pods:          []string{"drc-test-0-0"},
+			offsets:       map[string]int64{"10.0.0.2:6379": 100, "10.0.0.3:6379": 200},
+			linkDown:      []string{"10.0.0.3:6379"},
+			wantFailovers: []string{"10.0.0.2:6379"},
+		},
+		{
+			name:     "no slave in sync",
+			pods:     []string{"drc-test-0-0"},
+			linkDown: []string{"10.0.0.2:6379", "10.0.0.3:6379"},
+		},
+		{
+			name:        "master without slave restarted",
+			pods:        []string{"drc-test-1-0"},
+			wantDeleted: []string{"drc-test-1-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, admin := newTestRestartContext(nodes(), "")
+			for addr, offset := range tt.offsets {
+				admin.offsets[addr] = offset
+			}
+			for _, addr := range tt.linkDown {
+				admin.linkDown[addr] = true
+			}
+			var pods []*corev1.Pod
+			for _, name := range tt.pods {
+				for _, pod := range ctx.pods {
+					if pod.Name == name {
+						pods = append(pods, pod)
+					}
+				}
+			}
+			podController := &deletedPodControl{}
+			r := &ReconcileDistributedRedisCluster{podController: podController}
+
+			if err := r.restartPods(ctx, pods, "test"); GetType(err) != Requeue {
+				t.Fatalf("restartPods() error = %v, want a Requeue error", err)
+			}
+			if !reflect.DeepEqual(podController.deleted, tt.wantDeleted) {
+				t.Errorf("restartPods() deleted %v, want %v", podController.deleted, tt.wantDeleted)
+			}
+			if !reflect.DeepEqual(admin.failovers, tt.wantFailovers) {
+				t.Errorf("restartPods() failovers = %v, want %v", admin.failovers, tt.wantFailovers)
+			}
+		})
+	}
+}
+
+func TestReconcileDistributedRedisCluster_rollingUpdate(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	nodes := redisutil.Nodes{
+		newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 16383)),
+		newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+	}
+	tests := []struct {
+		name        string
+		revision    string
+		wantErr     bool
+		wantDeleted []string
+	}{
+		{
+			name:     "pods up to date",
+			revision: "rev2",
+		},
+		{
+			name:        "outdated pods",
+			revision:    "rev1",
+			wantErr:     true,
+			wantDeleted: []string{"drc-test-0-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newTestRestartContext(nodes, tt.revision)
+			ss := newTestStatefulSet("drc-test-0", 2)
+			ss.Status.UpdateRevision = "rev2"
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, ss)
+			podController := &deletedPodControl{}
+			r := &ReconcileDistributedRedisCluster{
+				client:                client,
+				statefulSetController: k8sutil.NewStatefulSetController(client),
+				podController:         podController,
+			}
+
+			err := r.rollingUpdate(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rollingUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(podController.deleted, tt.wantDeleted) {
+				t.Errorf("rollingUpdate() deleted %v, want %v", podController.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestReconcileDistributedRedisCluster_expandVolumes(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	nodes := redisutil.Nodes{
+		newTestNode("1", "drc-test-0-0", "drc-test-0", "vm1", master, "", redisutil.BuildSlotSlice(0, 16383)),
+		newTestNode("2", "drc-test-0-1", "drc-test-0", "vm2", slave, "1", nil),
+	}
+	size := resource.MustParse("2Gi")
+	allowed, notAllowed := true, false
+	tests := []struct {
+		name             string
+		allowExpansion   *bool
+		request          string
+		capacity         string
+		resizePending    bool
+		dryRun           bool
+		expanding        bool
+		wantErr          bool
+		wantRequest      string
+		wantDeleted      []string
+		wantPlan         int
+		wantCondition    corev1.ConditionStatus
+		wantReason       string
+		wantNoExpansions bool
+	}{
+		{
+			name:           "claims expanded",
+			allowExpansion: &allowed,
+			request:        "1Gi",
+			capacity:       "1Gi",
+			wantErr:        true,
+			wantRequest:    "2Gi",
+			wantCondition:  corev1.ConditionTrue,
+			wantReason:     "ExpandingVolumes",
+		},
+		{
+			name:           "storage class not allowing the expansion",
+			allowExpansion: &notAllowed,
+			request:        "1Gi",
+			capacity:       "1Gi",
+			wantRequest:    "1Gi",
+			wantCondition:  corev1.ConditionFalse,
+			wantReason:     "ExpansionNotAllowed",
+		},
+		{
+			name:          "storage class not allowing the expansion by default",
+			request:       "1Gi",
+			capacity:      "1Gi",
+			wantRequest:   "1Gi",
+			wantCondition: corev1.ConditionFalse,
+			wantReason:    "ExpansionNotAllowed",
+		},
+		{
+			name:           "filesystems resized when the pods restart",
+			allowExpansion: &allowed,
+			request:        "2Gi",
+			capacity:       "1Gi",
+			resizePending:  true,
+			wantErr:        true,
+			wantRequest:    "2Gi",
+			wantDeleted:    []string{"drc-test-0-1"},
+			wantCondition:  corev1.ConditionTrue,
+			wantReason:     "ExpandingVolumes",
+		},
+		{
+			name:           "volumes expanded",
+			allowExpansion: &allowed,
+			request:        "2Gi",
+			capacity:       "2Gi",
+			expanding:      true,
+			wantRequest:    "2Gi",
+			wantCondition:  corev1.ConditionFalse,
+			wantReason:     "VolumesExpanded",
+		},
+		{
+			name:             "volumes never expanded",
+			allowExpansion:   &allowed,
+			request:          "2Gi",
+			capacity:         "2Gi",
+			wantRequest:      "2Gi",
+			wantNoExpansions: true,
+		},
+		{
+			name:             "dry run",
+			allowExpansion:   &allowed,
+			request:          "1Gi",
+			capacity:         "1Gi",
+			dryRun:           true,
+			wantRequest:      "1Gi",
+			wantPlan:         2,
+			wantNoExpansions: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newTestRestartContext(nodes, "")
+			cluster := ctx.cluster
+			cluster.Spec.Storage = &redisv1alpha1.RedisStorage{Size: size, Type: redisv1alpha1.PersistentClaim, Class: "standard"}
+			cluster.Spec.DryRun = tt.dryRun
+			if tt.dryRun {
+				ctx.plan = &redisutil.Plan{}
+			}
+			if tt.expanding {
+				cluster.Status.VolumeExpansion = &redisv1alpha1.VolumeExpansionStatus{Size: size, Total: 2}
+			}
+			objs := []runtime.Object{&storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+				AllowVolumeExpansion: tt.allowExpansion,
+			}}
+			for _, pod := range ctx.pods {
+				pvc := &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      statefulsets.DataClaimName(pod.Name),
+						Namespace: cluster.Namespace,
+						Labels:    map[string]string{redisv1alpha1.LabelClusterName: cluster.Name},
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						StorageClassName: &cluster.Spec.Storage.Class,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(tt.request)},
+						},
+					},
+					Status: corev1.PersistentVolumeClaimStatus{
+						Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(tt.capacity)},
+					},
+				}
+				if tt.resizePending {
+					pvc.Status.Conditions = []corev1.PersistentVolumeClaimCondition{
+						{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+					}
+				}
+				objs = append(objs, pvc)
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+			podController := &deletedPodControl{}
+			r := &ReconcileDistributedRedisCluster{
+				client:                 client,
+				crController:           k8sutil.NewCRControl(client),
+				podController:          podController,
+				pvcController:          k8sutil.NewPvcController(client),
+				storageClassController: k8sutil.NewStorageClassController(client),
+			}
+
+			err := r.expandVolumes(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandVolumes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			pvcList, err := r.pvcController.ListPvcByLabels(cluster.Namespace, ctrlclient.MatchingLabels{redisv1alpha1.LabelClusterName: cluster.Name})
+			if err != nil {
+				t.Fatalf("ListPvcByLabels() error = %v", err)
+			}
+			for _, pvc := range pvcList.Items {
+				request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+				if request.String() != tt.wantRequest {
+					t.Errorf("expandVolumes() claim %s requests %s, want %s", pvc.Name, request.String(), tt.wantRequest)
+				}
+			}
+			if !reflect.DeepEqual(podController.deleted, tt.wantDeleted) {
+				t.Errorf("expandVolumes() deleted %v, want %v", podController.deleted, tt.wantDeleted)
+			}
+			if tt.dryRun && len(ctx.plan.Steps()) != tt.wantPlan {
+				t.Errorf("expandVolumes() plan = %q, want %d steps", ctx.plan.Steps(), tt.wantPlan)
+			}
+			condition := cluster.Status.GetCondition(redisv1alpha1.ClusterConditionResizing)
+			if tt.wantNoExpansions {
+				if condition != nil || cluster.Status.VolumeExpansion != nil {
+					t.Errorf("expandVolumes() reported %v, want no expansion", condition)
+				}
+				return
+			}
+			if condition == nil || condition.Status != tt.wantCondition || condition.Reason != tt.wantReason {
+				t.Errorf("expandVolumes() condition = %+v, want %s with the reason %s", condition, tt.wantCondition, tt.wantReason)
+			}
+		})
+	}
+}
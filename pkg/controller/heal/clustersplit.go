@@ -2,7 +2,6 @@ package heal
 
 import (
 	"fmt"
-	"time"
 
 	"k8s.io/apimachinery/pkg/util/errors"
 
@@ -17,9 +16,10 @@ func (c *CheckAndHeal) FixClusterSplit(cluster *redisv1alpha1.DistributedRedisCl
 
 	if len(clusters) > 1 {
 		if c.DryRun {
+			c.Plan.Add("fix the cluster split in %d clusters, the keys of all but the biggest one are lost", len(clusters))
 			return true, nil
 		}
-		return true, c.reassignClusters(admin, config, clusters)
+		return true, c.reassignClusters(admin, clusters)
 	}
 	c.Logger.V(3).Info("[Check] No split cluster detected")
 	return false, nil
@@ -27,7 +27,7 @@ func (c *CheckAndHeal) FixClusterSplit(cluster *redisv1alpha1.DistributedRedisCl
 
 type cluster []string
 
-func (c *CheckAndHeal) reassignClusters(admin redisutil.IAdmin, config *config.Redis, clusters []cluster) error {
+func (c *CheckAndHeal) reassignClusters(admin redisutil.IAdmin, clusters []cluster) error {
 	c.Logger.Info("[Check] Cluster split detected, the Redis manager will recover from the issue, but data may be lost")
 	var errs []error
 	// only one cluster may remain
@@ -43,13 +43,9 @@ func (c *CheckAndHeal) reassignClusters(admin redisutil.IAdmin, config *config.R
 	// reconfigure bad clusters
 	for _, cluster := range badClusters {
 		c.Logger.Info(fmt.Sprintf("[Check] All keys stored in redis cluster '%s' will be lost", cluster))
-		clusterAdmin := redisutil.NewAdmin(cluster,
-			&redisutil.AdminOptions{
-				ConnectionTimeout:  time.Duration(config.DialTimeout) * time.Millisecond,
-				RenameCommandsFile: config.GetRenameCommandsFile(),
-			})
+		// the nodes are connected on demand with the password and the TLS configuration of the admin
 		for _, nodeAddr := range cluster {
-			if err := clusterAdmin.FlushAndReset(nodeAddr, redisutil.ResetHard); err != nil {
+			if err := admin.FlushAndReset(nodeAddr, redisutil.ResetHard); err != nil {
 				c.Logger.Error(err, "unable to flush the node", "node", nodeAddr)
 				errs = append(errs, err)
 			}
@@ -59,7 +55,6 @@ func (c *CheckAndHeal) reassignClusters(admin redisutil.IAdmin, config *config.R
 			}
 
 		}
-		clusterAdmin.Close()
 	}
 
 	return errors.NewAggregate(errs)
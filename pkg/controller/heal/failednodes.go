@@ -15,11 +15,13 @@ func (c *CheckAndHeal) FixFailedNodes(cluster *redisv1alpha1.DistributedRedisClu
 	for id := range forgetSet {
 		doneAnAction = true
 		c.Logger.Info("[FixFailedNodes] Forgetting failed node, this command might fail, this is not an error", "node", id)
-		if !c.DryRun {
-			c.Logger.Info("[FixFailedNodes] try to forget node", "nodeId", id)
-			if err := admin.ForgetNode(id); err != nil {
-				errs = append(errs, err)
-			}
+		if c.DryRun {
+			c.Plan.Add("forget failed node %s", id)
+			continue
+		}
+		c.Logger.Info("[FixFailedNodes] try to forget node", "nodeId", id)
+		if err := admin.ForgetNode(id); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
@@ -5,6 +5,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 )
 
 type CheckAndHeal struct {
@@ -12,4 +13,8 @@ type CheckAndHeal struct {
 	PodControl k8sutil.IPodControl
 	Pods       []*corev1.Pod
 	DryRun     bool
+	// Zones are the topology domains of the kubernetes nodes, by node name
+	Zones map[string]string
+	// Plan records the actions skipped in DryRun mode, it may be nil
+	Plan *redisutil.Plan
 }
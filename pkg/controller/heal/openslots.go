@@ -0,0 +1,166 @@
+package heal
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/errors"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+// FixOpenSlots finishes or rolls back the migrations of slots interrupted by a restart of the operator, like
+// redis-cli --cluster fix: the keys of a slot left IMPORTING or MIGRATING are moved to a single node, the states
+// are cleared and the slot is assigned to that node.
+func (c *CheckAndHeal) FixOpenSlots(cluster *redisv1alpha1.DistributedRedisCluster, infos *redisutil.ClusterInfos, admin redisutil.IAdmin) (bool, error) {
+	if infos == nil || infos.Infos == nil {
+		return false, nil
+	}
+	nodes := infos.GetNodes()
+	slots := listOpenSlots(nodes)
+	if len(slots) == 0 {
+		return false, nil
+	}
+
+	var errs []error
+	for _, slot := range slots {
+		c.Logger.Info("[FixOpenSlots] found open slot", "slot", slot)
+		if c.DryRun {
+			c.Plan.Add("finish or roll back the migration of slot %s", slot)
+			continue
+		}
+		if err := c.fixOpenSlot(admin, nodes, slot); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return true, errors.NewAggregate(errs)
+}
+
+func (c *CheckAndHeal) fixOpenSlot(admin redisutil.IAdmin, nodes redisutil.Nodes, slot redisutil.Slot) error {
+	involved := slotNodes(nodes, slot)
+	keys := map[string]int64{}
+	for _, node := range involved {
+		count, err := admin.CountKeysInSlot(node.IPPort(), slot)
+		if err != nil {
+			return err
+		}
+		keys[node.ID] = count
+	}
+
+	owner, sources, orphan := planOpenSlotFix(involved, slot, keys)
+	c.Logger.Info("[FixOpenSlots] moving the keys of the slot", "slot", slot, "owner", owner.IPPort(), "sources", sources.String())
+	if orphan {
+		if err := admin.SetSlot(owner.IPPort(), "STABLE", slot, ""); err != nil {
+			return err
+		}
+		if err := admin.AddSlots(owner.IPPort(), []redisutil.Slot{slot}); err != nil {
+			return err
+		}
+	}
+	for _, source := range sources {
+		if _, err := admin.MigrateKeysInSlot(source.IPPort(), owner, slot, redisutil.DefaultMigrateOptions); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range involved {
+		if err := admin.SetSlot(node.IPPort(), "STABLE", slot, ""); err != nil {
+			return err
+		}
+	}
+	// the owner is assigned the slot first, so that it is the reference of the other nodes
+	if err := admin.SetSlot(owner.IPPort(), "NODE", slot, owner.ID); err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.ID == owner.ID || node.GetRole() != redisv1alpha1.RedisClusterNodeRoleMaster {
+			continue
+		}
+		if err := admin.SetSlot(node.IPPort(), "NODE", slot, owner.ID); err != nil {
+			c.Logger.Error(err, "[FixOpenSlots] SETSLOT NODE", "node", node.IPPort(), "slot", slot)
+		}
+	}
+	return nil
+}
+
+// listOpenSlots returns the slots in IMPORTING or MIGRATING state on any node.
+func listOpenSlots(nodes redisutil.Nodes) []redisutil.Slot {
+	open := map[redisutil.Slot]bool{}
+	for _, node := range nodes {
+		for slot := range node.MigratingSlots {
+			open[slot] = true
+		}
+		for slot := range node.ImportingSlots {
+			open[slot] = true
+		}
+	}
+	var slots []redisutil.Slot
+	for slot := range open {
+		slots = append(slots, slot)
+	}
+	sort.Sort(redisutil.SlotSlice(slots))
+	return slots
+}
+
+// slotNodes returns the nodes owning the slot, or importing or migrating it.
+func slotNodes(nodes redisutil.Nodes, slot redisutil.Slot) redisutil.Nodes {
+	return nodes.FilterByFunc(func(node *redisutil.Node) bool {
+		_, migrating := node.MigratingSlots[slot]
+		_, importing := node.ImportingSlots[slot]
+		return migrating || importing || redisutil.Contains(node.Slots, slot)
+	})
+}
+
+// planOpenSlotFix returns the node the slot is assigned to and the nodes its keys are moved from, keys is the
+// number of keys of the slot on each node. The migration is finished when the destination is importing the slot
+// from its owner, and rolled back to the owner otherwise. Without owner, the slot is assigned to the node with
+// the most keys, and orphan is true.
+func planOpenSlotFix(nodes redisutil.Nodes, slot redisutil.Slot, keys map[string]int64) (owner *redisutil.Node, sources redisutil.Nodes, orphan bool) {
+	nodes = nodes.SortByFunc(redisutil.LessByID)
+	var owners redisutil.Nodes
+	for _, node := range nodes {
+		if redisutil.Contains(node.Slots, slot) {
+			owners = append(owners, node)
+		}
+	}
+	mostKeys := func(candidates redisutil.Nodes) *redisutil.Node {
+		var found *redisutil.Node
+		for _, node := range candidates {
+			if found == nil || keys[node.ID] > keys[found.ID] {
+				found = node
+			}
+		}
+		return found
+	}
+
+	for _, source := range nodes {
+		destID, ok := source.MigratingSlots[slot]
+		if !ok || len(owners) > 1 || (len(owners) == 1 && owners[0].ID != source.ID) {
+			continue
+		}
+		for _, dest := range nodes {
+			if dest.ID == destID && dest.ImportingSlots[slot] == source.ID {
+				owner = dest
+			}
+		}
+	}
+	if owner == nil {
+		switch len(owners) {
+		case 0:
+			owner = mostKeys(nodes)
+			orphan = true
+		case 1:
+			owner = owners[0]
+		default:
+			owner = mostKeys(owners)
+		}
+	}
+
+	for _, node := range nodes {
+		if node.ID != owner.ID && keys[node.ID] > 0 {
+			sources = append(sources, node)
+		}
+	}
+	return owner, sources, orphan
+}
@@ -0,0 +1,98 @@
+package heal
+
+import (
+	"testing"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func newOpenSlotNode(id string, slots []redisutil.Slot, migrating, importing map[redisutil.Slot]string) *redisutil.Node {
+	return &redisutil.Node{
+		ID:             id,
+		Role:           redisutil.RedisMasterRole,
+		IP:             "10.0.0." + id,
+		Port:           "6379",
+		Slots:          slots,
+		MigratingSlots: migrating,
+		ImportingSlots: importing,
+	}
+}
+
+func Test_planOpenSlotFix(t *testing.T) {
+	slot := redisutil.Slot(7)
+	testCases := []struct {
+		name    string
+		nodes   redisutil.Nodes
+		keys    map[string]int64
+		owner   string
+		sources []string
+		orphan  bool
+	}{
+		{
+			name: "migration is finished",
+			nodes: redisutil.Nodes{
+				newOpenSlotNode("1", []redisutil.Slot{slot}, map[redisutil.Slot]string{slot: "2"}, nil),
+				newOpenSlotNode("2", nil, nil, map[redisutil.Slot]string{slot: "1"}),
+			},
+			keys:    map[string]int64{"1": 5, "2": 3},
+			owner:   "2",
+			sources: []string{"1"},
+		},
+		{
+			name: "importing only is rolled back",
+			nodes: redisutil.Nodes{
+				newOpenSlotNode("1", []redisutil.Slot{slot}, nil, nil),
+				newOpenSlotNode("2", nil, nil, map[redisutil.Slot]string{slot: "1"}),
+			},
+			keys:    map[string]int64{"1": 5, "2": 3},
+			owner:   "1",
+			sources: []string{"2"},
+		},
+		{
+			name: "migrating to another node is rolled back",
+			nodes: redisutil.Nodes{
+				newOpenSlotNode("1", []redisutil.Slot{slot}, map[redisutil.Slot]string{slot: "3"}, nil),
+				newOpenSlotNode("2", nil, nil, map[redisutil.Slot]string{slot: "1"}),
+			},
+			keys:  map[string]int64{"1": 5},
+			owner: "1",
+		},
+		{
+			name: "owner with the most keys",
+			nodes: redisutil.Nodes{
+				newOpenSlotNode("1", []redisutil.Slot{slot}, map[redisutil.Slot]string{slot: "2"}, nil),
+				newOpenSlotNode("2", []redisutil.Slot{slot}, nil, map[redisutil.Slot]string{slot: "1"}),
+			},
+			keys:    map[string]int64{"1": 2, "2": 4},
+			owner:   "2",
+			sources: []string{"1"},
+		},
+		{
+			name: "orphan slot",
+			nodes: redisutil.Nodes{
+				newOpenSlotNode("1", nil, nil, map[redisutil.Slot]string{slot: "3"}),
+				newOpenSlotNode("2", nil, nil, map[redisutil.Slot]string{slot: "3"}),
+			},
+			keys:   map[string]int64{"2": 1},
+			owner:  "2",
+			orphan: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			owner, sources, orphan := planOpenSlotFix(tc.nodes, slot, tc.keys)
+			if owner.ID != tc.owner || orphan != tc.orphan {
+				t.Errorf("expected owner %s and orphan %v, got %s and %v", tc.owner, tc.orphan, owner.ID, orphan)
+			}
+			if len(sources) != len(tc.sources) {
+				t.Fatalf("expected sources %v, got %v", tc.sources, sources)
+			}
+			for i := range sources {
+				if sources[i].ID != tc.sources[i] {
+					t.Errorf("expected sources %v, got %v", tc.sources, sources)
+				}
+			}
+		})
+	}
+}
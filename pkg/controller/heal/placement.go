@@ -0,0 +1,226 @@
+package heal
+
+import (
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+// placementFixes records the changes made to the placement of the nodes of each cluster, to rate-limit them.
+var placementFixes = &fixLimiter{history: map[string][]time.Time{}}
+
+// placementAction is a change of the placement of the nodes which doesn't move data: either the failover of
+// a slave, or two slaves exchanging their masters.
+type placementAction struct {
+	failover *redisutil.Node
+	swap     redisutil.Nodes
+}
+
+// FixPlacement fixes the masters in the same topology domain as one of their slaves, and the masters sharing
+// a domain, since losing the domain would lose every copy of their slots. A slave in another domain is failed
+// over, or when there is none, a slave in the domain of its master is exchanged with a slave of another master.
+// The number of changes per interval is limited by the operator configuration.
+func (c *CheckAndHeal) FixPlacement(cluster *redisv1alpha1.DistributedRedisCluster, infos *redisutil.ClusterInfos, admin redisutil.IAdmin, config *config.Redis) (bool, error) {
+	if config.MaxPlacementFixes <= 0 || infos == nil || infos.Status != redisutil.ClusterInfosConsistent {
+		return false, nil
+	}
+
+	var nodes redisutil.Nodes
+	for _, node := range infos.GetNodes() {
+		placed := *node
+		placed.Zone, placed.StatefulSet = c.placementOf(node)
+		nodes = append(nodes, &placed)
+	}
+	actions := planPlacementFixes(nodes)
+
+	key := cluster.Namespace + "/" + cluster.Name
+	doneAnAction := false
+	for _, action := range actions {
+		// a plan doesn't use up the changes allowed by the limit
+		if !c.DryRun && !placementFixes.allow(key, time.Now(), config.PlacementFixInterval, config.MaxPlacementFixes) {
+			c.Logger.Info("[FixPlacement] too many changes of the placement, postponed", "interval", config.PlacementFixInterval)
+			break
+		}
+		doneAnAction = true
+		if action.failover != nil {
+			c.Logger.Info("[FixPlacement] failover", "slave", action.failover.IPPort(), "zone", action.failover.Zone)
+			if c.DryRun {
+				c.Plan.Add("failover %s to fix the placement", action.failover.IPPort())
+			} else {
+				if err := admin.StartFailover(action.failover.IPPort()); err != nil {
+					return doneAnAction, err
+				}
+			}
+			continue
+		}
+		slaveA, slaveB := action.swap[0], action.swap[1]
+		c.Logger.Info("[FixPlacement] exchange the masters of slaves", "slaveA", slaveA.IPPort(), "slaveB", slaveB.IPPort())
+		if c.DryRun {
+			c.Plan.Add("exchange the masters of %s and %s to fix the placement", slaveA.IPPort(), slaveB.IPPort())
+		} else {
+			masterA, masterB := slaveA.MasterReferent, slaveB.MasterReferent
+			if err := admin.AttachSlaveToMaster(slaveA, masterB); err != nil {
+				return doneAnAction, err
+			}
+			if err := admin.AttachSlaveToMaster(slaveB, masterA); err != nil {
+				return doneAnAction, err
+			}
+		}
+	}
+
+	return doneAnAction, nil
+}
+
+// placementOf returns the topology domain and the statefulSet of the pod of the node. The domain is the zone of
+// its kubernetes node when the cluster has a topologyKey, else the kubernetes node itself, empty when the pod is
+// not found.
+func (c *CheckAndHeal) placementOf(node *redisutil.Node) (string, string) {
+	for _, pod := range c.Pods {
+		if pod.Status.PodIP != node.IP || pod.Spec.NodeName == "" {
+			continue
+		}
+		statefulSet := ""
+		if owner := metav1.GetControllerOf(pod); owner != nil {
+			statefulSet = owner.Name
+		}
+		if zone := c.Zones[pod.Spec.NodeName]; zone != "" {
+			return zone, statefulSet
+		}
+		return pod.Spec.NodeName, statefulSet
+	}
+	return "", ""
+}
+
+// planPlacementFixes returns the changes fixing the placement of the nodes, whose Zone is their topology domain
+// and StatefulSet their shard. The slaves of a shard are only attached to the master of the same shard.
+func planPlacementFixes(nodes redisutil.Nodes) []placementAction {
+	masters := nodes.FilterByFunc(redisutil.IsMasterWithSlot).SortByFunc(func(a, b *redisutil.Node) bool {
+		return a.ID < b.ID
+	})
+	slavesByMaster := map[string]redisutil.Nodes{}
+	for _, node := range nodes {
+		if redisutil.IsSlave(node) && node.MasterReferent != "" && isHealthy(node) {
+			slavesByMaster[node.MasterReferent] = append(slavesByMaster[node.MasterReferent], node)
+		}
+	}
+	mastersByDomain := map[string]int{}
+	for _, master := range masters {
+		if master.Zone != "" {
+			mastersByDomain[master.Zone]++
+		}
+	}
+
+	var actions []placementAction
+	moved := map[string]bool{}
+	for _, master := range masters {
+		if master.Zone == "" || moved[master.ID] {
+			continue
+		}
+		var colocated redisutil.Nodes
+		for _, slave := range slavesByMaster[master.ID] {
+			if slave.Zone == master.Zone {
+				colocated = append(colocated, slave)
+			}
+		}
+		if mastersByDomain[master.Zone] <= 1 && len(colocated) == 0 {
+			continue
+		}
+
+		if slave := failoverCandidate(slavesByMaster[master.ID], master, mastersByDomain); slave != nil {
+			actions = append(actions, placementAction{failover: slave})
+			mastersByDomain[master.Zone]--
+			mastersByDomain[slave.Zone]++
+			moved[master.ID] = true
+			continue
+		}
+
+		for _, slave := range colocated {
+			if other := swapCandidate(masters, slavesByMaster, master, slave, moved); other != nil {
+				actions = append(actions, placementAction{swap: redisutil.Nodes{slave, other}})
+				moved[slave.ID] = true
+				moved[other.ID] = true
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// failoverCandidate returns a slave of the master in a domain without master nor other slave of the master.
+func failoverCandidate(slaves redisutil.Nodes, master *redisutil.Node, mastersByDomain map[string]int) *redisutil.Node {
+	for _, slave := range slaves {
+		if slave.Zone == "" || slave.Zone == master.Zone || mastersByDomain[slave.Zone] > 0 {
+			continue
+		}
+		alone := true
+		for _, other := range slaves {
+			if other.ID != slave.ID && other.Zone == slave.Zone {
+				alone = false
+				break
+			}
+		}
+		if alone {
+			return slave
+		}
+	}
+	return nil
+}
+
+// swapCandidate returns a slave of another master of the same shard, which can be attached to the master of
+// the given slave while the given slave is attached to its master, each in another domain than its new master.
+func swapCandidate(masters redisutil.Nodes, slavesByMaster map[string]redisutil.Nodes, master, slave *redisutil.Node, moved map[string]bool) *redisutil.Node {
+	for _, otherMaster := range masters {
+		if otherMaster.ID == master.ID || otherMaster.Zone == "" || otherMaster.Zone == slave.Zone ||
+			!isSameShard(slave, otherMaster) {
+			continue
+		}
+		for _, other := range slavesByMaster[otherMaster.ID] {
+			if moved[other.ID] || other.Zone == "" || other.Zone == master.Zone || !isSameShard(other, master) {
+				continue
+			}
+			return other
+		}
+	}
+	return nil
+}
+
+// isSameShard returns false if both nodes belong to a statefulSet and the statefulSets are different.
+func isSameShard(nodeA, nodeB *redisutil.Node) bool {
+	if nodeA.StatefulSet == "" || nodeB.StatefulSet == "" {
+		return true
+	}
+	return nodeA.StatefulSet == nodeB.StatefulSet
+}
+
+func isHealthy(node *redisutil.Node) bool {
+	return !node.HasStatus(redisutil.NodeStatusFail) && !node.HasStatus(redisutil.NodeStatusPFail)
+}
+
+// fixLimiter limits the number of changes made to a cluster during an interval.
+type fixLimiter struct {
+	sync.Mutex
+	history map[string][]time.Time
+}
+
+// allow records a change of the cluster and returns true if less than max changes were made during the interval.
+func (l *fixLimiter) allow(key string, now time.Time, interval time.Duration, max int) bool {
+	l.Lock()
+	defer l.Unlock()
+	var recent []time.Time
+	for _, t := range l.history[key] {
+		if now.Sub(t) < interval {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= max {
+		l.history[key] = recent
+		return false
+	}
+	l.history[key] = append(recent, now)
+	return true
+}
@@ -0,0 +1,128 @@
+package heal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func newPlacedNode(id, role, masterID, zone, statefulSet string, slots ...redisutil.Slot) *redisutil.Node {
+	return &redisutil.Node{
+		ID:             id,
+		Role:           role,
+		IP:             "10.0.0." + id,
+		Port:           "6379",
+		MasterReferent: masterID,
+		Slots:          slots,
+		Zone:           zone,
+		StatefulSet:    statefulSet,
+	}
+}
+
+func Test_planPlacementFixes(t *testing.T) {
+	master, slave := redisutil.RedisMasterRole, redisutil.RedisSlaveRole
+	testCases := []struct {
+		name      string
+		nodes     redisutil.Nodes
+		failovers []string
+		swaps     [][2]string
+	}{
+		{
+			name: "optimal",
+			nodes: redisutil.Nodes{
+				newPlacedNode("1", master, "", "a", "", 0),
+				newPlacedNode("2", master, "", "b", "", 1),
+				newPlacedNode("3", slave, "1", "b", ""),
+				newPlacedNode("4", slave, "2", "a", ""),
+			},
+		},
+		{
+			name: "slave colocated with its master is failed over",
+			nodes: redisutil.Nodes{
+				newPlacedNode("1", master, "", "a", "drc-test-0", 0),
+				newPlacedNode("2", master, "", "b", "drc-test-1", 1),
+				newPlacedNode("3", slave, "1", "a", "drc-test-0"),
+				newPlacedNode("4", slave, "1", "c", "drc-test-0"),
+				newPlacedNode("5", slave, "2", "a", "drc-test-1"),
+			},
+			failovers: []string{"4"},
+		},
+		{
+			name: "masters sharing a domain",
+			nodes: redisutil.Nodes{
+				newPlacedNode("1", master, "", "a", "", 0),
+				newPlacedNode("2", master, "", "a", "", 1),
+				newPlacedNode("3", slave, "1", "b", ""),
+				newPlacedNode("4", slave, "2", "c", ""),
+			},
+			failovers: []string{"3"},
+		},
+		{
+			name: "slaves exchange their masters",
+			nodes: redisutil.Nodes{
+				newPlacedNode("1", master, "", "a", "", 0),
+				newPlacedNode("2", master, "", "b", "", 1),
+				newPlacedNode("3", slave, "1", "a", ""),
+				newPlacedNode("4", slave, "2", "b", ""),
+			},
+			swaps: [][2]string{{"3", "4"}},
+		},
+		{
+			name: "slaves of different shards are not exchanged",
+			nodes: redisutil.Nodes{
+				newPlacedNode("1", master, "", "a", "drc-test-0", 0),
+				newPlacedNode("2", master, "", "b", "drc-test-1", 1),
+				newPlacedNode("3", slave, "1", "a", "drc-test-0"),
+				newPlacedNode("4", slave, "2", "b", "drc-test-1"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var failovers []string
+			var swaps [][2]string
+			for _, action := range planPlacementFixes(tc.nodes) {
+				if action.failover != nil {
+					failovers = append(failovers, action.failover.ID)
+				} else {
+					swaps = append(swaps, [2]string{action.swap[0].ID, action.swap[1].ID})
+				}
+			}
+			if len(failovers) != len(tc.failovers) || len(swaps) != len(tc.swaps) {
+				t.Fatalf("expected failovers %v and swaps %v, got %v and %v", tc.failovers, tc.swaps, failovers, swaps)
+			}
+			for i := range failovers {
+				if failovers[i] != tc.failovers[i] {
+					t.Errorf("expected failovers %v, got %v", tc.failovers, failovers)
+				}
+			}
+			for i := range swaps {
+				if swaps[i] != tc.swaps[i] {
+					t.Errorf("expected swaps %v, got %v", tc.swaps, swaps)
+				}
+			}
+		})
+	}
+}
+
+func Test_fixLimiter(t *testing.T) {
+	limiter := &fixLimiter{history: map[string][]time.Time{}}
+	now := time.Now()
+	if !limiter.allow("default/test", now, time.Minute, 2) {
+		t.Errorf("first change should be allowed")
+	}
+	if !limiter.allow("default/test", now, time.Minute, 2) {
+		t.Errorf("second change should be allowed")
+	}
+	if limiter.allow("default/test", now, time.Minute, 2) {
+		t.Errorf("third change should be limited")
+	}
+	if !limiter.allow("default/other", now, time.Minute, 2) {
+		t.Errorf("changes of another cluster should be allowed")
+	}
+	if !limiter.allow("default/test", now.Add(time.Minute), time.Minute, 2) {
+		t.Errorf("change after the interval should be allowed")
+	}
+}
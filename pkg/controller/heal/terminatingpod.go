@@ -29,11 +29,13 @@ func (c *CheckAndHeal) FixTerminatingPods(cluster *redisv1alpha1.DistributedRedi
 			c.Logger.Info("[FixTerminatingPods] found deletion pod", "podName", pod.Name)
 			actionDone = true
 			// it means that this pod should already been deleted since a wild
-			if !c.DryRun {
-				c.Logger.Info("[FixTerminatingPods] try to delete pod", "podName", pod.Name)
-				if err := c.PodControl.DeletePodByName(cluster.Namespace, pod.Name); err != nil {
-					errs = append(errs, err)
-				}
+			if c.DryRun {
+				c.Plan.Add("force the deletion of terminating pod %s", pod.Name)
+				continue
+			}
+			c.Logger.Info("[FixTerminatingPods] try to delete pod", "podName", pod.Name)
+			if err := c.PodControl.DeletePodByName(cluster.Namespace, pod.Name); err != nil {
+				errs = append(errs, err)
 			}
 		}
 	}
@@ -34,18 +34,23 @@ func (c *CheckAndHeal) FixUntrustedNodes(cluster *redisv1alpha1.DistributedRedis
 			continue
 		}
 		exist, reused := checkIfPodNameExistAndIsReused(uNode, c.Pods)
+		doneAnAction = true
+		if c.DryRun {
+			if exist && !reused {
+				c.Plan.Add("delete pod %s", uNode.PodName)
+			}
+			c.Plan.Add("forget untrusted node %s", id)
+			continue
+		}
 		if exist && !reused {
 			c.Logger.Info("[FixUntrustedNodes] try to delete pod", "podName", uNode.PodName)
 			if err := c.PodControl.DeletePodByName(cluster.Namespace, uNode.PodName); err != nil {
 				errs = append(errs, err)
 			}
 		}
-		doneAnAction = true
-		if !c.DryRun {
-			c.Logger.Info("[FixUntrustedNodes] try to forget node", "nodeId", id)
-			if err := admin.ForgetNode(id); err != nil {
-				errs = append(errs, err)
-			}
+		c.Logger.Info("[FixUntrustedNodes] try to forget node", "nodeId", id)
+		if err := admin.ForgetNode(id); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
@@ -3,7 +3,6 @@ package manager
 import (
 	"fmt"
 
-	appsv1 "k8s.io/api/apps/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
@@ -17,8 +16,7 @@ type ICheck interface {
 }
 
 type realCheck struct {
-	statefulSetClient  k8sutil.IStatefulSetControl
-	clusterStatefulSet *appsv1.StatefulSet
+	statefulSetClient k8sutil.IStatefulSetControl
 }
 
 func NewCheck(client client.Client) ICheck {
@@ -27,35 +25,34 @@ func NewCheck(client client.Client) ICheck {
 	}
 }
 
-func (c *realCheck) init(cluster *redisv1alpha1.DistributedRedisCluster) error {
-	ss, err := c.statefulSetClient.GetStatefulSet(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name))
-	if err != nil {
-		return err
+func (c *realCheck) CheckRedisNodeNum(cluster *redisv1alpha1.DistributedRedisCluster) error {
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		ss, err := c.statefulSetClient.GetStatefulSet(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name, i))
+		if err != nil {
+			return err
+		}
+		// during a scale down the statefulSet keeps its replicas until the removed nodes are drained
+		if cluster.Spec.ClusterReplicas+1 > *ss.Spec.Replicas {
+			return fmt.Errorf("number of redis pods is different from specification")
+		}
 	}
-	c.clusterStatefulSet = ss
-	return nil
-}
 
-func (c *realCheck) CheckRedisNodeNum(cluster *redisv1alpha1.DistributedRedisCluster) error {
-	err := c.init(cluster)
+	ssList, err := c.statefulSetClient.ListStatefulSetByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
 	if err != nil {
 		return err
 	}
-	expectNodeNum := cluster.Spec.MasterSize * (cluster.Spec.ClusterReplicas + 1)
-	if expectNodeNum != *c.clusterStatefulSet.Spec.Replicas {
-		return fmt.Errorf("number of redis pods is different from specification")
-	}
-	if expectNodeNum != c.clusterStatefulSet.Status.ReadyReplicas {
-		return fmt.Errorf("redis pods are not all ready")
+	for _, ss := range ssList.Items {
+		if *ss.Spec.Replicas != ss.Status.ReadyReplicas {
+			return fmt.Errorf("redis pods of statefulSet %s are not all ready", ss.Name)
+		}
 	}
 
 	return nil
 }
 
 func (c *realCheck) CheckRedisMasterNum(cluster *redisv1alpha1.DistributedRedisCluster) error {
-	if c.clusterStatefulSet == nil {
-		c.init(cluster)
-	}
 	if cluster.Spec.MasterSize != cluster.Status.NumberOfMaster {
 		return fmt.Errorf("number of redis master different from specification")
 	}
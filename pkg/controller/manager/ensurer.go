@@ -1,28 +1,48 @@
 package manager
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"strconv"
 
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/certificates"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/monitors"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/poddisruptionbudgets"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
 type IEnsureResource interface {
-	EnsureRedisStatefulset(cluster *redisv1alpha1.DistributedRedisCluster,
+	EnsureRedisStatefulsets(cluster *redisv1alpha1.DistributedRedisCluster,
 		backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) error
 	EnsureRedisHeadLessSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisPodSvcs(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 	EnsureRedisConfigMap(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 	EnsureRedisOSMSecret(cluster *redisv1alpha1.DistributedRedisCluster,
 		backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) error
+	EnsureRedisMonitor(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	DeleteRedisMonitors(cluster *redisv1alpha1.DistributedRedisCluster) error
+	EnsureRedisCertificate(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisACLSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 }
 
 type realEnsureResource struct {
@@ -30,45 +50,79 @@ type realEnsureResource struct {
 	svcClient         k8sutil.IServiceControl
 	configMapClient   k8sutil.IConfigMapControl
 	pdbClient         k8sutil.IPodDisruptionBudgetControl
+	monitorClient     k8sutil.IMonitorControl
 	crClient          k8sutil.ICustomResource
 	client            client.Client
-	logger            logr.Logger
+	// watchNamespace is the namespace watched by the operator, empty when it is cluster-scoped
+	watchNamespace string
+	logger         logr.Logger
 }
 
-func NewEnsureResource(client client.Client, logger logr.Logger) IEnsureResource {
+func NewEnsureResource(client client.Client, watchNamespace string, logger logr.Logger) IEnsureResource {
 	return &realEnsureResource{
 		statefulSetClient: k8sutil.NewStatefulSetController(client),
 		svcClient:         k8sutil.NewServiceController(client),
 		configMapClient:   k8sutil.NewConfigMapController(client),
 		pdbClient:         k8sutil.NewPodDisruptionBudgetController(client),
+		monitorClient:     k8sutil.NewMonitorController(client),
 		crClient:          k8sutil.NewCRControl(client),
 		client:            client,
+		watchNamespace:    watchNamespace,
 		logger:            logger,
 	}
 }
 
-func (r *realEnsureResource) EnsureRedisStatefulset(cluster *redisv1alpha1.DistributedRedisCluster,
+// EnsureRedisStatefulsets ensures the statefulSet of every shard. The statefulSets of the shards beyond
+// spec.masterSize are deleted by the controller once their slots have been moved.
+func (r *realEnsureResource) EnsureRedisStatefulsets(cluster *redisv1alpha1.DistributedRedisCluster,
 	backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) error {
 	if err := r.ensureRedisPDB(cluster, labels); err != nil {
 		return err
 	}
 
-	name := statefulsets.ClusterStatefulSetName(cluster.Name)
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		if err := r.ensureRedisStatefulset(cluster, i, backup, labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *realEnsureResource) ensureRedisStatefulset(cluster *redisv1alpha1.DistributedRedisCluster, shard int,
+	backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) error {
+	name := statefulsets.ClusterStatefulSetName(cluster.Name, shard)
 	ss, err := r.statefulSetClient.GetStatefulSet(cluster.Namespace, name)
 	if err == nil {
-		if (cluster.Spec.MasterSize * (cluster.Spec.ClusterReplicas + 1)) != *ss.Spec.Replicas {
+		newSS, err := statefulsets.NewStatefulSetForCR(cluster, shard, backup, labels)
+		if err != nil {
+			return err
+		}
+		// the statefulSet is only scaled up here, scaling down is done by the controller
+		// once the redis nodes of the removed pods no longer own any slot.
+		if *newSS.Spec.Replicas > *ss.Spec.Replicas {
 			r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", name).
 				Info("scaling statefulSet")
-			newSS, err := statefulsets.NewStatefulSetForCR(cluster, backup, labels)
-			if err != nil {
-				return err
-			}
-			return r.statefulSetClient.UpdateStatefulSet(newSS)
+		} else if statefulsets.IsTemplateChanged(ss, newSS) {
+			// the pods are not restarted by the statefulSet controller, the rolling update is driven by
+			// the operator to failover the masters first.
+			r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", name).
+				Info("updating statefulSet template")
+			newSS.Spec.Replicas = ss.Spec.Replicas
+		} else if statefulsets.IsTemplateEdited(ss, newSS) {
+			// the template was edited by hand
+			r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", name).
+				Info("reverting manual changes to the statefulSet template")
+			newSS.Spec.Replicas = ss.Spec.Replicas
+		} else {
+			return nil
 		}
+		// volumeClaimTemplates is forbidden to be updated
+		newSS.Spec.VolumeClaimTemplates = ss.Spec.VolumeClaimTemplates
+		return r.statefulSetClient.UpdateStatefulSet(newSS)
 	} else if err != nil && errors.IsNotFound(err) {
 		r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", name).
 			Info("creating a new statefulSet")
-		newSS, err := statefulsets.NewStatefulSetForCR(cluster, backup, labels)
+		newSS, err := statefulsets.NewStatefulSetForCR(cluster, shard, backup, labels)
 		if err != nil {
 			return err
 		}
@@ -78,40 +132,165 @@ func (r *realEnsureResource) EnsureRedisStatefulset(cluster *redisv1alpha1.Distr
 }
 
 func (r *realEnsureResource) ensureRedisPDB(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
-	_, err := r.pdbClient.GetPodDisruptionBudget(cluster.Namespace, cluster.Name)
+	newPDB := poddisruptionbudgets.NewPodDisruptionBudgetForCR(cluster, labels)
+	pdb, err := r.pdbClient.GetPodDisruptionBudget(cluster.Namespace, cluster.Name)
 	if err != nil && errors.IsNotFound(err) {
 		r.logger.WithValues("PDB.Namespace", cluster.Namespace, "PDB.Name", cluster.Spec.ServiceName).
 			Info("creating a new PodDisruptionBudget")
-		pdb := poddisruptionbudgets.NewPodDisruptionBudgetForCR(cluster, labels)
-		return r.pdbClient.CreatePodDisruptionBudget(pdb)
+		return r.pdbClient.CreatePodDisruptionBudget(newPDB)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepDerivative(newPDB.Spec, pdb.Spec) {
+		return nil
+	}
+	// the spec of a PodDisruptionBudget is immutable, it is recreated by the next reconcile
+	r.logger.WithValues("PDB.Namespace", cluster.Namespace, "PDB.Name", cluster.Name).
+		Info("deleting the PodDisruptionBudget changed by hand")
+	return r.pdbClient.DeletePodDisruptionBudget(pdb)
 }
 
 func (r *realEnsureResource) EnsureRedisHeadLessSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
-	_, err := r.svcClient.GetService(cluster.Namespace, cluster.Spec.ServiceName)
+	newSvc := services.NewHeadLessSvcForCR(cluster, labels)
+	svc, err := r.svcClient.GetService(cluster.Namespace, cluster.Spec.ServiceName)
 	if err != nil && errors.IsNotFound(err) {
 		r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", cluster.Spec.ServiceName).
 			Info("creating a new headless service")
-		svc := services.NewHeadLessSvcForCR(cluster, labels)
-		return r.svcClient.CreateService(svc)
+		return r.svcClient.CreateService(newSvc)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return r.revertServiceChanges(svc, newSvc)
+}
+
+// revertServiceChanges restores the ports and the selector of a service edited by hand. The target ports are
+// compared with their default, the port, and the node ports allocated by the apiserver are kept.
+func (r *realEnsureResource) revertServiceChanges(svc, newSvc *corev1.Service) error {
+	for i := range newSvc.Spec.Ports {
+		port := &newSvc.Spec.Ports[i]
+		if port.TargetPort == (intstr.IntOrString{}) {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		for _, current := range svc.Spec.Ports {
+			if current.Name == port.Name && port.NodePort == 0 {
+				port.NodePort = current.NodePort
+			}
+		}
+	}
+	if equality.Semantic.DeepDerivative(newSvc.Spec.Ports, svc.Spec.Ports) &&
+		reflect.DeepEqual(newSvc.Spec.Selector, svc.Spec.Selector) {
+		return nil
+	}
+	r.logger.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name).
+		Info("reverting manual changes to the service")
+	svc.Spec.Ports = newSvc.Spec.Ports
+	svc.Spec.Selector = newSvc.Spec.Selector
+	return r.svcClient.UpdateService(svc)
+}
+
+// EnsureRedisSvc ensures the ClusterIP service of the clients exists when spec.expose.clusterIP is set.
+func (r *realEnsureResource) EnsureRedisSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	name := services.ClientServiceName(cluster.Spec.ServiceName)
+	svc, err := r.svcClient.GetService(cluster.Namespace, name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if cluster.Spec.Expose == nil || !cluster.Spec.Expose.ClusterIP {
+		if exists {
+			r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", name).
+				Info("deleting the client service")
+			return r.svcClient.DeleteService(svc)
+		}
+		return nil
+	}
+	if !exists {
+		r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", name).
+			Info("creating a new client service")
+		return r.svcClient.CreateService(services.NewSvcForCR(cluster, labels))
+	}
+	return r.revertServiceChanges(svc, services.NewSvcForCR(cluster, labels))
+}
+
+// EnsureRedisPodSvcs ensures every redis pod is exposed by a service when spec.expose.podServiceType is set,
+// and deletes the services of the pods which no longer exist.
+func (r *realEnsureResource) EnsureRedisPodSvcs(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	expected := map[string]bool{}
+	var svcType corev1.ServiceType
+	if cluster.Spec.Expose != nil {
+		svcType = cluster.Spec.Expose.PodServiceType
+	}
+	if svcType != "" {
+		for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+			for j := 0; j <= int(cluster.Spec.ClusterReplicas); j++ {
+				expected[fmt.Sprintf("%s-%d", statefulsets.ClusterStatefulSetName(cluster.Name, i), j)] = true
+			}
+		}
+	}
+
+	svcList, err := r.svcClient.ListServiceByLabels(cluster.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelClusterName: cluster.Name,
+	})
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for i := range svcList.Items {
+		svc := &svcList.Items[i]
+		podName, ok := svc.Labels[redisv1alpha1.LabelPodName]
+		if !ok {
+			continue
+		}
+		if expected[podName] && svc.Spec.Type == svcType {
+			existing[podName] = true
+			if err := r.revertServiceChanges(svc, services.NewPodSvcForCR(cluster, podName, labels)); err != nil {
+				return err
+			}
+			continue
+		}
+		r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", svc.Name).
+			Info("deleting pod service")
+		if err := r.svcClient.DeleteService(svc); err != nil {
+			return err
+		}
+	}
+
+	for podName := range expected {
+		if existing[podName] {
+			continue
+		}
+		r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", podName).
+			Info("creating a new pod service")
+		if err := r.svcClient.CreateService(services.NewPodSvcForCR(cluster, podName, labels)); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *realEnsureResource) EnsureRedisConfigMap(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
 	cmName := configmaps.RedisConfigMapName(cluster.Name)
-	_, err := r.configMapClient.GetConfigMap(cluster.Namespace, cmName)
+	newCm := configmaps.NewConfigMapForCR(cluster, labels)
+	cm, err := r.configMapClient.GetConfigMap(cluster.Namespace, cmName)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			r.logger.WithValues("ConfigMap.Namespace", cluster.Namespace, "ConfigMap.Name", cmName).
 				Info("creating a new configMap")
-			cm := configmaps.NewConfigMapForCR(cluster, labels)
-			return r.configMapClient.CreateConfigMap(cm)
+			return r.configMapClient.CreateConfigMap(newCm)
 		}
 		return err
 
 	}
+	if !reflect.DeepEqual(cm.Data, newCm.Data) {
+		r.logger.WithValues("ConfigMap.Namespace", cluster.Namespace, "ConfigMap.Name", cmName).
+			Info("updating configMap")
+		cm.Data = newCm.Data
+		if err := r.configMapClient.UpdateConfigMap(cm); err != nil {
+			return err
+		}
+	}
 
 	if cluster.Spec.Init != nil {
 		restoreCmName := configmaps.RestoreConfigMapName(cluster.Name)
@@ -147,3 +326,248 @@ func (r *realEnsureResource) EnsureRedisOSMSecret(cluster *redisv1alpha1.Distrib
 	}
 	return nil
 }
+
+// EnsureRedisMonitor ensures the metrics service and the prometheus-operator ServiceMonitor or PodMonitor
+// of the cluster when spec.monitor is set, and deletes them when it is not.
+func (r *realEnsureResource) EnsureRedisMonitor(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if err := r.ensureRedisMetricsSvc(cluster, labels); err != nil {
+		return err
+	}
+	for _, ensure := range []func() error{
+		func() error { return r.ensureServiceMonitor(cluster) },
+		func() error { return r.ensurePodMonitor(cluster, labels) },
+	} {
+		err := ensure()
+		if meta.IsNoMatchError(err) {
+			// the prometheus-operator CRD is not installed, the exporter is still annotated for Prometheus
+			r.logger.WithValues("Cluster.Namespace", cluster.Namespace, "Cluster.Name", cluster.Name).
+				Info("prometheus-operator is not installed, skip creating monitors", "error", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRedisMonitors deletes the ServiceMonitors and PodMonitors of a deleted cluster, those created in another
+// namespace than the one of the cluster are not garbage collected with it.
+func (r *realEnsureResource) DeleteRedisMonitors(cluster *redisv1alpha1.DistributedRedisCluster) error {
+	// each prometheus-operator CRD may not be installed
+	smList, err := r.monitorClient.ListServiceMonitorByLabels(r.watchNamespace, monitors.Labels(cluster))
+	if err != nil && !meta.IsNoMatchError(err) {
+		return err
+	}
+	if err == nil {
+		for _, sm := range smList.Items {
+			r.logger.WithValues("ServiceMonitor.Namespace", sm.Namespace, "ServiceMonitor.Name", sm.Name).
+				Info("deleting the ServiceMonitor")
+			if err := r.monitorClient.DeleteServiceMonitor(sm); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	pmList, err := r.monitorClient.ListPodMonitorByLabels(r.watchNamespace, monitors.Labels(cluster))
+	if meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, pm := range pmList.Items {
+		r.logger.WithValues("PodMonitor.Namespace", pm.Namespace, "PodMonitor.Name", pm.Name).
+			Info("deleting the PodMonitor")
+		if err := r.monitorClient.DeletePodMonitor(pm); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *realEnsureResource) ensureRedisMetricsSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	name := services.MetricsServiceName(cluster.Spec.ServiceName)
+	svc, err := r.svcClient.GetService(cluster.Namespace, name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if !isMonitorKind(cluster, redisv1alpha1.MonitorKindServiceMonitor) {
+		if exists {
+			r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", name).
+				Info("deleting the metrics service")
+			return r.svcClient.DeleteService(svc)
+		}
+		return nil
+	}
+	newSvc := services.NewMetricsSvcForCR(cluster, labels)
+	if !exists {
+		r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", name).
+			Info("creating a new metrics service")
+		return r.svcClient.CreateService(newSvc)
+	}
+	if reflect.DeepEqual(svc.Spec.Ports, newSvc.Spec.Ports) {
+		return nil
+	}
+	r.logger.WithValues("Service.Namespace", cluster.Namespace, "Service.Name", name).
+		Info("updating the metrics service")
+	svc.Spec.Ports = newSvc.Spec.Ports
+	return r.svcClient.UpdateService(svc)
+}
+
+func (r *realEnsureResource) ensureServiceMonitor(cluster *redisv1alpha1.DistributedRedisCluster) error {
+	smList, err := r.monitorClient.ListServiceMonitorByLabels(r.watchNamespace, monitors.Labels(cluster))
+	if err != nil {
+		return err
+	}
+	var newSM *monitoringv1.ServiceMonitor
+	if isMonitorKind(cluster, redisv1alpha1.MonitorKindServiceMonitor) {
+		newSM = monitors.NewServiceMonitorForCR(cluster)
+	}
+	exists := false
+	for _, sm := range smList.Items {
+		if newSM == nil || sm.Namespace != newSM.Namespace || sm.Name != newSM.Name {
+			// monitoring is turned off, or spec.monitor.prometheus.namespace changed
+			r.logger.WithValues("ServiceMonitor.Namespace", sm.Namespace, "ServiceMonitor.Name", sm.Name).
+				Info("deleting the ServiceMonitor")
+			if err := r.monitorClient.DeleteServiceMonitor(sm); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		exists = true
+		if reflect.DeepEqual(sm.Spec, newSM.Spec) && reflect.DeepEqual(sm.Labels, newSM.Labels) {
+			continue
+		}
+		r.logger.WithValues("ServiceMonitor.Namespace", sm.Namespace, "ServiceMonitor.Name", sm.Name).
+			Info("updating the ServiceMonitor")
+		sm.Spec = newSM.Spec
+		sm.Labels = newSM.Labels
+		if err := r.monitorClient.UpdateServiceMonitor(sm); err != nil {
+			return err
+		}
+	}
+	if newSM == nil || exists {
+		return nil
+	}
+	r.logger.WithValues("ServiceMonitor.Namespace", newSM.Namespace, "ServiceMonitor.Name", newSM.Name).
+		Info("creating a new ServiceMonitor")
+	return r.monitorClient.CreateServiceMonitor(newSM)
+}
+
+func (r *realEnsureResource) ensurePodMonitor(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	pmList, err := r.monitorClient.ListPodMonitorByLabels(r.watchNamespace, monitors.Labels(cluster))
+	if err != nil {
+		return err
+	}
+	var newPM *monitoringv1.PodMonitor
+	if isMonitorKind(cluster, redisv1alpha1.MonitorKindPodMonitor) {
+		newPM = monitors.NewPodMonitorForCR(cluster, labels)
+	}
+	exists := false
+	for _, pm := range pmList.Items {
+		if newPM == nil || pm.Namespace != newPM.Namespace || pm.Name != newPM.Name {
+			// monitoring is turned off, or spec.monitor.prometheus.namespace changed
+			r.logger.WithValues("PodMonitor.Namespace", pm.Namespace, "PodMonitor.Name", pm.Name).
+				Info("deleting the PodMonitor")
+			if err := r.monitorClient.DeletePodMonitor(pm); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		exists = true
+		if reflect.DeepEqual(pm.Spec, newPM.Spec) && reflect.DeepEqual(pm.Labels, newPM.Labels) {
+			continue
+		}
+		r.logger.WithValues("PodMonitor.Namespace", pm.Namespace, "PodMonitor.Name", pm.Name).
+			Info("updating the PodMonitor")
+		pm.Spec = newPM.Spec
+		pm.Labels = newPM.Labels
+		if err := r.monitorClient.UpdatePodMonitor(pm); err != nil {
+			return err
+		}
+	}
+	if newPM == nil || exists {
+		return nil
+	}
+	r.logger.WithValues("PodMonitor.Namespace", newPM.Namespace, "PodMonitor.Name", newPM.Name).
+		Info("creating a new PodMonitor")
+	return r.monitorClient.CreatePodMonitor(newPM)
+}
+
+// EnsureRedisCertificate ensures the cert-manager Certificate of the redis nodes when spec.tls.issuerRef is set,
+// cert-manager keeps the Secret mounted by the redis pods up to date.
+func (r *realEnsureResource) EnsureRedisCertificate(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if cluster.Spec.TLS == nil || cluster.Spec.TLS.IssuerRef == nil {
+		return nil
+	}
+	newCert := certificates.NewCertificateForCR(cluster, labels)
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(certificates.GroupVersionKind)
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: newCert.GetNamespace(),
+		Name:      newCert.GetName(),
+	}, cert)
+	if meta.IsNoMatchError(err) {
+		return fmt.Errorf("cert-manager is not installed, unable to request the certificate of spec.tls.issuerRef: %v", err)
+	}
+	if errors.IsNotFound(err) {
+		r.logger.WithValues("Certificate.Namespace", newCert.GetNamespace(), "Certificate.Name", newCert.GetName()).
+			Info("creating a new Certificate")
+		return r.client.Create(context.TODO(), newCert)
+	}
+	if err != nil {
+		return err
+	}
+	spec, _, err := unstructured.NestedMap(cert.Object, "spec")
+	if err != nil {
+		return err
+	}
+	newSpec := newCert.Object["spec"].(map[string]interface{})
+	changed := false
+	for key, value := range newSpec {
+		if !reflect.DeepEqual(spec[key], value) {
+			spec[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	r.logger.WithValues("Certificate.Namespace", cert.GetNamespace(), "Certificate.Name", cert.GetName()).
+		Info("updating the Certificate")
+	if err := unstructured.SetNestedMap(cert.Object, spec, "spec"); err != nil {
+		return err
+	}
+	return r.client.Update(context.TODO(), cert)
+}
+
+// EnsureRedisACLSecret ensures the Secret holding the password of the ACL user of the operator when spec.users
+// is set, the password is generated once and kept for the life of the cluster.
+func (r *realEnsureResource) EnsureRedisACLSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if !cluster.IsACLEnabled() {
+		return nil
+	}
+	password, err := utils.RandomPassword(24)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cluster.ACLOperatorSecretName(),
+			Namespace:       cluster.Namespace,
+			Labels:          labels,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Data: map[string][]byte{
+			"password": []byte(password),
+		},
+	}
+	return k8sutil.CreateSecret(r.client, secret, r.logger)
+}
+
+// isMonitorKind returns true when the cluster is monitored through a prometheus-operator monitor of the given kind.
+func isMonitorKind(cluster *redisv1alpha1.DistributedRedisCluster, kind redisv1alpha1.MonitorKind) bool {
+	return cluster.Spec.Monitor != nil && cluster.Spec.Monitor.Prometheus != nil &&
+		cluster.Spec.Monitor.Prometheus.MonitorKind == kind
+}
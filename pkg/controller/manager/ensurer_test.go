@@ -0,0 +1,409 @@
+package manager
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/monitors"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// countingStatefulSetControl counts the updates of the statefulSets
+type countingStatefulSetControl struct {
+	k8sutil.IStatefulSetControl
+	updates int
+}
+
+func (c *countingStatefulSetControl) UpdateStatefulSet(ss *appsv1.StatefulSet) error {
+	c.updates++
+	return c.IStatefulSetControl.UpdateStatefulSet(ss)
+}
+
+// countingServiceControl counts the updates of the services
+type countingServiceControl struct {
+	k8sutil.IServiceControl
+	updates int
+}
+
+func (c *countingServiceControl) UpdateService(svc *corev1.Service) error {
+	c.updates++
+	return c.IServiceControl.UpdateService(svc)
+}
+
+func newTestCluster() *redisv1alpha1.DistributedRedisCluster {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			MasterSize:      3,
+			ClusterReplicas: 1,
+			Expose:          &redisv1alpha1.ExposeSpec{PodServiceType: corev1.ServiceTypeNodePort},
+		},
+	}
+	cluster.Default()
+	return cluster
+}
+
+// setStatefulSetDefaults sets the defaults of the apiserver on the pod template of the statefulSet
+func setStatefulSetDefaults(ss *appsv1.StatefulSet) {
+	spec := &ss.Spec.Template.Spec
+	grace := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	spec.TerminationGracePeriodSeconds = &grace
+	spec.RestartPolicy = corev1.RestartPolicyAlways
+	spec.DNSPolicy = corev1.DNSClusterFirst
+	spec.SchedulerName = corev1.DefaultSchedulerName
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		container.TerminationMessagePath = corev1.TerminationMessagePathDefault
+		container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+		if container.ImagePullPolicy == "" {
+			container.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+		for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+			if probe == nil {
+				continue
+			}
+			if probe.TimeoutSeconds == 0 {
+				probe.TimeoutSeconds = 1
+			}
+			probe.PeriodSeconds = 10
+			probe.SuccessThreshold = 1
+			probe.FailureThreshold = 3
+		}
+	}
+	for i := range spec.Volumes {
+		if secret := spec.Volumes[i].Secret; secret != nil && secret.DefaultMode == nil {
+			mode := corev1.SecretVolumeSourceDefaultMode
+			secret.DefaultMode = &mode
+		}
+	}
+}
+
+// setServiceDefaults sets the defaults of the apiserver on the service, and allocates its node ports
+func setServiceDefaults(svc *corev1.Service) {
+	svc.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	for i := range svc.Spec.Ports {
+		port := &svc.Spec.Ports[i]
+		port.Protocol = corev1.ProtocolTCP
+		port.TargetPort = intstr.FromInt(int(port.Port))
+		port.NodePort = int32(30000 + i)
+	}
+}
+
+func Test_realEnsureResource_ensureRedisStatefulset(t *testing.T) {
+	labels := map[string]string{redisv1alpha1.LabelClusterName: "test"}
+	tests := []struct {
+		name       string
+		edit       func(ss *appsv1.StatefulSet)
+		wantUpdate bool
+	}{
+		{
+			name: "defaulted by the apiserver",
+			edit: func(ss *appsv1.StatefulSet) {},
+		},
+		{
+			name: "image edited by hand",
+			edit: func(ss *appsv1.StatefulSet) {
+				ss.Spec.Template.Spec.Containers[0].Image = "redis:latest"
+			},
+			wantUpdate: true,
+		},
+		{
+			name: "probe edited by hand",
+			edit: func(ss *appsv1.StatefulSet) {
+				ss.Spec.Template.Spec.Containers[0].LivenessProbe.FailureThreshold = 10
+			},
+			wantUpdate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			ss, err := statefulsets.NewStatefulSetForCR(cluster, 0, nil, labels)
+			if err != nil {
+				t.Fatalf("NewStatefulSetForCR() error = %v", err)
+			}
+			setStatefulSetDefaults(ss)
+			tt.edit(ss)
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, ss)
+			ssClient := &countingStatefulSetControl{IStatefulSetControl: k8sutil.NewStatefulSetController(client)}
+			r := &realEnsureResource{statefulSetClient: ssClient, client: client, logger: logf.Log}
+			if err := r.ensureRedisStatefulset(cluster, 0, nil, labels); err != nil {
+				t.Fatalf("ensureRedisStatefulset() error = %v", err)
+			}
+			if got := ssClient.updates > 0; got != tt.wantUpdate {
+				t.Errorf("ensureRedisStatefulset() updated = %v, want %v", got, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func Test_realEnsureResource_revertServiceChanges(t *testing.T) {
+	labels := map[string]string{redisv1alpha1.LabelClusterName: "test"}
+	podName := "drc-test-0-0"
+	tests := []struct {
+		name       string
+		edit       func(svc *corev1.Service)
+		wantUpdate bool
+	}{
+		{
+			name: "defaulted by the apiserver",
+			edit: func(svc *corev1.Service) {},
+		},
+		{
+			name: "target port edited by hand",
+			edit: func(svc *corev1.Service) {
+				svc.Spec.Ports[0].TargetPort = intstr.FromInt(6380)
+			},
+			wantUpdate: true,
+		},
+		{
+			name: "selector edited by hand",
+			edit: func(svc *corev1.Service) {
+				svc.Spec.Selector = map[string]string{"app": "redis"}
+			},
+			wantUpdate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			svc := services.NewPodSvcForCR(cluster, podName, labels)
+			setServiceDefaults(svc)
+			tt.edit(svc)
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, svc)
+			svcClient := &countingServiceControl{IServiceControl: k8sutil.NewServiceController(client)}
+			r := &realEnsureResource{svcClient: svcClient, client: client, logger: logf.Log}
+			current, err := svcClient.GetService(cluster.Namespace, podName)
+			if err != nil {
+				t.Fatalf("GetService() error = %v", err)
+			}
+			if err := r.revertServiceChanges(current, services.NewPodSvcForCR(cluster, podName, labels)); err != nil {
+				t.Fatalf("revertServiceChanges() error = %v", err)
+			}
+			if got := svcClient.updates > 0; got != tt.wantUpdate {
+				t.Errorf("revertServiceChanges() updated = %v, want %v", got, tt.wantUpdate)
+			}
+			// the node ports allocated by the apiserver are kept, the clients announce them
+			updated, err := svcClient.GetService(cluster.Namespace, podName)
+			if err != nil {
+				t.Fatalf("GetService() error = %v", err)
+			}
+			for i, port := range updated.Spec.Ports {
+				if port.NodePort != int32(30000+i) {
+					t.Errorf("revertServiceChanges() port %s nodePort = %d, want %d", port.Name, port.NodePort, 30000+i)
+				}
+			}
+		})
+	}
+}
+
+// noMatchMonitorControl fails the lists of the prometheus-operator CRDs which are not installed
+type noMatchMonitorControl struct {
+	k8sutil.IMonitorControl
+	noServiceMonitor bool
+	noPodMonitor     bool
+}
+
+func (c *noMatchMonitorControl) ListServiceMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.ServiceMonitorList, error) {
+	if c.noServiceMonitor {
+		return nil, &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: monitoringv1.SchemeGroupVersion.Group, Kind: monitoringv1.ServiceMonitorsKind}}
+	}
+	return c.IMonitorControl.ListServiceMonitorByLabels(namespace, labels)
+}
+
+func (c *noMatchMonitorControl) ListPodMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.PodMonitorList, error) {
+	if c.noPodMonitor {
+		return nil, &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: monitoringv1.SchemeGroupVersion.Group, Kind: monitoringv1.PodMonitorsKind}}
+	}
+	return c.IMonitorControl.ListPodMonitorByLabels(namespace, labels)
+}
+
+func newMonitoredCluster(kind redisv1alpha1.MonitorKind, namespace string) *redisv1alpha1.DistributedRedisCluster {
+	cluster := newTestCluster()
+	cluster.Spec.Monitor = &redisv1alpha1.AgentSpec{
+		Prometheus: &redisv1alpha1.PrometheusSpec{MonitorKind: kind, Namespace: namespace},
+	}
+	cluster.Default()
+	return cluster
+}
+
+func newMonitorTestResource(t *testing.T, noServiceMonitor, noPodMonitor bool, objs ...runtime.Object) *realEnsureResource {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	if err := monitoringv1.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	c := fake.NewFakeClientWithScheme(s, objs...)
+	return &realEnsureResource{
+		svcClient: k8sutil.NewServiceController(c),
+		monitorClient: &noMatchMonitorControl{
+			IMonitorControl:  k8sutil.NewMonitorController(c),
+			noServiceMonitor: noServiceMonitor,
+			noPodMonitor:     noPodMonitor,
+		},
+		client: c,
+		logger: logf.Log,
+	}
+}
+
+// listMonitors returns the namespaced names of the ServiceMonitors and PodMonitors of the cluster
+func listMonitors(t *testing.T, r *realEnsureResource, cluster *redisv1alpha1.DistributedRedisCluster) ([]string, []string) {
+	monitorClient := r.monitorClient.(*noMatchMonitorControl).IMonitorControl
+	smList, err := monitorClient.ListServiceMonitorByLabels("", monitors.Labels(cluster))
+	if err != nil {
+		t.Fatalf("ListServiceMonitorByLabels() error = %v", err)
+	}
+	pmList, err := monitorClient.ListPodMonitorByLabels("", monitors.Labels(cluster))
+	if err != nil {
+		t.Fatalf("ListPodMonitorByLabels() error = %v", err)
+	}
+	var sms, pms []string
+	for _, sm := range smList.Items {
+		sms = append(sms, sm.Namespace+"/"+sm.Name)
+	}
+	for _, pm := range pmList.Items {
+		pms = append(pms, pm.Namespace+"/"+pm.Name)
+	}
+	sort.Strings(sms)
+	sort.Strings(pms)
+	return sms, pms
+}
+
+func Test_realEnsureResource_EnsureRedisMonitor(t *testing.T) {
+	labels := map[string]string{redisv1alpha1.LabelClusterName: "test"}
+	serviceMonitored := newMonitoredCluster(redisv1alpha1.MonitorKindServiceMonitor, "")
+	podMonitored := newMonitoredCluster(redisv1alpha1.MonitorKindPodMonitor, "")
+	tests := []struct {
+		name             string
+		cluster          *redisv1alpha1.DistributedRedisCluster
+		objs             []runtime.Object
+		noServiceMonitor bool
+		wantSMs          []string
+		wantPMs          []string
+		wantMetricsSvc   bool
+	}{
+		{
+			name:           "service monitor created",
+			cluster:        newMonitoredCluster(redisv1alpha1.MonitorKindServiceMonitor, ""),
+			wantSMs:        []string{"default/test"},
+			wantMetricsSvc: true,
+		},
+		{
+			name:    "monitor kind changed",
+			cluster: newMonitoredCluster(redisv1alpha1.MonitorKindPodMonitor, ""),
+			objs: []runtime.Object{
+				monitors.NewServiceMonitorForCR(serviceMonitored),
+				services.NewMetricsSvcForCR(serviceMonitored, labels),
+			},
+			wantPMs: []string{"default/test"},
+		},
+		{
+			name:           "prometheus namespace changed",
+			cluster:        newMonitoredCluster(redisv1alpha1.MonitorKindServiceMonitor, "monitoring"),
+			objs:           []runtime.Object{monitors.NewServiceMonitorForCR(serviceMonitored)},
+			wantSMs:        []string{"monitoring/test"},
+			wantMetricsSvc: true,
+		},
+		{
+			name:             "ServiceMonitor CRD not installed",
+			cluster:          newMonitoredCluster(redisv1alpha1.MonitorKindPodMonitor, ""),
+			noServiceMonitor: true,
+			wantPMs:          []string{"default/test"},
+		},
+		{
+			name:    "monitoring turned off",
+			cluster: newTestCluster(),
+			objs: []runtime.Object{
+				monitors.NewServiceMonitorForCR(serviceMonitored),
+				monitors.NewPodMonitorForCR(podMonitored, labels),
+				services.NewMetricsSvcForCR(serviceMonitored, labels),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newMonitorTestResource(t, tt.noServiceMonitor, false, tt.objs...)
+			if err := r.EnsureRedisMonitor(tt.cluster, labels); err != nil {
+				t.Fatalf("EnsureRedisMonitor() error = %v", err)
+			}
+			sms, pms := listMonitors(t, r, tt.cluster)
+			if !reflect.DeepEqual(sms, tt.wantSMs) || !reflect.DeepEqual(pms, tt.wantPMs) {
+				t.Errorf("EnsureRedisMonitor() ServiceMonitors = %v and PodMonitors = %v, want %v and %v", sms, pms, tt.wantSMs, tt.wantPMs)
+			}
+			_, err := r.svcClient.GetService(tt.cluster.Namespace, services.MetricsServiceName(tt.cluster.Spec.ServiceName))
+			if got := err == nil; got != tt.wantMetricsSvc {
+				t.Errorf("EnsureRedisMonitor() metrics service exists = %v, want %v", got, tt.wantMetricsSvc)
+			}
+		})
+	}
+}
+
+func Test_realEnsureResource_DeleteRedisMonitors(t *testing.T) {
+	labels := map[string]string{redisv1alpha1.LabelClusterName: "test"}
+	cluster := newMonitoredCluster(redisv1alpha1.MonitorKindServiceMonitor, "monitoring")
+	other := newMonitoredCluster(redisv1alpha1.MonitorKindServiceMonitor, "monitoring")
+	other.Name = "other"
+	tests := []struct {
+		name             string
+		noServiceMonitor bool
+		noPodMonitor     bool
+	}{
+		{
+			name: "monitors deleted",
+		},
+		{
+			name:             "ServiceMonitor CRD not installed",
+			noServiceMonitor: true,
+		},
+		{
+			name:         "PodMonitor CRD not installed",
+			noPodMonitor: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newMonitorTestResource(t, tt.noServiceMonitor, tt.noPodMonitor,
+				monitors.NewServiceMonitorForCR(cluster),
+				monitors.NewPodMonitorForCR(newMonitoredCluster(redisv1alpha1.MonitorKindPodMonitor, ""), labels),
+				monitors.NewServiceMonitorForCR(other),
+			)
+			if err := r.DeleteRedisMonitors(cluster); err != nil {
+				t.Fatalf("DeleteRedisMonitors() error = %v", err)
+			}
+			sms, pms := listMonitors(t, r, cluster)
+			// the monitors of a kind whose CRD is not installed can't be listed
+			if got := len(sms) > 0; got != tt.noServiceMonitor {
+				t.Errorf("DeleteRedisMonitors() ServiceMonitors = %v", sms)
+			}
+			if got := len(pms) > 0; got != tt.noPodMonitor {
+				t.Errorf("DeleteRedisMonitors() PodMonitors = %v", pms)
+			}
+			if sms, _ := listMonitors(t, r, other); len(sms) != 1 {
+				t.Errorf("DeleteRedisMonitors() deleted the monitor of another cluster")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"time"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/heal"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+type IHeal interface {
+	Heal(cluster *redisv1alpha1.DistributedRedisCluster, infos *redisutil.ClusterInfos, admin redisutil.IAdmin) (bool, error)
+	FixTerminatingPods(cluster *redisv1alpha1.DistributedRedisCluster, maxDuration time.Duration) (bool, error)
+}
+
+type realHeal struct {
+	*heal.CheckAndHeal
+}
+
+func NewHealer(heal *heal.CheckAndHeal) IHeal {
+	return &realHeal{heal}
+}
+
+// Heal runs the fixes in order and returns true once one of them made a change, so that the cluster is checked
+// again. In DryRun mode every fix is planned and false is returned, the plan goes on with the clustering.
+func (h *realHeal) Heal(cluster *redisv1alpha1.DistributedRedisCluster, infos *redisutil.ClusterInfos, admin redisutil.IAdmin) (bool, error) {
+	fixes := []struct {
+		name string
+		fix  func() (bool, error)
+	}{
+		{"FixFailedNodes", func() (bool, error) { return h.FixFailedNodes(cluster, infos, admin) }},
+		{"FixUntrustedNodes", func() (bool, error) { return h.FixUntrustedNodes(cluster, infos, admin) }},
+		{"FixOpenSlots", func() (bool, error) { return h.FixOpenSlots(cluster, infos, admin) }},
+		{"FixPlacement", func() (bool, error) { return h.FixPlacement(cluster, infos, admin, config.RedisConf()) }},
+	}
+	for _, f := range fixes {
+		actionDone, err := f.fix()
+		if h.DryRun {
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		if actionDone {
+			metrics.HealAction(cluster.Namespace, cluster.Name, f.name)
+		}
+		if err != nil {
+			return actionDone, err
+		} else if actionDone {
+			return actionDone, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *realHeal) FixTerminatingPods(cluster *redisv1alpha1.DistributedRedisCluster, maxDuration time.Duration) (bool, error) {
+	actionDone, err := h.CheckAndHeal.FixTerminatingPods(cluster, maxDuration)
+	if actionDone && !h.DryRun {
+		metrics.HealAction(cluster.Namespace, cluster.Name, "FixTerminatingPods")
+	}
+	return actionDone, err
+}
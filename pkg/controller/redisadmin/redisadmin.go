@@ -0,0 +1,158 @@
+// Package redisadmin builds the redis admin of a DistributedRedisCluster, with the credentials and the TLS
+// configuration its nodes accept. It is shared by the controllers of the clusters, backups and restores.
+package redisadmin
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+const (
+	// PasswordKey is the key of the password in the password Secrets
+	PasswordKey = "password"
+	// PreviousPasswordKey is the key of the password replaced by the last rotation in the Secret of the applied
+	// password, it is still accepted by the redis nodes until the end of the grace period
+	PreviousPasswordKey = "previous-password"
+)
+
+// Auth holds the passwords the admin authenticates with.
+type Auth struct {
+	// Password of the default user, from spec.rootPasswordSecret
+	Password string
+	// AppliedPassword is the password of the default user last applied to the redis nodes, it differs
+	// from Password while the password is rotated
+	AppliedPassword string
+	// Applied is false when no password has been recorded for the cluster yet
+	Applied bool
+	// PreviousPassword is the password replaced by the last rotation, still accepted by the redis nodes
+	PreviousPassword string
+	// RotatedAt is the time the applied password replaced the previous one
+	RotatedAt time.Time
+	// OperatorPassword is the password of the ACL user of the operator, when spec.users is set
+	OperatorPassword string
+}
+
+// GetAuth returns the passwords of the admin of the cluster.
+func GetAuth(c client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (*Auth, error) {
+	password := ""
+	if cluster.Spec.PasswordSecret != nil {
+		var err error
+		if password, err = GetSecretPassword(c, cluster.Namespace, cluster.Spec.PasswordSecret.Name); err != nil {
+			return nil, err
+		}
+	}
+	operatorPassword := ""
+	if cluster.IsACLEnabled() {
+		var err error
+		if operatorPassword, err = GetSecretPassword(c, cluster.Namespace, cluster.ACLOperatorSecretName()); err != nil {
+			return nil, err
+		}
+	}
+	auth := &Auth{
+		Password:         password,
+		AppliedPassword:  password,
+		OperatorPassword: operatorPassword,
+	}
+	applied := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.AppliedPasswordSecretName(),
+		Namespace: cluster.Namespace,
+	}, applied)
+	if errors.IsNotFound(err) {
+		// the nodes were started with the current password
+		return auth, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	auth.AppliedPassword = string(applied.Data[PasswordKey])
+	auth.Applied = true
+	auth.PreviousPassword = string(applied.Data[PreviousPasswordKey])
+	if rotatedAt, ok := applied.Annotations[redisv1alpha1.AnnotationPasswordRotatedAt]; ok {
+		// a malformed time ends the grace period at once
+		auth.RotatedAt, _ = time.Parse(time.RFC3339, rotatedAt)
+	}
+	return auth, nil
+}
+
+// Rotating returns true if the password of the redis nodes differs from the one of spec.rootPasswordSecret.
+func (a *Auth) Rotating() bool {
+	return a.Password != a.AppliedPassword
+}
+
+// SetCredentials sets the credentials of the admin. The admin connects with the ACL user of the operator when
+// it is set, and falls back to the default user for the nodes started before spec.users was set. During a
+// rotation both the new and the old passwords of the default user are accepted.
+func (a *Auth) SetCredentials(options *redisutil.AdminOptions) {
+	credentials := []redisutil.Credentials{{Password: a.Password}}
+	if a.Rotating() {
+		credentials = append(credentials, redisutil.Credentials{Password: a.AppliedPassword})
+	}
+	if a.OperatorPassword != "" {
+		options.Username = redisv1alpha1.ACLOperatorUser
+		options.Password = a.OperatorPassword
+		options.FallbackCredentials = credentials
+		return
+	}
+	options.Password = credentials[0].Password
+	options.FallbackCredentials = credentials[1:]
+}
+
+// GetTLSConfig returns the TLS configuration of the connections to the redis nodes, nil when TLS is disabled.
+func GetTLSConfig(c client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (*tls.Config, error) {
+	if !cluster.IsTLSEnabled() {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.TLSSecretName(),
+		Namespace: cluster.Namespace,
+	}, secret)
+	if err != nil {
+		return nil, err
+	}
+	return redisutil.NewTLSConfig(secret.Data["ca.crt"], secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+}
+
+// GetSecretPassword returns the password stored in the Secret.
+func GetSecretPassword(c client.Client, namespace, name string) (string, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[PasswordKey]), nil
+}
+
+// New returns a redis admin connected to the given nodes of the cluster.
+func New(c client.Client, cluster *redisv1alpha1.DistributedRedisCluster, addrs []string) (redisutil.IAdmin, error) {
+	auth, err := GetAuth(c, cluster)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := GetTLSConfig(c, cluster)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.RedisConf()
+	options := &redisutil.AdminOptions{
+		ConnectionTimeout:  time.Duration(cfg.DialTimeout) * time.Millisecond,
+		RenameCommandsFile: cfg.GetRenameCommandsFile(),
+		TLSConfig:          tlsConfig,
+	}
+	auth.SetCredentials(options)
+	return redisutil.NewAdmin(addrs, options), nil
+}
@@ -0,0 +1,163 @@
+package redisadmin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func passwordSecret(name, password string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{PasswordKey: []byte(password)},
+	}
+}
+
+func rotatedSecret(name, password, previous, rotatedAt string) *corev1.Secret {
+	secret := passwordSecret(name, password)
+	secret.Data[PreviousPasswordKey] = []byte(previous)
+	secret.Annotations = map[string]string{redisv1alpha1.AnnotationPasswordRotatedAt: rotatedAt}
+	return secret
+}
+
+func passwordCluster() *redisv1alpha1.DistributedRedisCluster {
+	return &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			PasswordSecret: &corev1.LocalObjectReference{Name: "test-password"},
+		},
+	}
+}
+
+func TestGetAuth(t *testing.T) {
+	cluster := passwordCluster()
+	tests := []struct {
+		name                string
+		objs                []runtime.Object
+		wantPassword        string
+		wantAppliedPassword string
+		wantApplied         bool
+		wantRotating        bool
+		wantPrevious        string
+		wantRotatedAt       time.Time
+		wantErr             bool
+	}{
+		{
+			name:    "password secret not found",
+			wantErr: true,
+		},
+		{
+			name:                "password never applied",
+			objs:                []runtime.Object{passwordSecret("test-password", "new")},
+			wantPassword:        "new",
+			wantAppliedPassword: "new",
+		},
+		{
+			name: "password applied",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				passwordSecret(cluster.AppliedPasswordSecretName(), "new"),
+			},
+			wantPassword:        "new",
+			wantAppliedPassword: "new",
+			wantApplied:         true,
+		},
+		{
+			name: "password rotated",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				passwordSecret(cluster.AppliedPasswordSecretName(), "old"),
+			},
+			wantPassword:        "new",
+			wantAppliedPassword: "old",
+			wantApplied:         true,
+			wantRotating:        true,
+		},
+		{
+			name: "grace period of the previous password",
+			objs: []runtime.Object{
+				passwordSecret("test-password", "new"),
+				rotatedSecret(cluster.AppliedPasswordSecretName(), "new", "old", "2020-01-01T00:00:00Z"),
+			},
+			wantPassword:        "new",
+			wantAppliedPassword: "new",
+			wantApplied:         true,
+			wantPrevious:        "old",
+			wantRotatedAt:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, tt.objs...)
+			auth, err := GetAuth(client, cluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if auth.Password != tt.wantPassword || auth.AppliedPassword != tt.wantAppliedPassword || auth.Applied != tt.wantApplied {
+				t.Errorf("GetAuth() = %+v, want password %s, appliedPassword %s and applied %v",
+					auth, tt.wantPassword, tt.wantAppliedPassword, tt.wantApplied)
+			}
+			if got := auth.Rotating(); got != tt.wantRotating {
+				t.Errorf("Rotating() = %v, want %v", got, tt.wantRotating)
+			}
+			if auth.PreviousPassword != tt.wantPrevious || !auth.RotatedAt.Equal(tt.wantRotatedAt) {
+				t.Errorf("GetAuth() previous password = %s rotated at %v, want %s rotated at %v",
+					auth.PreviousPassword, auth.RotatedAt, tt.wantPrevious, tt.wantRotatedAt)
+			}
+		})
+	}
+}
+
+func TestAuth_SetCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		auth         Auth
+		wantUsername string
+		wantPassword string
+		wantFallback []redisutil.Credentials
+	}{
+		{
+			name:         "default user",
+			auth:         Auth{Password: "new", AppliedPassword: "new"},
+			wantPassword: "new",
+			wantFallback: []redisutil.Credentials{},
+		},
+		{
+			name:         "password rotated",
+			auth:         Auth{Password: "new", AppliedPassword: "old"},
+			wantPassword: "new",
+			wantFallback: []redisutil.Credentials{{Password: "old"}},
+		},
+		{
+			name:         "operator user",
+			auth:         Auth{Password: "new", AppliedPassword: "old", OperatorPassword: "operator"},
+			wantUsername: redisv1alpha1.ACLOperatorUser,
+			wantPassword: "operator",
+			wantFallback: []redisutil.Credentials{{Password: "new"}, {Password: "old"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &redisutil.AdminOptions{}
+			tt.auth.SetCredentials(options)
+			if options.Username != tt.wantUsername || options.Password != tt.wantPassword {
+				t.Errorf("SetCredentials() user = %s:%s, want %s:%s", options.Username, options.Password, tt.wantUsername, tt.wantPassword)
+			}
+			if !reflect.DeepEqual(options.FallbackCredentials, tt.wantFallback) {
+				t.Errorf("SetCredentials() fallback credentials = %v, want %v", options.FallbackCredentials, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package redisclusterbackup
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+const (
+	// bgsaveTimeout is the maximum time to wait for the BGSAVE of all the shards to complete
+	bgsaveTimeout = 10 * time.Minute
+	// bgsaveRequeue is the delay before the completion of the BGSAVE of the shards is checked again
+	bgsaveRequeue = 5 * time.Second
+	// redisDataMountPath is where the data volume of the redis pod is mounted in an upload job
+	redisDataMountPath = "/redis-data"
+	// jobTypeUpload is the redis-tools command uploading an existing RDB file
+	jobTypeUpload = "upload"
+)
+
+// errSaveInProgress is returned while the BGSAVE of a shard is not completed, the backup is requeued
+var errSaveInProgress = fmt.Errorf("waiting for the BGSAVE of the shards to complete")
+
+// coordinatedSave triggers a BGSAVE on the node of every shard at nearly the same instant, then checks at
+// each reconcile that no background save is still running on them, errSaveInProgress is returned until
+// they completed. INFO PERSISTENCE is read rather than LASTSAVE, whose one second resolution misses a save
+// completed within the second of the previous one. The last save time of each node before its BGSAVE is
+// recorded in shards and the start of the save in the status. The BGSAVE of a node already saving, or
+// rewriting its AOF, is started again at the next reconcile.
+func (r *ReconcileRedisClusterBackup) coordinatedSave(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster, shards []redisv1alpha1.ShardBackupStatus) error {
+	if backup.Status.SaveStartTime == nil {
+		t := metav1.Now()
+		backup.Status.SaveStartTime = &t
+	} else if time.Since(backup.Status.SaveStartTime.Time) > bgsaveTimeout {
+		return fmt.Errorf("timeout waiting for BGSAVE to complete")
+	}
+
+	addrs := make([]string, len(shards))
+	for i, shard := range shards {
+		node := clusterNode(cluster, shard.NodeID)
+		if node == nil {
+			return fmt.Errorf("node %s of shard %d not found", shard.NodeID, shard.Index)
+		}
+		addrs[i] = net.JoinHostPort(node.IP, node.Port)
+	}
+	admin, err := r.newAdmin(cluster, addrs)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	// the connections are opened here, the BGSAVE below only reads the connection map
+	infos := make([]*redisutil.PersistenceInfo, len(addrs))
+	for i, addr := range addrs {
+		if infos[i], err = admin.GetPersistenceInfo(addr); err != nil {
+			return err
+		}
+	}
+
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		if shards[i].PreviousSaveTime != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			errs[i] = admin.BGSave(addr)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	done := true
+	for i := range shards {
+		shard := &shards[i]
+		switch {
+		case shard.SaveTime != nil:
+		case shard.PreviousSaveTime == nil:
+			done = false
+			if errs[i] != nil {
+				if !isSaveInProgress(errs[i]) {
+					return errs[i]
+				}
+				reqLogger.Info("a save is already in progress, BGSAVE postponed", "shard", shard.Index, "node", shard.NodeID)
+				continue
+			}
+			t := metav1.NewTime(time.Unix(infos[i].LastSaveTime, 0))
+			shard.PreviousSaveTime = &t
+		case infos[i].BGSaveInProgress:
+			done = false
+		case !infos[i].LastBGSaveOK:
+			return fmt.Errorf("BGSAVE of shard %d failed on node %s", shard.Index, shard.NodeID)
+		default:
+			t := metav1.NewTime(time.Unix(infos[i].LastSaveTime, 0))
+			shard.SaveTime = &t
+		}
+	}
+	if !done {
+		return errSaveInProgress
+	}
+	reqLogger.Info("BGSAVE completed on all shards")
+	return nil
+}
+
+// isSaveInProgress returns true when a BGSAVE failed because the node is already saving or rewriting its AOF.
+func isSaveInProgress(err error) bool {
+	return strings.Contains(err.Error(), "Background save already in progress") ||
+		strings.Contains(err.Error(), "AOF log rewriting in progress")
+}
+
+// getShardUploadJob returns the job uploading the RDB file of the node of a shard, the job runs on the
+// kubernetes node of the redis pod and mounts its data volume.
+func (r *ReconcileRedisClusterBackup) getShardUploadJob(backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster, shard *redisv1alpha1.ShardBackupStatus) (*batchv1.Job, error) {
+	node := clusterNode(cluster, shard.NodeID)
+	if node == nil {
+		return nil, fmt.Errorf("node %s of shard %d not found", shard.NodeID, shard.Index)
+	}
+	bucket, err := backup.Spec.Backend.Container()
+	if err != nil {
+		return nil, err
+	}
+	folderName, err := backup.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	container := corev1.Container{
+		Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, shard.Index),
+		Image:           backup.Spec.Image,
+		ImagePullPolicy: "Always",
+		Args: []string{
+			jobTypeUpload,
+			fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+			fmt.Sprintf(`--source-dir=%s`, redisDataMountPath),
+			fmt.Sprintf(`--lastsave=%s`, strconv.FormatInt(shard.SaveTime.Unix(), 10)),
+			fmt.Sprintf(`--bucket=%s`, bucket),
+			fmt.Sprintf(`--enable-analytics=%v`, "false"),
+			fmt.Sprintf(`--host=%s`, node.IP),
+			fmt.Sprintf(`--folder=%s`, folderName),
+			fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, shard.Index),
+			"--",
+		},
+		Resources: backup.Spec.PodSpec.Resources,
+		Lifecycle: backup.Spec.PodSpec.Lifecycle,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      redisv1alpha1.UtilVolumeName,
+				MountPath: redisv1alpha1.BackupDumpDir,
+			},
+			{
+				Name:      "redis-data",
+				ReadOnly:  true,
+				MountPath: redisDataMountPath,
+			},
+			{
+				Name:      "osmconfig",
+				ReadOnly:  true,
+				MountPath: osm.SecretMountPath,
+			},
+		},
+	}
+	if cluster.Spec.PasswordSecret != nil {
+		container.Env = append(container.Env, redisPassword(cluster))
+	}
+	if cluster.IsTLSEnabled() {
+		container.Env = append(container.Env, statefulsets.TLSDirEnv())
+		container.VolumeMounts = append(container.VolumeMounts, statefulsets.TLSVolumeMount())
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backup.ShardJobName(int(shard.Index)),
+			Namespace: backup.Namespace,
+			Labels:    backupJobLabels(backup),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: redisv1alpha1.SchemeGroupVersion.String(),
+					Kind:       redisv1alpha1.RedisClusterBackupKind,
+					Name:       backup.Name,
+					UID:        backup.UID,
+				},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					// the data volume is ReadWriteOnce, run on the node of the redis pod
+					NodeName:   node.NodeName,
+					Containers: []corev1.Container{container},
+					Volumes: []corev1.Volume{
+						{
+							Name: redisv1alpha1.UtilVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+						{
+							Name: "redis-data",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: statefulsets.DataClaimName(node.PodName),
+									ReadOnly:  true,
+								},
+							},
+						},
+						{
+							Name: "osmconfig",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: backup.OSMSecretName(),
+								},
+							},
+						},
+					},
+					RestartPolicy:     corev1.RestartPolicyNever,
+					Tolerations:       backup.Spec.PodSpec.Tolerations,
+					PriorityClassName: backup.Spec.PodSpec.PriorityClassName,
+					Priority:          backup.Spec.PodSpec.Priority,
+					SecurityContext:   backup.Spec.PodSpec.SecurityContext,
+					ImagePullSecrets:  backup.Spec.PodSpec.ImagePullSecrets,
+				},
+			},
+		},
+	}
+	if cluster.IsTLSEnabled() {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, statefulsets.TLSVolume(cluster))
+	}
+	if backup.Spec.Backend.Local != nil {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name:         "local",
+			VolumeSource: backup.Spec.Backend.Local.VolumeSource,
+		})
+		job.Spec.Template.Spec.Containers[0].VolumeMounts = append(job.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "local",
+			MountPath: backup.Spec.Backend.Local.MountPath,
+			SubPath:   backup.Spec.Backend.Local.SubPath,
+		})
+	}
+
+	return job, nil
+}
@@ -0,0 +1,269 @@
+package redisclusterbackup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	store "kmodules.xyz/objectstore-api/api/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// fakeAdmin reports the persistence and replication infos of the nodes, a BGSAVE starts a background save
+type fakeAdmin struct {
+	redisutil.IAdmin
+	infos       map[string]*redisutil.PersistenceInfo
+	bgsaveError map[string]error
+	bgsaves     []string
+	offsets     map[string]int64
+	linkDown    map[string]bool
+}
+
+func (a *fakeAdmin) GetPersistenceInfo(addr string) (*redisutil.PersistenceInfo, error) {
+	info, ok := a.infos[addr]
+	if !ok {
+		return nil, fmt.Errorf("node %s not reachable", addr)
+	}
+	copied := *info
+	return &copied, nil
+}
+
+func (a *fakeAdmin) BGSave(addr string) error {
+	if err := a.bgsaveError[addr]; err != nil {
+		return err
+	}
+	a.bgsaves = append(a.bgsaves, addr)
+	a.infos[addr].BGSaveInProgress = true
+	return nil
+}
+
+func (a *fakeAdmin) GetReplicationOffset(addr string) (int64, error) {
+	return a.offsets[addr], nil
+}
+
+func (a *fakeAdmin) IsMasterLinkUp(addr string) (bool, error) {
+	return !a.linkDown[addr], nil
+}
+
+func (a *fakeAdmin) Close() {}
+
+func newTestCluster() *redisv1alpha1.DistributedRedisCluster {
+	return &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       redisv1alpha1.DistributedRedisClusterSpec{MasterSize: 2, ClusterReplicas: 1},
+		Status: redisv1alpha1.DistributedRedisClusterStatus{
+			Nodes: []redisv1alpha1.RedisClusterNode{
+				{ID: "1", Role: redisv1alpha1.RedisClusterNodeRoleMaster, IP: "10.0.0.1", Port: "6379", PodName: "drc-test-0-0", NodeName: "vm1"},
+				{ID: "2", Role: redisv1alpha1.RedisClusterNodeRoleSlave, IP: "10.0.0.2", Port: "6379", MasterRef: "1", PodName: "drc-test-0-1", NodeName: "vm2"},
+				{ID: "3", Role: redisv1alpha1.RedisClusterNodeRoleMaster, IP: "10.0.0.3", Port: "6379", PodName: "drc-test-1-0", NodeName: "vm3"},
+				{ID: "4", Role: redisv1alpha1.RedisClusterNodeRoleSlave, IP: "10.0.0.4", Port: "6379", MasterRef: "3", PodName: "drc-test-1-1", NodeName: "vm1"},
+			},
+		},
+	}
+}
+
+func TestReconcileRedisClusterBackup_coordinatedSave(t *testing.T) {
+	const lastSave = 1577836800
+	previous := metav1.NewTime(time.Unix(lastSave, 0))
+	saved := metav1.NewTime(time.Unix(lastSave+1, 0))
+	tests := []struct {
+		name         string
+		saveStart    time.Duration
+		previous     []*metav1.Time
+		infos        []redisutil.PersistenceInfo
+		bgsaveError  error
+		wantErr      error
+		wantBGSaves  int
+		wantPrevious []bool
+		wantSaved    []*metav1.Time
+	}{
+		{
+			name:         "saves started",
+			previous:     []*metav1.Time{nil, nil},
+			infos:        []redisutil.PersistenceInfo{{LastBGSaveOK: true, LastSaveTime: lastSave}, {LastBGSaveOK: true, LastSaveTime: lastSave}},
+			wantErr:      errSaveInProgress,
+			wantBGSaves:  2,
+			wantPrevious: []bool{true, true},
+			wantSaved:    []*metav1.Time{nil, nil},
+		},
+		{
+			name:         "save still running",
+			previous:     []*metav1.Time{&previous, &previous},
+			infos:        []redisutil.PersistenceInfo{{LastBGSaveOK: true, LastSaveTime: lastSave + 1}, {BGSaveInProgress: true, LastBGSaveOK: true, LastSaveTime: lastSave}},
+			wantErr:      errSaveInProgress,
+			wantPrevious: []bool{true, true},
+			wantSaved:    []*metav1.Time{&saved, nil},
+		},
+		{
+			name:         "saves completed within the second of the previous saves",
+			previous:     []*metav1.Time{&previous, &previous},
+			infos:        []redisutil.PersistenceInfo{{LastBGSaveOK: true, LastSaveTime: lastSave}, {LastBGSaveOK: true, LastSaveTime: lastSave}},
+			wantPrevious: []bool{true, true},
+			wantSaved:    []*metav1.Time{&previous, &previous},
+		},
+		{
+			name:        "save failed",
+			previous:    []*metav1.Time{&previous, &previous},
+			infos:       []redisutil.PersistenceInfo{{LastBGSaveOK: true, LastSaveTime: lastSave}, {LastSaveTime: lastSave}},
+			wantErr:     fmt.Errorf("BGSAVE of shard 1 failed on node 3"),
+			wantBGSaves: 0,
+		},
+		{
+			name:         "save postponed while a save is running",
+			previous:     []*metav1.Time{nil, nil},
+			infos:        []redisutil.PersistenceInfo{{BGSaveInProgress: true, LastBGSaveOK: true, LastSaveTime: lastSave}, {BGSaveInProgress: true, LastBGSaveOK: true, LastSaveTime: lastSave}},
+			bgsaveError:  fmt.Errorf("ERR Background save already in progress"),
+			wantErr:      errSaveInProgress,
+			wantPrevious: []bool{false, false},
+			wantSaved:    []*metav1.Time{nil, nil},
+		},
+		{
+			name:      "timeout",
+			saveStart: -bgsaveTimeout - time.Minute,
+			previous:  []*metav1.Time{&previous, &previous},
+			infos:     []redisutil.PersistenceInfo{{BGSaveInProgress: true}, {BGSaveInProgress: true}},
+			wantErr:   fmt.Errorf("timeout waiting for BGSAVE to complete"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			backup := newTestBackup()
+			backup.Spec.Consistency = redisv1alpha1.BackupConsistencyCoordinated
+			if tt.saveStart != 0 {
+				start := metav1.NewTime(time.Now().Add(tt.saveStart))
+				backup.Status.SaveStartTime = &start
+			}
+			shards := backupShards(cluster)
+			admin := &fakeAdmin{infos: map[string]*redisutil.PersistenceInfo{}, bgsaveError: map[string]error{}}
+			for i := range shards {
+				shards[i].PreviousSaveTime = tt.previous[i]
+				addr := fmt.Sprintf("10.0.0.%s:6379", shards[i].NodeID)
+				info := tt.infos[i]
+				admin.infos[addr] = &info
+				admin.bgsaveError[addr] = tt.bgsaveError
+			}
+			r := &ReconcileRedisClusterBackup{
+				newAdmin: func(*redisv1alpha1.DistributedRedisCluster, []string) (redisutil.IAdmin, error) {
+					return admin, nil
+				},
+			}
+
+			err := r.coordinatedSave(log, backup, cluster, shards)
+			if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+				t.Fatalf("coordinatedSave() error = %v, want %v", err, tt.wantErr)
+			}
+			if backup.Status.SaveStartTime == nil {
+				t.Errorf("coordinatedSave() did not record the start of the save")
+			}
+			if len(admin.bgsaves) != tt.wantBGSaves {
+				t.Errorf("coordinatedSave() sent BGSAVE to %v, want %d nodes", admin.bgsaves, tt.wantBGSaves)
+			}
+			if tt.wantErr != nil && tt.wantErr != errSaveInProgress {
+				return
+			}
+			for i, shard := range shards {
+				if got := shard.PreviousSaveTime != nil; got != tt.wantPrevious[i] {
+					t.Errorf("coordinatedSave() shard %d previous save recorded = %v, want %v", i, got, tt.wantPrevious[i])
+				}
+				if got, want := shard.SaveTime, tt.wantSaved[i]; (got == nil) != (want == nil) || (got != nil && !got.Equal(want)) {
+					t.Errorf("coordinatedSave() shard %d save time = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterBackup_getShardUploadJob(t *testing.T) {
+	saved := metav1.NewTime(time.Unix(1577836800, 0))
+	started := metav1.Now()
+	tests := []struct {
+		name      string
+		tls       bool
+		local     bool
+		wantEnv   []string
+		wantMount []string
+	}{
+		{
+			name:      "object store",
+			wantEnv:   []string{"REDIS_PASSWORD"},
+			wantMount: []string{redisv1alpha1.UtilVolumeName, "redis-data", "osmconfig"},
+		},
+		{
+			name:      "tls",
+			tls:       true,
+			wantEnv:   []string{"REDIS_PASSWORD", redisv1alpha1.TLSDirENV},
+			wantMount: []string{redisv1alpha1.UtilVolumeName, "redis-data", "osmconfig", redisv1alpha1.TLSVolumeName},
+		},
+		{
+			name:      "local backend",
+			local:     true,
+			wantEnv:   []string{"REDIS_PASSWORD"},
+			wantMount: []string{redisv1alpha1.UtilVolumeName, "redis-data", "osmconfig", "local"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			cluster.Spec.PasswordSecret = &corev1.LocalObjectReference{Name: "test-password"}
+			if tt.tls {
+				cluster.Spec.TLS = &redisv1alpha1.TLSSpec{SecretName: "test-tls"}
+			}
+			backup := newTestBackup()
+			backup.Status.StartTime = &started
+			if tt.local {
+				backup.Spec.S3 = nil
+				backup.Spec.Local = &store.LocalSpec{MountPath: "/backup"}
+			}
+			shard := backupShards(cluster)[1]
+			shard.SaveTime = &saved
+			r := &ReconcileRedisClusterBackup{}
+
+			job, err := r.getShardUploadJob(backup, cluster, &shard)
+			if err != nil {
+				t.Fatalf("getShardUploadJob() error = %v", err)
+			}
+			if job.Name != "redisbackup-backup-1" {
+				t.Errorf("getShardUploadJob() name = %s, want redisbackup-backup-1", job.Name)
+			}
+			podSpec := job.Spec.Template.Spec
+			if podSpec.NodeName != "vm3" {
+				t.Errorf("getShardUploadJob() runs on %s, want the node of the redis pod vm3", podSpec.NodeName)
+			}
+			claim := ""
+			for _, volume := range podSpec.Volumes {
+				if volume.PersistentVolumeClaim != nil {
+					claim = volume.PersistentVolumeClaim.ClaimName
+				}
+			}
+			if want := statefulsets.DataClaimName("drc-test-1-0"); claim != want {
+				t.Errorf("getShardUploadJob() mounts the claim %s, want %s", claim, want)
+			}
+			container := podSpec.Containers[0]
+			if !contains(container.Args, "--lastsave=1577836800") {
+				t.Errorf("getShardUploadJob() args = %v, want the save time of the shard", container.Args)
+			}
+			if !contains(container.Args, "--snapshot=backup-1") || !contains(container.Args, "--host=10.0.0.3") {
+				t.Errorf("getShardUploadJob() args = %v, want the snapshot and host of shard 1", container.Args)
+			}
+			var env, mounts []string
+			for _, e := range container.Env {
+				env = append(env, e.Name)
+			}
+			for _, m := range container.VolumeMounts {
+				mounts = append(mounts, m.Name)
+			}
+			if fmt.Sprint(env) != fmt.Sprint(tt.wantEnv) {
+				t.Errorf("getShardUploadJob() env = %v, want %v", env, tt.wantEnv)
+			}
+			if fmt.Sprint(mounts) != fmt.Sprint(tt.wantMount) {
+				t.Errorf("getShardUploadJob() volume mounts = %v, want %v", mounts, tt.wantMount)
+			}
+		})
+	}
+}
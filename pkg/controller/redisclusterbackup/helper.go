@@ -2,13 +2,19 @@ package redisclusterbackup
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
 )
 
 func (r *ReconcileRedisClusterBackup) markAsFailedBackup(backup *redisv1alpha1.RedisClusterBackup,
@@ -17,7 +23,11 @@ func (r *ReconcileRedisClusterBackup) markAsFailedBackup(backup *redisv1alpha1.R
 	backup.Status.CompletionTime = &t
 	backup.Status.Phase = redisv1alpha1.BackupPhaseFailed
 	backup.Status.Reason = reason
-	return r.crController.UpdateCRStatus(backup)
+	if err := r.crController.UpdateCRStatus(backup); err != nil {
+		return err
+	}
+	metrics.BackupCompleted(backup.Namespace, backup.Spec.RedisClusterName, backup.Status.Phase)
+	return nil
 }
 
 func (r *ReconcileRedisClusterBackup) markAsIgnoredBackup(backup *redisv1alpha1.RedisClusterBackup,
@@ -26,7 +36,11 @@ func (r *ReconcileRedisClusterBackup) markAsIgnoredBackup(backup *redisv1alpha1.
 	backup.Status.CompletionTime = &t
 	backup.Status.Phase = redisv1alpha1.BackupPhaseIgnored
 	backup.Status.Reason = reason
-	return r.crController.UpdateCRStatus(backup)
+	if err := r.crController.UpdateCRStatus(backup); err != nil {
+		return err
+	}
+	metrics.BackupCompleted(backup.Namespace, backup.Spec.RedisClusterName, backup.Status.Phase)
+	return nil
 }
 
 func (r *ReconcileRedisClusterBackup) isBackupRunning(backup *redisv1alpha1.RedisClusterBackup) (bool, error) {
@@ -79,7 +93,8 @@ func upsertEnvVars(vars []corev1.EnvVar, nv ...corev1.EnvVar) []corev1.EnvVar {
 
 // Returns the REDIS_PASSWORD environment variable.
 func redisPassword(cluster *redisv1alpha1.DistributedRedisCluster) corev1.EnvVar {
-	secretName := cluster.Spec.PasswordSecret.Name
+	// the nodes accept the password last applied to them, also while it is rotated
+	secretName := cluster.AppliedPasswordSecretName()
 	return corev1.EnvVar{
 		Name: "REDIS_PASSWORD",
 		ValueFrom: &corev1.EnvVarSource{
@@ -87,12 +102,136 @@ func redisPassword(cluster *redisv1alpha1.DistributedRedisCluster) corev1.EnvVar
 				LocalObjectReference: corev1.LocalObjectReference{
 					Name: secretName,
 				},
-				Key: "password",
+				Key: redisadmin.PasswordKey,
 			},
 		},
 	}
 }
 
+// backupShards returns the shards to backup, the masters of the cluster.
+func backupShards(cluster *redisv1alpha1.DistributedRedisCluster) []redisv1alpha1.ShardBackupStatus {
+	masterNum := int(cluster.Spec.MasterSize)
+	shards := make([]redisv1alpha1.ShardBackupStatus, 0, masterNum)
+	for _, node := range cluster.Status.Nodes {
+		if node.Role != redisv1alpha1.RedisClusterNodeRoleMaster {
+			continue
+		}
+		if len(shards) == masterNum {
+			break
+		}
+		shards = append(shards, redisv1alpha1.ShardBackupStatus{
+			Index:   int32(len(shards)),
+			NodeID:  node.ID,
+			Role:    node.Role,
+			PodName: node.PodName,
+			Slots:   node.Slots,
+		})
+	}
+	return shards
+}
+
+func clusterNode(cluster *redisv1alpha1.DistributedRedisCluster, id string) *redisv1alpha1.RedisClusterNode {
+	for i := range cluster.Status.Nodes {
+		if cluster.Status.Nodes[i].ID == id {
+			return &cluster.Status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// listBackupJobs returns the jobs of the backup.
+func (r *ReconcileRedisClusterBackup) listBackupJobs(backup *redisv1alpha1.RedisClusterBackup) ([]*batchv1.Job, error) {
+	jobList, err := r.jobController.ListJobByLabels(backup.Namespace, client.MatchingLabels{
+		redisv1alpha1.LabelBackupName: backup.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*batchv1.Job, 0, len(jobList.Items))
+	for i := range jobList.Items {
+		jobs = append(jobs, &jobList.Items[i])
+	}
+	if len(jobs) > 0 {
+		return jobs, nil
+	}
+
+	// jobs created before the backup name label was added
+	job, err := r.jobController.GetJob(backup.Namespace, backup.JobName())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []*batchv1.Job{job}, nil
+}
+
+// shardArtifact describes the RDB file of a shard, it is written by the backup container in its termination message.
+type shardArtifact struct {
+	ObjectKey string `json:"objectKey"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Keys      int64  `json:"keys"`
+}
+
+// recordShardArtifacts sets the artifact of each shard of the backup from the termination message of its backup
+// container, and checks that the RDB files stored in the bucket have the size of the dumped files.
+func (r *ReconcileRedisClusterBackup) recordShardArtifacts(backup *redisv1alpha1.RedisClusterBackup, jobs []*batchv1.Job) error {
+	artifacts := map[string]shardArtifact{}
+	for _, job := range jobs {
+		if job.Spec.Selector == nil {
+			continue
+		}
+		podList := &corev1.PodList{}
+		opts := []client.ListOption{
+			client.InNamespace(job.Namespace),
+			client.MatchingLabels(job.Spec.Selector.MatchLabels),
+		}
+		if err := r.directClient.List(context.TODO(), podList, opts...); err != nil {
+			return err
+		}
+		for _, pod := range podList.Items {
+			if pod.Status.Phase != corev1.PodSucceeded {
+				continue
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				terminated := status.State.Terminated
+				if terminated == nil || terminated.ExitCode != 0 || terminated.Message == "" {
+					continue
+				}
+				artifact := shardArtifact{}
+				if err := json.Unmarshal([]byte(terminated.Message), &artifact); err != nil {
+					return fmt.Errorf("invalid artifact reported by container %s of pod %s: %v", status.Name, pod.Name, err)
+				}
+				artifacts[status.Name] = artifact
+			}
+		}
+	}
+
+	for i := range backup.Status.Shards {
+		shard := &backup.Status.Shards[i]
+		artifact, ok := artifacts[fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, shard.Index)]
+		if !ok {
+			return fmt.Errorf("no artifact reported for shard %d", shard.Index)
+		}
+		if backup.Spec.Local == nil {
+			size, err := r.itemSize(backup.Spec.Backend, backup.Namespace, artifact.ObjectKey)
+			if err != nil {
+				return fmt.Errorf("unable to get the RDB file %s of shard %d: %v", artifact.ObjectKey, shard.Index, err)
+			}
+			if size != artifact.Size {
+				return fmt.Errorf("RDB file %s of shard %d is incomplete, %d bytes stored out of %d",
+					artifact.ObjectKey, shard.Index, size, artifact.Size)
+			}
+		}
+		shard.ObjectKey = artifact.ObjectKey
+		shard.Size = artifact.Size
+		shard.SHA256 = artifact.SHA256
+		shard.Keys = artifact.Keys
+	}
+	return nil
+}
+
 func newDirectClient(config *rest.Config) client.Client {
 	c, err := client.New(config, client.Options{})
 	if err != nil {
@@ -2,12 +2,17 @@ package redisclusterbackup
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	store "kmodules.xyz/objectstore-api/api/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -19,13 +24,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	redisevent "github.com/ucloud/redis-cluster-operator/pkg/event"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
 var log = logf.Log.WithName("controller_redisclusterbackup")
 
-const backupFinalizer = "finalizer.backup.redis.kun"
+const (
+	backupFinalizer = "finalizer.backup.redis.kun"
+
+	// backupInUseRequeue is the delay before the deletion of a backup still in use is retried
+	backupInUseRequeue = time.Minute
+)
 
 /**
 * USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
@@ -45,6 +59,12 @@ func newReconciler(mgr manager.Manager) reconcile.Reconciler {
 	r.directClient = newDirectClient(mgr.GetConfig())
 	r.jobController = k8sutil.NewJobController(r.directClient)
 	r.recorder = mgr.GetEventRecorderFor("redis-cluster-operator-backup")
+	r.newAdmin = func(cluster *redisv1alpha1.DistributedRedisCluster, addrs []string) (redisutil.IAdmin, error) {
+		return redisadmin.New(r.client, cluster, addrs)
+	}
+	r.itemSize = func(backend store.Backend, namespace, key string) (int64, error) {
+		return osm.ItemSize(r.client, backend, namespace, key)
+	}
 	return r
 }
 
@@ -117,6 +137,11 @@ type ReconcileRedisClusterBackup struct {
 
 	crController  k8sutil.ICustomResource
 	jobController k8sutil.IJobControl
+
+	// newAdmin returns a redis admin connected to the given nodes of the cluster
+	newAdmin func(cluster *redisv1alpha1.DistributedRedisCluster, addrs []string) (redisutil.IAdmin, error)
+	// itemSize returns the size of an object stored in the backend of a backup
+	itemSize func(backend store.Backend, namespace, key string) (int64, error)
 }
 
 // Reconcile reads that state of the cluster for a RedisClusterBackup object and makes changes based on the state read
@@ -149,6 +174,18 @@ func (r *ReconcileRedisClusterBackup) Reconcile(request reconcile.Request) (reco
 	isBackupMarkedToBeDeleted := instance.GetDeletionTimestamp() != nil
 	if isBackupMarkedToBeDeleted {
 		if contains(instance.GetFinalizers(), backupFinalizer) {
+			// the deletion is deferred while a cluster or a restore still reads the backup
+			refs, err := r.backupReferences(instance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if len(refs) > 0 {
+				msg := fmt.Sprintf("Backup is still used by %s, deletion deferred", strings.Join(refs, ", "))
+				reqLogger.Info(msg)
+				r.recorder.Event(instance, corev1.EventTypeWarning, redisevent.BackupInUse, msg)
+				return reconcile.Result{RequeueAfter: backupInUseRequeue}, nil
+			}
+
 			// Run finalization logic for backupFinalizer. If the
 			// finalization logic fails, don't remove the finalizer so
 			// that we can retry during the next reconciliation.
@@ -159,7 +196,7 @@ func (r *ReconcileRedisClusterBackup) Reconcile(request reconcile.Request) (reco
 			// Remove backupFinalizer. Once all finalizers have been
 			// removed, the object will be deleted.
 			instance.SetFinalizers(remove(instance.GetFinalizers(), backupFinalizer))
-			err := r.client.Update(context.TODO(), instance)
+			err = r.client.Update(context.TODO(), instance)
 			if err != nil {
 				return reconcile.Result{}, err
 			}
@@ -175,21 +212,15 @@ func (r *ReconcileRedisClusterBackup) Reconcile(request reconcile.Request) (reco
 	}
 
 	if err := r.create(reqLogger, instance); err != nil {
+		if err == errSaveInProgress {
+			return reconcile.Result{RequeueAfter: bgsaveRequeue}, nil
+		}
 		return reconcile.Result{}, err
 	}
 
 	return reconcile.Result{}, nil
 }
 
-func (r *ReconcileRedisClusterBackup) finalizeBackup(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
-	// TODO(user): Add the cleanup steps that the operator
-	// needs to do before the CR can be deleted. Examples
-	// of finalizers include performing backups and deleting
-	// resources that are not owned by this CR, like a PVC.
-	reqLogger.Info("Successfully finalized RedisClusterBackup")
-	return nil
-}
-
 func (r *ReconcileRedisClusterBackup) addFinalizer(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
 	reqLogger.Info("Adding Finalizer for the backup")
 	b.SetFinalizers(append(b.GetFinalizers(), backupFinalizer))
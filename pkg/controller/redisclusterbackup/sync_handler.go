@@ -3,6 +3,8 @@ package redisclusterbackup
 import (
 	"context"
 	"fmt"
+	"net"
+	"reflect"
 
 	"github.com/go-logr/logr"
 	batchv1 "k8s.io/api/batch/v1"
@@ -14,7 +16,9 @@ import (
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/event"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/metrics"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
@@ -139,7 +143,52 @@ func (r *ReconcileRedisClusterBackup) create(reqLogger logr.Logger, backup *redi
 		}
 	}
 
-	job, err := r.getBackupJob(reqLogger, backup, cluster)
+	// the shards of a coordinated backup are recorded once their BGSAVE is started
+	shards := backup.Status.Shards
+	if !backup.IsCoordinated() || len(shards) == 0 {
+		shards, err = r.selectBackupNodes(reqLogger, backup, cluster)
+		if err != nil {
+			message := fmt.Sprintf("Failed to select the nodes to backup. Reason: %v", err)
+			r.recorder.Event(
+				backup,
+				corev1.EventTypeWarning,
+				event.BackupFailed,
+				message,
+			)
+			return r.markAsFailedBackup(backup, message)
+		}
+	}
+	if backup.IsCoordinated() {
+		status := backup.Status.DeepCopy()
+		err := r.coordinatedSave(reqLogger, backup, cluster, shards)
+		if err == errSaveInProgress {
+			backup.Status.Shards = shards
+			if !reflect.DeepEqual(status, &backup.Status) {
+				if err := r.crController.UpdateCRStatus(backup); err != nil {
+					r.recorder.Event(
+						backup,
+						corev1.EventTypeWarning,
+						event.BackupError,
+						err.Error(),
+					)
+					return err
+				}
+			}
+			return errSaveInProgress
+		}
+		if err != nil {
+			message := fmt.Sprintf("Failed to save the shards. Reason: %v", err)
+			r.recorder.Event(
+				backup,
+				corev1.EventTypeWarning,
+				event.BackupFailed,
+				message,
+			)
+			return r.markAsFailedBackup(backup, message)
+		}
+	}
+
+	jobs, err := r.getBackupJobs(reqLogger, backup, cluster, shards)
 	if err != nil {
 		message := fmt.Sprintf("Failed to create Backup Job. Reason: %v", err)
 		r.recorder.Event(
@@ -158,6 +207,7 @@ func (r *ReconcileRedisClusterBackup) create(reqLogger logr.Logger, backup *redi
 	backup.Status.MasterSize = cluster.Spec.MasterSize
 	backup.Status.ClusterReplicas = cluster.Spec.ClusterReplicas
 	backup.Status.ClusterImage = cluster.Spec.Image
+	backup.Status.Shards = shards
 	if err := r.crController.UpdateCRStatus(backup); err != nil {
 		r.recorder.Event(
 			backup,
@@ -188,14 +238,16 @@ func (r *ReconcileRedisClusterBackup) create(reqLogger logr.Logger, backup *redi
 		"Backup running",
 	)
 
-	if err := r.client.Create(context.TODO(), job); err != nil {
-		r.recorder.Event(
-			backup,
-			corev1.EventTypeWarning,
-			event.BackupError,
-			err.Error(),
-		)
-		return err
+	for _, job := range jobs {
+		if err := r.client.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+			r.recorder.Event(
+				backup,
+				corev1.EventTypeWarning,
+				event.BackupError,
+				err.Error(),
+			)
+			return err
+		}
 	}
 
 	return nil
@@ -209,26 +261,119 @@ func (r *ReconcileRedisClusterBackup) ValidateBackup(backup *redisv1alpha1.Redis
 		return err
 	}
 
-	if _, err := r.crController.GetDistributedRedisCluster(backup.Namespace, backup.Spec.RedisClusterName); err != nil {
+	cluster, err := r.crController.GetDistributedRedisCluster(backup.Namespace, backup.Spec.RedisClusterName)
+	if err != nil {
 		return err
 	}
+	if backup.IsCoordinated() && (cluster.Spec.Storage == nil || cluster.Spec.Storage.Type != redisv1alpha1.PersistentClaim) {
+		return fmt.Errorf("%s consistency requires the cluster to use a persistent storage", redisv1alpha1.BackupConsistencyCoordinated)
+	}
 
 	return nil
 }
 
-func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup, cluster *redisv1alpha1.DistributedRedisCluster) (*batchv1.Job, error) {
-	jobName := backup.JobName()
-	jobLabel := map[string]string{
+// getBackupJobs returns the jobs of the backup, a single job dumping all the shards, or a job per
+// shard uploading the RDB file of its master in Coordinated consistency mode.
+func (r *ReconcileRedisClusterBackup) getBackupJobs(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster, shards []redisv1alpha1.ShardBackupStatus) ([]*batchv1.Job, error) {
+	if !backup.IsCoordinated() {
+		job, err := r.getBackupJob(reqLogger, backup, cluster, shards)
+		if err != nil {
+			return nil, err
+		}
+		return []*batchv1.Job{job}, nil
+	}
+
+	jobs := make([]*batchv1.Job, 0, len(shards))
+	for i := range shards {
+		job, err := r.getShardUploadJob(backup, cluster, &shards[i])
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func backupJobLabels(backup *redisv1alpha1.RedisClusterBackup) map[string]string {
+	return map[string]string{
 		redisv1alpha1.LabelClusterName:  backup.Spec.RedisClusterName,
 		redisv1alpha1.AnnotationJobType: redisv1alpha1.JobTypeBackup,
+		redisv1alpha1.LabelBackupName:   backup.Name,
+	}
+}
+
+// selectBackupNodes returns the shards to backup with the node dumped for each of them, depending on spec.source
+// the master or the most caught-up healthy replica of the shard is selected.
+func (r *ReconcileRedisClusterBackup) selectBackupNodes(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster) ([]redisv1alpha1.ShardBackupStatus, error) {
+	shards := backupShards(cluster)
+	source := backup.Spec.Source
+	if source == "" || source == redisv1alpha1.BackupSourceMaster {
+		return shards, nil
 	}
 
+	var addrs []string
+	for _, node := range cluster.Status.Nodes {
+		if node.Role == redisv1alpha1.RedisClusterNodeRoleSlave {
+			addrs = append(addrs, net.JoinHostPort(node.IP, node.Port))
+		}
+	}
+	admin, err := r.newAdmin(cluster, addrs)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	for i := range shards {
+		shard := &shards[i]
+		var selected *redisv1alpha1.RedisClusterNode
+		var selectedOffset int64
+		for j := range cluster.Status.Nodes {
+			node := &cluster.Status.Nodes[j]
+			if node.Role != redisv1alpha1.RedisClusterNodeRoleSlave || node.MasterRef != shard.NodeID {
+				continue
+			}
+			addr := net.JoinHostPort(node.IP, node.Port)
+			if up, err := admin.IsMasterLinkUp(addr); err != nil || !up {
+				reqLogger.Info("replica is not healthy", "node", node.ID, "err", err)
+				continue
+			}
+			offset, err := admin.GetReplicationOffset(addr)
+			if err != nil {
+				reqLogger.Info("unable to get the replication offset", "node", node.ID, "err", err)
+				continue
+			}
+			if selected == nil || offset > selectedOffset {
+				selected = node
+				selectedOffset = offset
+			}
+		}
+		if selected == nil {
+			if source == redisv1alpha1.BackupSourceReplica {
+				return nil, fmt.Errorf("shard %d has no healthy replica", shard.Index)
+			}
+			reqLogger.Info("no healthy replica, fall back to the master", "shard", shard.Index, "node", shard.NodeID)
+			continue
+		}
+		shard.NodeID = selected.ID
+		shard.Role = selected.Role
+		shard.PodName = selected.PodName
+	}
+	return shards, nil
+}
+
+func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster, shards []redisv1alpha1.ShardBackupStatus) (*batchv1.Job, error) {
+	jobName := backup.JobName()
+	jobLabel := backupJobLabels(backup)
+
 	persistentVolume, err := r.GetVolumeForBackup(backup, jobName)
 	if err != nil {
 		return nil, err
 	}
 
-	containers, err := r.backupContainers(backup, cluster)
+	containers, err := r.backupContainers(backup, cluster, shards)
 	if err != nil {
 		return nil, err
 	}
@@ -278,6 +423,9 @@ func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup
 			},
 		},
 	}
+	if cluster.IsTLSEnabled() {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, statefulsets.TLSVolume(cluster))
+	}
 	if backup.Spec.Backend.Local != nil {
 		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
 			Name:         "local",
@@ -288,73 +436,74 @@ func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup
 	return job, nil
 }
 
-func (r *ReconcileRedisClusterBackup) backupContainers(backup *redisv1alpha1.RedisClusterBackup, cluster *redisv1alpha1.DistributedRedisCluster) ([]corev1.Container, error) {
+func (r *ReconcileRedisClusterBackup) backupContainers(backup *redisv1alpha1.RedisClusterBackup, cluster *redisv1alpha1.DistributedRedisCluster,
+	shards []redisv1alpha1.ShardBackupStatus) ([]corev1.Container, error) {
 	backupSpec := backup.Spec.Backend
 	bucket, err := backupSpec.Container()
 	if err != nil {
 		return nil, err
 	}
-	masterNum := int(cluster.Spec.MasterSize)
-	containers := make([]corev1.Container, masterNum)
-	i := 0
-	for _, node := range cluster.Status.Nodes {
-		if node.Role == redisv1alpha1.RedisClusterNodeRoleMaster {
-			if i == masterNum {
-				break
-			}
-			folderName, err := backup.Location()
-			if err != nil {
-				r.recorder.Event(
-					backup,
-					corev1.EventTypeWarning,
-					event.BackupError,
-					err.Error(),
-				)
-				return nil, err
-			}
-			container := corev1.Container{
-				Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, i),
-				Image:           backup.Spec.Image,
-				ImagePullPolicy: "Always",
-				Args: []string{
-					redisv1alpha1.JobTypeBackup,
-					fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
-					fmt.Sprintf(`--bucket=%s`, bucket),
-					fmt.Sprintf(`--enable-analytics=%v`, "false"),
-					fmt.Sprintf(`--host=%s`, node.IP),
-					fmt.Sprintf(`--folder=%s`, folderName),
-					fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, i),
-					"--",
+	folderName, err := backup.Location()
+	if err != nil {
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeWarning,
+			event.BackupError,
+			err.Error(),
+		)
+		return nil, err
+	}
+	containers := make([]corev1.Container, 0, len(shards))
+	for _, shard := range shards {
+		node := clusterNode(cluster, shard.NodeID)
+		if node == nil {
+			return nil, fmt.Errorf("node %s of shard %d not found", shard.NodeID, shard.Index)
+		}
+		container := corev1.Container{
+			Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, shard.Index),
+			Image:           backup.Spec.Image,
+			ImagePullPolicy: "Always",
+			Args: []string{
+				redisv1alpha1.JobTypeBackup,
+				fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+				fmt.Sprintf(`--bucket=%s`, bucket),
+				fmt.Sprintf(`--enable-analytics=%v`, "false"),
+				fmt.Sprintf(`--host=%s`, node.IP),
+				fmt.Sprintf(`--folder=%s`, folderName),
+				fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, shard.Index),
+				"--",
+			},
+			Resources:      backup.Spec.PodSpec.Resources,
+			LivenessProbe:  backup.Spec.PodSpec.LivenessProbe,
+			ReadinessProbe: backup.Spec.PodSpec.ReadinessProbe,
+			Lifecycle:      backup.Spec.PodSpec.Lifecycle,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      redisv1alpha1.UtilVolumeName,
+					MountPath: redisv1alpha1.BackupDumpDir,
 				},
-				Resources:      backup.Spec.PodSpec.Resources,
-				LivenessProbe:  backup.Spec.PodSpec.LivenessProbe,
-				ReadinessProbe: backup.Spec.PodSpec.ReadinessProbe,
-				Lifecycle:      backup.Spec.PodSpec.Lifecycle,
-				VolumeMounts: []corev1.VolumeMount{
-					{
-						Name:      redisv1alpha1.UtilVolumeName,
-						MountPath: redisv1alpha1.BackupDumpDir,
-					},
-					{
-						Name:      "osmconfig",
-						ReadOnly:  true,
-						MountPath: osm.SecretMountPath,
-					},
+				{
+					Name:      "osmconfig",
+					ReadOnly:  true,
+					MountPath: osm.SecretMountPath,
 				},
-			}
-			if cluster.Spec.PasswordSecret != nil {
-				container.Env = append(container.Env, redisPassword(cluster))
-			}
-			if backup.Spec.Backend.Local != nil {
-				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-					Name:      "local",
-					MountPath: backup.Spec.Backend.Local.MountPath,
-					SubPath:   backup.Spec.Backend.Local.SubPath,
-				})
-			}
-			containers[i] = container
-			i++
+			},
+		}
+		if cluster.Spec.PasswordSecret != nil {
+			container.Env = append(container.Env, redisPassword(cluster))
+		}
+		if cluster.IsTLSEnabled() {
+			container.Env = append(container.Env, statefulsets.TLSDirEnv())
+			container.VolumeMounts = append(container.VolumeMounts, statefulsets.TLSVolumeMount())
 		}
+		if backup.Spec.Backend.Local != nil {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      "local",
+				MountPath: backup.Spec.Backend.Local.MountPath,
+				SubPath:   backup.Spec.Backend.Local.SubPath,
+			})
+		}
+		containers = append(containers, container)
 	}
 	return containers, nil
 }
@@ -436,26 +585,33 @@ func (r *ReconcileRedisClusterBackup) createPVCForBackup(backup *redisv1alpha1.R
 
 func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup) error {
 	reqLogger.Info("Handle Backup Job")
-	job, err := r.jobController.GetJob(backup.Namespace, backup.JobName())
+	jobs, err := r.listBackupJobs(backup)
 	if err != nil {
-		// TODO: Sometimes the job is created successfully, but it cannot be obtained immediately.
-		if errors.IsNotFound(err) {
-			msg := "One Backup is already Running"
-			reqLogger.Info(msg, "err", err)
-			r.markAsIgnoredBackup(backup, msg)
-			r.recorder.Event(
-				backup,
-				corev1.EventTypeWarning,
-				event.BackupFailed,
-				msg,
-			)
-			return nil
-		}
 		return err
 	}
-	if job.Status.Succeeded == 0 && job.Status.Failed < utils.Int32(job.Spec.BackoffLimit) {
-		return fmt.Errorf("wait for job Succeeded or Failed")
+	// TODO: Sometimes the job is created successfully, but it cannot be obtained immediately.
+	if len(jobs) == 0 {
+		msg := "One Backup is already Running"
+		reqLogger.Info(msg)
+		r.markAsIgnoredBackup(backup, msg)
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeWarning,
+			event.BackupFailed,
+			msg,
+		)
+		return nil
+	}
+	jobSucceeded := true
+	for _, job := range jobs {
+		if job.Status.Succeeded == 0 && job.Status.Failed < utils.Int32(job.Spec.BackoffLimit) {
+			return fmt.Errorf("wait for job Succeeded or Failed")
+		}
+		if job.Status.Succeeded == 0 {
+			jobSucceeded = false
+		}
 	}
+	job := jobs[0]
 
 	cluster, err := r.crController.GetDistributedRedisCluster(backup.Namespace, backup.Spec.RedisClusterName)
 	if err != nil {
@@ -470,12 +626,22 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 	for _, o := range job.OwnerReferences {
 		if o.Kind == redisv1alpha1.RedisClusterBackupKind {
 			if o.Name == backup.Name {
-				jobSucceeded := job.Status.Succeeded > 0
+				reason := "run batch job failed"
+				if jobSucceeded {
+					// a partial upload must not look like a successful backup
+					if err := r.recordShardArtifacts(backup, jobs); err != nil {
+						if k8sutil.IsRequestRetryable(err) {
+							return err
+						}
+						jobSucceeded = false
+						reason = err.Error()
+					}
+				}
 				if jobSucceeded {
 					backup.Status.Phase = redisv1alpha1.BackupPhaseSucceeded
 				} else {
 					backup.Status.Phase = redisv1alpha1.BackupPhaseFailed
-					backup.Status.Reason = "run batch job failed"
+					backup.Status.Reason = reason
 				}
 				t := metav1.Now()
 				backup.Status.CompletionTime = &t
@@ -488,6 +654,7 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 					)
 					return err
 				}
+				metrics.BackupCompleted(backup.Namespace, backup.Spec.RedisClusterName, backup.Status.Phase)
 
 				delete(backup.GetLabels(), redisv1alpha1.LabelBackupStatus)
 				if err := r.crController.UpdateCR(backup); err != nil {
@@ -516,7 +683,7 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 						msg,
 					)
 				} else {
-					msg := "Failed to complete backup"
+					msg := fmt.Sprintf("Failed to complete backup. Reason: %s", reason)
 					reqLogger.Info(msg)
 					r.recorder.Event(
 						backup,
@@ -547,3 +714,101 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 
 	return nil
 }
+
+// backupReferences returns the clusters initialized from the backup and the restores of the backup which are
+// not completed, they still read the data of the backup.
+func (r *ReconcileRedisClusterBackup) backupReferences(b *redisv1alpha1.RedisClusterBackup) ([]string, error) {
+	var refs []string
+	clusters := &redisv1alpha1.DistributedRedisClusterList{}
+	if err := r.client.List(context.TODO(), clusters); err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters.Items {
+		if cluster.Spec.Init == nil || cluster.Spec.Init.BackupSource == nil {
+			continue
+		}
+		source := cluster.Spec.Init.BackupSource
+		namespace := source.Namespace
+		if namespace == "" {
+			namespace = cluster.Namespace
+		}
+		if namespace == b.Namespace && source.Name == b.Name {
+			refs = append(refs, fmt.Sprintf("DistributedRedisCluster %s/%s", cluster.Namespace, cluster.Name))
+		}
+	}
+
+	restores := &redisv1alpha1.RedisClusterRestoreList{}
+	if err := r.client.List(context.TODO(), restores); err != nil {
+		return nil, err
+	}
+	for _, restore := range restores.Items {
+		if restore.Status.Phase == redisv1alpha1.RestorePhaseSucceeded || restore.Status.Phase == redisv1alpha1.RestorePhaseFailed {
+			continue
+		}
+		if restore.BackupNamespace() == b.Namespace && restore.Spec.BackupSource.Name == b.Name {
+			refs = append(refs, fmt.Sprintf("RedisClusterRestore %s/%s", restore.Namespace, restore.Name))
+		}
+	}
+	return refs, nil
+}
+
+// finalizeBackup deletes the resources of the backup which are not owned by the CR: the snapshot folder
+// in the object store, the osm config secret and the backup PVC. The data and the PVC are kept when
+// spec.retainOnDelete is set.
+func (r *ReconcileRedisClusterBackup) finalizeBackup(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
+	if !b.Spec.RetainOnDelete {
+		if err := r.deleteBackupData(reqLogger, b); err != nil {
+			r.recorder.Event(
+				b,
+				corev1.EventTypeWarning,
+				event.BackupCleanupError,
+				fmt.Sprintf("Failed to delete backup data. Reason: %v", err),
+			)
+			return err
+		}
+
+		claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: b.JobName(), Namespace: b.Namespace}}
+		if err := r.client.Delete(context.TODO(), claim); err != nil && !errors.IsNotFound(err) {
+			r.recorder.Event(
+				b,
+				corev1.EventTypeWarning,
+				event.BackupCleanupError,
+				fmt.Sprintf("Failed to delete backup PVC %s. Reason: %v", claim.Name, err),
+			)
+			return err
+		}
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: b.OSMSecretName(), Namespace: b.Namespace}}
+	if err := r.client.Delete(context.TODO(), secret); err != nil && !errors.IsNotFound(err) {
+		r.recorder.Event(
+			b,
+			corev1.EventTypeWarning,
+			event.BackupCleanupError,
+			fmt.Sprintf("Failed to delete osm secret %s. Reason: %v", secret.Name, err),
+		)
+		return err
+	}
+
+	reqLogger.Info("Successfully finalized RedisClusterBackup")
+	return nil
+}
+
+// deleteBackupData deletes the snapshot folder of the backup in the object store.
+func (r *ReconcileRedisClusterBackup) deleteBackupData(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
+	// the backup never started, nothing was uploaded
+	if b.Status.StartTime == nil || b.Status.Phase == redisv1alpha1.BackupPhaseIgnored {
+		return nil
+	}
+	// the local backend is a volume of the backup job, the operator can not reach it
+	if b.Spec.Local != nil {
+		reqLogger.Info("skip deleting the data of a local backup")
+		return nil
+	}
+	folder, err := b.Location()
+	if err != nil {
+		return err
+	}
+	reqLogger.Info("Deleting backup data", "folder", folder)
+	return osm.DeleteFolder(r.client, b.Spec.Backend, b.Namespace, folder)
+}
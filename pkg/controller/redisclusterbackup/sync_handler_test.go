@@ -0,0 +1,434 @@
+package redisclusterbackup
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	store "kmodules.xyz/objectstore-api/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/apis"
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func newTestClient(t *testing.T, objs ...runtime.Object) client.Client {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	return fake.NewFakeClientWithScheme(s, objs...)
+}
+
+func newTestBackup() *redisv1alpha1.RedisClusterBackup {
+	return &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			RedisClusterName: "test",
+			Backend: store.Backend{
+				StorageSecretName: "s3-secret",
+				S3:                &store.S3Spec{Bucket: "bucket"},
+			},
+		},
+	}
+}
+
+func exists(t *testing.T, c client.Client, obj runtime.Object, name string) bool {
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		t.Fatalf("Get() error = %v", err)
+	}
+	return err == nil
+}
+
+func TestReconcileRedisClusterBackup_finalizeBackup(t *testing.T) {
+	started := metav1.Now()
+	tests := []struct {
+		name          string
+		backup        func(b *redisv1alpha1.RedisClusterBackup)
+		wantErr       bool
+		wantPVC       bool
+		wantOSMSecret bool
+	}{
+		{
+			name:   "backup never started",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {},
+		},
+		{
+			name: "data retained",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+				b.Spec.RetainOnDelete = true
+			},
+			wantPVC: true,
+		},
+		{
+			name: "data of a local backup",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+				b.Spec.S3 = nil
+				b.Spec.Local = &store.LocalSpec{MountPath: "/backup"}
+			},
+		},
+		{
+			// the storage secret is missing, the bucket can not be reached
+			name: "data deletion failed",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+			},
+			wantErr:       true,
+			wantPVC:       true,
+			wantOSMSecret: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := newTestBackup()
+			tt.backup(backup)
+			c := newTestClient(t,
+				&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: backup.JobName(), Namespace: backup.Namespace}},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: backup.OSMSecretName(), Namespace: backup.Namespace}},
+			)
+			r := &ReconcileRedisClusterBackup{client: c, recorder: record.NewFakeRecorder(10)}
+
+			if err := r.finalizeBackup(log, backup); (err != nil) != tt.wantErr {
+				t.Fatalf("finalizeBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := exists(t, c, &corev1.PersistentVolumeClaim{}, backup.JobName()); got != tt.wantPVC {
+				t.Errorf("finalizeBackup() kept the backup PVC = %v, want %v", got, tt.wantPVC)
+			}
+			if got := exists(t, c, &corev1.Secret{}, backup.OSMSecretName()); got != tt.wantOSMSecret {
+				t.Errorf("finalizeBackup() kept the osm secret = %v, want %v", got, tt.wantOSMSecret)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterBackup_deleteBackupData(t *testing.T) {
+	started := metav1.Now()
+	tests := []struct {
+		name    string
+		backup  func(b *redisv1alpha1.RedisClusterBackup)
+		wantErr bool
+	}{
+		{
+			name:   "backup never started",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {},
+		},
+		{
+			name: "backup ignored",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+				b.Status.Phase = redisv1alpha1.BackupPhaseIgnored
+			},
+		},
+		{
+			name: "local backup",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+				b.Spec.S3 = nil
+				b.Spec.Local = &store.LocalSpec{MountPath: "/backup"}
+			},
+		},
+		{
+			name: "no storage provider",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+				b.Spec.S3 = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "object store not reachable",
+			backup: func(b *redisv1alpha1.RedisClusterBackup) {
+				b.Status.StartTime = &started
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := newTestBackup()
+			tt.backup(backup)
+			r := &ReconcileRedisClusterBackup{client: newTestClient(t)}
+
+			if err := r.deleteBackupData(log, backup); (err != nil) != tt.wantErr {
+				t.Errorf("deleteBackupData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterBackup_backupReferences(t *testing.T) {
+	backup := newTestBackup()
+	cluster := func(name, namespace, sourceNamespace, sourceName string) *redisv1alpha1.DistributedRedisCluster {
+		return &redisv1alpha1.DistributedRedisCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: redisv1alpha1.DistributedRedisClusterSpec{
+				Init: &redisv1alpha1.InitSpec{
+					BackupSource: &redisv1alpha1.BackupSourceSpec{Namespace: sourceNamespace, Name: sourceName},
+				},
+			},
+		}
+	}
+	restore := func(name string, phase redisv1alpha1.RestorePhase, sourceName string) *redisv1alpha1.RedisClusterRestore {
+		return &redisv1alpha1.RedisClusterRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       redisv1alpha1.RedisClusterRestoreSpec{BackupSource: redisv1alpha1.BackupSourceSpec{Name: sourceName}},
+			Status:     redisv1alpha1.RedisClusterRestoreStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		want []string
+	}{
+		{
+			name: "not referenced",
+			objs: []runtime.Object{
+				&redisv1alpha1.DistributedRedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"}},
+				cluster("other-backup", "default", "", "other"),
+				cluster("other-namespace", "other", "", "backup"),
+				restore("other-backup", redisv1alpha1.RestorePhaseRunning, "other"),
+			},
+		},
+		{
+			name: "initialized clusters",
+			objs: []runtime.Object{
+				cluster("same-namespace", "default", "", "backup"),
+				cluster("source-namespace", "other", "default", "backup"),
+			},
+			want: []string{"DistributedRedisCluster default/same-namespace", "DistributedRedisCluster other/source-namespace"},
+		},
+		{
+			name: "restores not completed",
+			objs: []runtime.Object{
+				restore("running", redisv1alpha1.RestorePhaseRunning, "backup"),
+				restore("succeeded", redisv1alpha1.RestorePhaseSucceeded, "backup"),
+				restore("failed", redisv1alpha1.RestorePhaseFailed, "backup"),
+			},
+			want: []string{"RedisClusterRestore default/running"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileRedisClusterBackup{client: newTestClient(t, tt.objs...)}
+
+			got, err := r.backupReferences(backup)
+			if err != nil {
+				t.Fatalf("backupReferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("backupReferences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterBackup_selectBackupNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   redisv1alpha1.BackupSource
+		offsets  map[string]int64
+		linkDown []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "masters by default",
+			want: []string{"1", "3"},
+		},
+		{
+			name:   "masters",
+			source: redisv1alpha1.BackupSourceMaster,
+			want:   []string{"1", "3"},
+		},
+		{
+			name:    "most caught-up replicas",
+			source:  redisv1alpha1.BackupSourceReplica,
+			offsets: map[string]int64{"10.0.0.2:6379": 100, "10.0.0.5:6379": 200, "10.0.0.4:6379": 100},
+			want:    []string{"5", "4"},
+		},
+		{
+			name:     "replica with the link down skipped",
+			source:   redisv1alpha1.BackupSourceReplica,
+			offsets:  map[string]int64{"10.0.0.2:6379": 100, "10.0.0.5:6379": 200, "10.0.0.4:6379": 100},
+			linkDown: []string{"10.0.0.5:6379"},
+			want:     []string{"2", "4"},
+		},
+		{
+			name:     "no healthy replica",
+			source:   redisv1alpha1.BackupSourceReplica,
+			linkDown: []string{"10.0.0.4:6379"},
+			wantErr:  true,
+		},
+		{
+			name:     "master when no healthy replica",
+			source:   redisv1alpha1.BackupSourceAuto,
+			linkDown: []string{"10.0.0.4:6379"},
+			want:     []string{"2", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster()
+			cluster.Status.Nodes = append(cluster.Status.Nodes, redisv1alpha1.RedisClusterNode{ID: "5",
+				Role: redisv1alpha1.RedisClusterNodeRoleSlave, IP: "10.0.0.5", Port: "6379", MasterRef: "1", PodName: "drc-test-0-2"})
+			backup := newTestBackup()
+			backup.Spec.Source = tt.source
+			admin := &fakeAdmin{offsets: tt.offsets, linkDown: map[string]bool{}}
+			for _, addr := range tt.linkDown {
+				admin.linkDown[addr] = true
+			}
+			r := &ReconcileRedisClusterBackup{
+				newAdmin: func(*redisv1alpha1.DistributedRedisCluster, []string) (redisutil.IAdmin, error) {
+					return admin, nil
+				},
+			}
+
+			shards, err := r.selectBackupNodes(log, backup, cluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectBackupNodes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, shard := range shards {
+				got = append(got, shard.NodeID)
+				if node := clusterNode(cluster, shard.NodeID); node.PodName != shard.PodName || node.Role != shard.Role {
+					t.Errorf("selectBackupNodes() shard %d = %+v, want the pod and role of node %s", shard.Index, shard, node.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectBackupNodes() nodes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterBackup_recordShardArtifacts(t *testing.T) {
+	artifact := func(shard int) string {
+		return fmt.Sprintf(`{"objectKey":"backup/backup-%d","size":100,"sha256":"abc%d","keys":10}`, shard, shard)
+	}
+	tests := []struct {
+		name     string
+		local    bool
+		messages []string
+		failed   []bool
+		stored   map[string]int64
+		wantErr  error
+	}{
+		{
+			name:     "artifacts recorded",
+			messages: []string{artifact(0), artifact(1)},
+			stored:   map[string]int64{"backup/backup-0": 100, "backup/backup-1": 100},
+		},
+		{
+			name:     "local backend",
+			local:    true,
+			messages: []string{artifact(0), artifact(1)},
+		},
+		{
+			name:     "upload incomplete",
+			messages: []string{artifact(0), artifact(1)},
+			stored:   map[string]int64{"backup/backup-0": 100, "backup/backup-1": 60},
+			wantErr:  fmt.Errorf("RDB file backup/backup-1 of shard 1 is incomplete, 60 bytes stored out of 100"),
+		},
+		{
+			name:     "file not stored",
+			messages: []string{artifact(0), artifact(1)},
+			stored:   map[string]int64{"backup/backup-0": 100},
+			wantErr:  fmt.Errorf("unable to get the RDB file backup/backup-1 of shard 1: not found"),
+		},
+		{
+			name:     "backup container failed",
+			messages: []string{artifact(0), artifact(1)},
+			failed:   []bool{false, true},
+			stored:   map[string]int64{"backup/backup-0": 100, "backup/backup-1": 100},
+			wantErr:  fmt.Errorf("no artifact reported for shard 1"),
+		},
+		{
+			name:     "invalid artifact",
+			messages: []string{artifact(0), "killed"},
+			wantErr:  fmt.Errorf("invalid artifact reported by container backup-1 of pod redisbackup-backup-1-pod: invalid character 'k' looking for beginning of value"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := newTestBackup()
+			if tt.local {
+				backup.Spec.S3 = nil
+				backup.Spec.Local = &store.LocalSpec{MountPath: "/backup"}
+			}
+			var objs []runtime.Object
+			var jobs []*batchv1.Job
+			for i, message := range tt.messages {
+				name := fmt.Sprintf("redisbackup-backup-%d", i)
+				labels := map[string]string{"job-name": name}
+				jobs = append(jobs, &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+				})
+				var exitCode int32
+				phase := corev1.PodSucceeded
+				if tt.failed != nil && tt.failed[i] {
+					exitCode = 1
+					phase = corev1.PodFailed
+				}
+				objs = append(objs, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: name + "-pod", Namespace: "default", Labels: labels},
+					Status: corev1.PodStatus{
+						Phase: phase,
+						ContainerStatuses: []corev1.ContainerStatus{{
+							Name: fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, i),
+							State: corev1.ContainerState{
+								Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message},
+							},
+						}},
+					},
+				})
+				backup.Status.Shards = append(backup.Status.Shards, redisv1alpha1.ShardBackupStatus{Index: int32(i)})
+			}
+			r := &ReconcileRedisClusterBackup{
+				directClient: newTestClient(t, objs...),
+				itemSize: func(backend store.Backend, namespace, key string) (int64, error) {
+					if tt.local {
+						t.Errorf("recordShardArtifacts() looked up %s in a local backend", key)
+					}
+					size, ok := tt.stored[key]
+					if !ok {
+						return 0, fmt.Errorf("not found")
+					}
+					return size, nil
+				},
+			}
+
+			err := r.recordShardArtifacts(backup, jobs)
+			if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+				t.Fatalf("recordShardArtifacts() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			for i, shard := range backup.Status.Shards {
+				want := redisv1alpha1.ShardBackupStatus{Index: int32(i), ObjectKey: fmt.Sprintf("backup/backup-%d", i),
+					Size: 100, SHA256: fmt.Sprintf("abc%d", i), Keys: 10}
+				if !reflect.DeepEqual(shard, want) {
+					t.Errorf("recordShardArtifacts() shard %d = %+v, want %+v", i, shard, want)
+				}
+			}
+		})
+	}
+}
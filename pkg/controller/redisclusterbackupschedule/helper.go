@@ -0,0 +1,74 @@
+package redisclusterbackupschedule
+
+import (
+	"fmt"
+	"sort"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func isBackupCompleted(backup *redisv1alpha1.RedisClusterBackup) bool {
+	return backup.Status.Phase == redisv1alpha1.BackupPhaseFailed ||
+		backup.Status.Phase == redisv1alpha1.BackupPhaseSucceeded ||
+		backup.Status.Phase == redisv1alpha1.BackupPhaseIgnored
+}
+
+// backupsToPrune returns the completed backups which are not kept by the retention policy.
+// Only succeeded backups are kept by the retention rules, failed and ignored backups are pruned
+// as soon as a newer backup is completed.
+func backupsToPrune(backups []redisv1alpha1.RedisClusterBackup, retention *redisv1alpha1.BackupRetention) []*redisv1alpha1.RedisClusterBackup {
+	if retention == nil || (retention.KeepLast == 0 && retention.KeepDaily == 0 && retention.KeepWeekly == 0) {
+		return nil
+	}
+
+	var completed []*redisv1alpha1.RedisClusterBackup
+	for i := range backups {
+		if isBackupCompleted(&backups[i]) && backups[i].DeletionTimestamp == nil {
+			completed = append(completed, &backups[i])
+		}
+	}
+	// newest first
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[j].CreationTimestamp.Before(&completed[i].CreationTimestamp)
+	})
+
+	keep := map[string]bool{}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	var succeeded int32
+	for _, backup := range completed {
+		if backup.Status.Phase != redisv1alpha1.BackupPhaseSucceeded {
+			continue
+		}
+		if succeeded < retention.KeepLast {
+			keep[backup.Name] = true
+		}
+		succeeded++
+
+		t := backup.CreationTimestamp.UTC()
+		day := t.Format("2006-01-02")
+		if !days[day] && int32(len(days)) < retention.KeepDaily {
+			days[day] = true
+			keep[backup.Name] = true
+		}
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && int32(len(weeks)) < retention.KeepWeekly {
+			weeks[weekKey] = true
+			keep[backup.Name] = true
+		}
+	}
+
+	var prune []*redisv1alpha1.RedisClusterBackup
+	for i, backup := range completed {
+		if keep[backup.Name] {
+			continue
+		}
+		// keep the latest backup even if it failed, it reports the state of the schedule
+		if i == 0 && backup.Status.Phase != redisv1alpha1.BackupPhaseSucceeded {
+			continue
+		}
+		prune = append(prune, backup)
+	}
+	return prune
+}
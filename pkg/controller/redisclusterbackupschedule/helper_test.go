@@ -0,0 +1,120 @@
+package redisclusterbackupschedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func newScheduledBackup(name string, created time.Time, phase redisv1alpha1.BackupPhase) redisv1alpha1.RedisClusterBackup {
+	return redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Status:     redisv1alpha1.RedisClusterBackupStatus{Phase: phase},
+	}
+}
+
+func Test_backupsToPrune(t *testing.T) {
+	succeeded, failed, running := redisv1alpha1.BackupPhaseSucceeded, redisv1alpha1.BackupPhaseFailed, redisv1alpha1.BackupPhaseRunning
+	// a monday
+	day := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	deleting := newScheduledBackup("deleting", day.Add(-time.Hour), succeeded)
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	testCases := []struct {
+		name      string
+		backups   []redisv1alpha1.RedisClusterBackup
+		retention *redisv1alpha1.BackupRetention
+		want      []string
+	}{
+		{
+			name: "no retention",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), succeeded),
+			},
+		},
+		{
+			name: "keep last",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("c", day.Add(2*time.Hour), succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), succeeded),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepLast: 2},
+			want:      []string{"a"},
+		},
+		{
+			name: "keep count larger than the backups",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), succeeded),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepLast: 5},
+		},
+		{
+			name: "failed backups are not counted",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), failed),
+				newScheduledBackup("c", day.Add(2*time.Hour), succeeded),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepLast: 2},
+			want:      []string{"b"},
+		},
+		{
+			name: "the latest failed backup is kept",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), failed),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepLast: 1},
+		},
+		{
+			name: "running and deleting backups are not pruned",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				deleting,
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), running),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepLast: 1},
+		},
+		{
+			name: "keep daily",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day, succeeded),
+				newScheduledBackup("b", day.Add(time.Hour), succeeded),
+				newScheduledBackup("c", day.Add(24*time.Hour), succeeded),
+				newScheduledBackup("d", day.Add(48*time.Hour), succeeded),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepDaily: 2},
+			want:      []string{"b", "a"},
+		},
+		{
+			name: "keep weekly",
+			backups: []redisv1alpha1.RedisClusterBackup{
+				newScheduledBackup("a", day.Add(-48*time.Hour), succeeded),
+				newScheduledBackup("b", day, succeeded),
+				newScheduledBackup("c", day.Add(24*time.Hour), succeeded),
+			},
+			retention: &redisv1alpha1.BackupRetention{KeepWeekly: 2},
+			want:      []string{"b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, backup := range backupsToPrune(tc.backups, tc.retention) {
+				got = append(got, backup.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("backupsToPrune() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package redisclusterbackupschedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+)
+
+var log = logf.Log.WithName("controller_redisclusterbackupschedule")
+
+// Add creates a new RedisClusterBackupSchedule Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	r := &ReconcileRedisClusterBackupSchedule{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	r.crController = k8sutil.NewCRControl(r.client)
+	r.recorder = mgr.GetEventRecorderFor("redis-cluster-operator-backup-schedule")
+	return r
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("redisclusterbackupschedule-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource RedisClusterBackupSchedule
+	err = c.Watch(&source.Kind{Type: &redisv1alpha1.RedisClusterBackupSchedule{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the RedisClusterBackups created by a schedule, the backups are not owned by
+	// the schedule so they are mapped back to it with their schedule label
+	err = c.Watch(&source.Kind{Type: &redisv1alpha1.RedisClusterBackup{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			name, ok := a.Meta.GetLabels()[redisv1alpha1.LabelBackupSchedule]
+			if !ok {
+				return nil
+			}
+			return []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: a.Meta.GetNamespace(), Name: name}},
+			}
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileRedisClusterBackupSchedule implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileRedisClusterBackupSchedule{}
+
+// ReconcileRedisClusterBackupSchedule reconciles a RedisClusterBackupSchedule object
+type ReconcileRedisClusterBackupSchedule struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+
+	crController k8sutil.ICustomResource
+}
+
+// Reconcile creates a RedisClusterBackup each time the schedule of a RedisClusterBackupSchedule is reached,
+// and deletes the backups which are not kept by its retention policy.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileRedisClusterBackupSchedule) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling RedisClusterBackupSchedule")
+
+	// Fetch the RedisClusterBackupSchedule instance
+	instance := &redisv1alpha1.RedisClusterBackupSchedule{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	sched, err := cron.ParseStandard(instance.Spec.Schedule)
+	if err != nil {
+		r.recorder.Event(instance, corev1.EventTypeWarning, event.BackupScheduleError, err.Error())
+		instance.Status.Reason = err.Error()
+		return reconcile.Result{}, r.crController.UpdateCRStatus(instance)
+	}
+
+	backups, err := r.listScheduleBackups(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.prune(reqLogger, instance, backups); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if instance.Spec.Suspend {
+		return reconcile.Result{}, nil
+	}
+
+	now := time.Now()
+	scheduledTime := lastScheduledTime(instance, sched, now)
+	if scheduledTime != nil {
+		if err := r.schedule(reqLogger, instance, backups, *scheduledTime); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: sched.Next(now).Sub(now)}, nil
+}
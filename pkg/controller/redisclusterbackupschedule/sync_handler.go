@@ -0,0 +1,109 @@
+package redisclusterbackupschedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+)
+
+// maxMissedSchedules bounds the number of missed schedules walked through one by one to find the last one,
+// as the CronJob controller does
+const maxMissedSchedules = 100
+
+func (r *ReconcileRedisClusterBackupSchedule) listScheduleBackups(schedule *redisv1alpha1.RedisClusterBackupSchedule) ([]redisv1alpha1.RedisClusterBackup, error) {
+	backupList := &redisv1alpha1.RedisClusterBackupList{}
+	opts := []client.ListOption{
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			redisv1alpha1.LabelBackupSchedule: schedule.Name,
+		},
+	}
+	if err := r.client.List(context.TODO(), backupList, opts...); err != nil {
+		return nil, err
+	}
+	return backupList.Items, nil
+}
+
+// lastScheduledTime returns the last schedule reached since the previous backup, nil if the
+// next schedule is not reached yet. Only one backup is taken for all the missed schedules.
+func lastScheduledTime(schedule *redisv1alpha1.RedisClusterBackupSchedule, sched cron.Schedule, now time.Time) *time.Time {
+	earliest := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliest = schedule.Status.LastScheduleTime.Time
+	}
+	var last *time.Time
+	t := sched.Next(earliest)
+	// a schedule which never fires returns the zero time
+	for i := 0; !t.IsZero() && !t.After(now); i++ {
+		if i == maxMissedSchedules {
+			// too many schedules were missed, skip to the last ones assuming a regular interval
+			if skipped := sched.Next(now.Add(-t.Sub(*last))); skipped.After(t) && !skipped.After(now) {
+				t = skipped
+			}
+		}
+		scheduled := t
+		last = &scheduled
+		t = sched.Next(t)
+	}
+	return last
+}
+
+// schedule creates the RedisClusterBackup of the scheduled time, no backup is created while
+// a previous backup of the schedule is still running.
+func (r *ReconcileRedisClusterBackupSchedule) schedule(reqLogger logr.Logger, schedule *redisv1alpha1.RedisClusterBackupSchedule,
+	backups []redisv1alpha1.RedisClusterBackup, scheduledTime time.Time) error {
+	for _, backup := range backups {
+		if !isBackupCompleted(&backup) {
+			reqLogger.Info("previous backup is still running, postpone the schedule", "backup", backup.Name)
+			return nil
+		}
+	}
+
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", schedule.Name, scheduledTime.UTC().Format("20060102150405")),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				redisv1alpha1.LabelBackupSchedule: schedule.Name,
+				redisv1alpha1.LabelClusterName:    schedule.Spec.BackupTemplate.RedisClusterName,
+			},
+		},
+		Spec: *schedule.Spec.BackupTemplate.DeepCopy(),
+	}
+	if err := r.client.Create(context.TODO(), backup); err != nil && !errors.IsAlreadyExists(err) {
+		r.recorder.Event(schedule, corev1.EventTypeWarning, event.BackupScheduleError, err.Error())
+		return err
+	}
+	reqLogger.Info("Backup created", "backup", backup.Name)
+	r.recorder.Eventf(schedule, corev1.EventTypeNormal, event.BackupScheduled, "Created backup %s", backup.Name)
+
+	t := metav1.NewTime(scheduledTime)
+	schedule.Status.LastScheduleTime = &t
+	schedule.Status.LastBackup = backup.Name
+	schedule.Status.Reason = ""
+	return r.crController.UpdateCRStatus(schedule)
+}
+
+// prune deletes the backups of the schedule which are not kept by the retention policy, their data
+// in the object store is deleted by the finalizer of the backup.
+func (r *ReconcileRedisClusterBackupSchedule) prune(reqLogger logr.Logger, schedule *redisv1alpha1.RedisClusterBackupSchedule,
+	backups []redisv1alpha1.RedisClusterBackup) error {
+	for _, backup := range backupsToPrune(backups, schedule.Spec.Retention) {
+		if err := r.client.Delete(context.TODO(), backup); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		reqLogger.Info("Backup pruned", "backup", backup.Name)
+		r.recorder.Eventf(schedule, corev1.EventTypeNormal, event.BackupPruned, "Deleted backup %s", backup.Name)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package redisclusterbackupschedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func Test_lastScheduledTime(t *testing.T) {
+	created := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		schedule     string
+		lastSchedule *time.Time
+		now          time.Time
+		want         *time.Time
+	}{
+		{
+			name:     "next schedule not reached",
+			schedule: "0 * * * *",
+			now:      created.Add(30 * time.Minute),
+		},
+		{
+			name:     "schedule reached",
+			schedule: "0 * * * *",
+			now:      created.Add(65 * time.Minute),
+			want:     timePtr(created.Add(time.Hour)),
+		},
+		{
+			name:         "from the last schedule",
+			schedule:     "0 * * * *",
+			lastSchedule: timePtr(created.Add(time.Hour)),
+			now:          created.Add(90 * time.Minute),
+		},
+		{
+			name:     "missed schedules",
+			schedule: "0 * * * *",
+			now:      created.Add(5*time.Hour + 30*time.Minute),
+			want:     timePtr(created.Add(5 * time.Hour)),
+		},
+		{
+			name:     "too many missed schedules",
+			schedule: "0 * * * *",
+			now:      created.Add(365*24*time.Hour + 30*time.Minute),
+			want:     timePtr(created.Add(365 * 24 * time.Hour)),
+		},
+		{
+			name:     "too many missed irregular schedules",
+			schedule: "0 9 * * 1-5",
+			// a monday
+			now:  time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC),
+			want: timePtr(time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:     "schedule never reached",
+			schedule: "0 0 30 2 *",
+			now:      created.Add(365 * 24 * time.Hour),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sched, err := cron.ParseStandard(tc.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schedule := &redisv1alpha1.RedisClusterBackupSchedule{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+			}
+			if tc.lastSchedule != nil {
+				last := metav1.NewTime(*tc.lastSchedule)
+				schedule.Status.LastScheduleTime = &last
+			}
+			got := lastScheduledTime(schedule, sched, tc.now)
+			if (got == nil) != (tc.want == nil) || (got != nil && !got.Equal(*tc.want)) {
+				t.Errorf("lastScheduledTime() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
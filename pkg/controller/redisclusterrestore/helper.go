@@ -0,0 +1,86 @@
+package redisclusterrestore
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+)
+
+func (r *ReconcileRedisClusterRestore) markAsFailedRestore(restore *redisv1alpha1.RedisClusterRestore,
+	reason string) error {
+	t := metav1.Now()
+	restore.Status.CompletionTime = &t
+	restore.Status.Phase = redisv1alpha1.RestorePhaseFailed
+	restore.Status.Reason = reason
+	return r.crController.UpdateCRStatus(restore)
+}
+
+func isRestoreCompleted(restore *redisv1alpha1.RedisClusterRestore) bool {
+	return restore.Status.Phase == redisv1alpha1.RestorePhaseFailed ||
+		restore.Status.Phase == redisv1alpha1.RestorePhaseSucceeded
+}
+
+// snapshotIndexes returns the indexes of the snapshots of the backup, one per shard of the backed up cluster.
+func snapshotIndexes(backup *redisv1alpha1.RedisClusterBackup) []int32 {
+	var indexes []int32
+	for _, shard := range backup.Status.Shards {
+		indexes = append(indexes, shard.Index)
+	}
+	if len(indexes) > 0 {
+		return indexes
+	}
+	// backups taken before the shards were recorded in the status
+	for i := int32(0); i < backup.Status.MasterSize; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// clusterHost returns the host the keys are replayed into, the headless service of the cluster always exists
+// and is in the certificate of the nodes when TLS is enabled.
+func clusterHost(cluster *redisv1alpha1.DistributedRedisCluster) string {
+	return fmt.Sprintf("%s.%s.svc", cluster.Spec.ServiceName, cluster.Namespace)
+}
+
+// masterAddrs returns the addresses of the masters of the cluster.
+func masterAddrs(cluster *redisv1alpha1.DistributedRedisCluster) []string {
+	var addrs []string
+	for _, node := range cluster.Status.Nodes {
+		if node.Role == redisv1alpha1.RedisClusterNodeRoleMaster {
+			addrs = append(addrs, net.JoinHostPort(node.IP, node.Port))
+		}
+	}
+	return addrs
+}
+
+// Returns the REDIS_PASSWORD environment variable.
+func redisPassword(cluster *redisv1alpha1.DistributedRedisCluster) corev1.EnvVar {
+	// the nodes accept the password last applied to them, also while it is rotated
+	secretName := cluster.AppliedPasswordSecretName()
+	return corev1.EnvVar{
+		Name: "REDIS_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: secretName,
+				},
+				Key: redisadmin.PasswordKey,
+			},
+		},
+	}
+}
+
+func newDirectClient(config *rest.Config) client.Client {
+	c, err := client.New(config, client.Options{})
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
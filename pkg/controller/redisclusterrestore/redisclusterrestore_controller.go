@@ -0,0 +1,143 @@
+package redisclusterrestore
+
+import (
+	"context"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisadmin"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
+)
+
+var log = logf.Log.WithName("controller_redisclusterrestore")
+
+// Add creates a new RedisClusterRestore Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	r := &ReconcileRedisClusterRestore{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	r.crController = k8sutil.NewCRControl(r.client)
+	r.directClient = newDirectClient(mgr.GetConfig())
+	r.jobController = k8sutil.NewJobController(r.directClient)
+	r.recorder = mgr.GetEventRecorderFor("redis-cluster-operator-restore")
+	r.newAdmin = func(cluster *redisv1alpha1.DistributedRedisCluster, addrs []string) (redisutil.IAdmin, error) {
+		return redisadmin.New(r.client, cluster, addrs)
+	}
+	return r
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("redisclusterrestore-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource RedisClusterRestore
+	err = c.Watch(&source.Kind{Type: &redisv1alpha1.RedisClusterRestore{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	jobPred := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldObj := e.ObjectOld.(*batch.Job)
+			newObj := e.ObjectNew.(*batch.Job)
+			return isJobCompleted(oldObj, newObj)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			job := e.Object.(*batch.Job)
+			return isJobFinished(job)
+		},
+	}
+
+	// Watch for changes to secondary resource Jobs and requeue the owner RedisClusterRestore
+	err = c.Watch(&source.Kind{Type: &batch.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &redisv1alpha1.RedisClusterRestore{},
+	}, jobPred)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileRedisClusterRestore implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileRedisClusterRestore{}
+
+// ReconcileRedisClusterRestore reconciles a RedisClusterRestore object
+type ReconcileRedisClusterRestore struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client       client.Client
+	directClient client.Client
+	scheme       *runtime.Scheme
+	recorder     record.EventRecorder
+
+	crController  k8sutil.ICustomResource
+	jobController k8sutil.IJobControl
+
+	// newAdmin returns a redis admin connected to the given nodes of the cluster
+	newAdmin func(cluster *redisv1alpha1.DistributedRedisCluster, addrs []string) (redisutil.IAdmin, error)
+}
+
+// Reconcile replays the keys of a RedisClusterBackup into a running DistributedRedisCluster with a Job, and
+// reports the result of the Job in the RedisClusterRestore status.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileRedisClusterRestore) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling RedisClusterRestore")
+
+	// Fetch the RedisClusterRestore instance
+	instance := &redisv1alpha1.RedisClusterRestore{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected.
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	if err := r.restore(reqLogger, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{}, nil
+}
+
+func isJobFinished(job *batch.Job) bool {
+	return job.Status.Succeeded > 0 || job.Status.Failed >= utils.Int32(job.Spec.BackoffLimit)
+}
+
+func isJobCompleted(old, new *batch.Job) bool {
+	return !isJobFinished(old) && isJobFinished(new)
+}
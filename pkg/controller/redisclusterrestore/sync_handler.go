@@ -0,0 +1,351 @@
+package redisclusterrestore
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// replayBasePort is the port of the local redis server loading the first snapshot in the restore job,
+// each snapshot is loaded by its own container on the next port.
+const replayBasePort = 6380
+
+func (r *ReconcileRedisClusterRestore) restore(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore) error {
+	// Do not process "completed", aka "failed" or "succeeded", restores.
+	if isRestoreCompleted(restore) {
+		return nil
+	}
+
+	job, err := r.jobController.GetJob(restore.Namespace, restore.JobName())
+	if err == nil {
+		return r.handleRestoreJob(reqLogger, restore, job)
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	backup, cluster, err := r.validateRestore(restore)
+	if err != nil {
+		if k8sutil.IsRequestRetryable(err) {
+			return err
+		}
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreFailed,
+			err.Error(),
+		)
+		return r.markAsFailedRestore(restore, err.Error())
+	}
+
+	if cluster.Status.Status != redisv1alpha1.ClusterStatusOK {
+		// the keys are replayed into the slots of the cluster, wait for it to be stable
+		return fmt.Errorf("wait for the cluster %s to be %s", cluster.Name, redisv1alpha1.ClusterStatusOK)
+	}
+
+	if restore.Status.StartTime == nil {
+		t := metav1.Now()
+		restore.Status.StartTime = &t
+		restore.Status.Phase = redisv1alpha1.RestorePhaseRunning
+		if err := r.crController.UpdateCRStatus(restore); err != nil {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeWarning,
+				event.RestoreError,
+				err.Error(),
+			)
+			return err
+		}
+	}
+
+	// the storage secret is read in the namespace of the backup
+	secret, err := osm.NewCephSecret(r.client, restore.OSMSecretName(), backup.Namespace, backup.Spec.Backend)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to generate osm secret. Reason: %v", err)
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreFailed,
+			msg,
+		)
+		return r.markAsFailedRestore(restore, msg)
+	}
+	secret.Namespace = restore.Namespace
+	secret.OwnerReferences = []metav1.OwnerReference{restoreOwnerReference(restore)}
+	if err := k8sutil.CreateSecret(r.client, secret, reqLogger); err != nil {
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreError,
+			err.Error(),
+		)
+		return err
+	}
+
+	job, err = r.getRestoreJob(restore, backup, cluster)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create Restore Job. Reason: %v", err)
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreFailed,
+			msg,
+		)
+		return r.markAsFailedRestore(restore, msg)
+	}
+
+	if restore.Spec.FlushAll && restore.Status.FlushTime == nil {
+		if err := r.flushAll(reqLogger, cluster); err != nil {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeWarning,
+				event.RestoreError,
+				err.Error(),
+			)
+			return err
+		}
+		// the flush is recorded before the job is created, a retry must not flush the replayed keys
+		t := metav1.Now()
+		restore.Status.FlushTime = &t
+		if err := r.crController.UpdateCRStatus(restore); err != nil {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeWarning,
+				event.RestoreError,
+				err.Error(),
+			)
+			return err
+		}
+	}
+
+	if err := r.jobController.CreateJob(job); err != nil && !errors.IsAlreadyExists(err) {
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreError,
+			err.Error(),
+		)
+		return err
+	}
+
+	reqLogger.Info("Restore running", "backup", backup.Name, "cluster", cluster.Name)
+	r.recorder.Event(
+		restore,
+		corev1.EventTypeNormal,
+		event.Starting,
+		"Restore running",
+	)
+	return nil
+}
+
+// validateRestore returns the backup to restore and the cluster to restore into.
+func (r *ReconcileRedisClusterRestore) validateRestore(restore *redisv1alpha1.RedisClusterRestore) (
+	*redisv1alpha1.RedisClusterBackup, *redisv1alpha1.DistributedRedisCluster, error) {
+	if err := restore.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	backup, err := r.crController.GetRedisClusterBackup(restore.BackupNamespace(), restore.Spec.BackupSource.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if backup.Status.Phase != redisv1alpha1.BackupPhaseSucceeded {
+		return nil, nil, fmt.Errorf("backup %s/%s is not %s", backup.Namespace, backup.Name, redisv1alpha1.BackupPhaseSucceeded)
+	}
+	if backup.Spec.Local != nil {
+		return nil, nil, fmt.Errorf("backup %s/%s is stored in a local volume, only backups in an object store can be replayed",
+			backup.Namespace, backup.Name)
+	}
+
+	cluster, err := r.crController.GetDistributedRedisCluster(restore.Namespace, restore.Spec.RedisClusterName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backup, cluster, nil
+}
+
+// flushAll deletes all the keys of the cluster, the replicas follow their masters.
+func (r *ReconcileRedisClusterRestore) flushAll(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) error {
+	admin, err := r.newAdmin(cluster, masterAddrs(cluster))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	reqLogger.Info("Flush all keys of the cluster", "cluster", cluster.Name)
+	return admin.FlushAll()
+}
+
+func restoreOwnerReference(restore *redisv1alpha1.RedisClusterRestore) metav1.OwnerReference {
+	return *metav1.NewControllerRef(restore, redisv1alpha1.SchemeGroupVersion.WithKind(redisv1alpha1.RedisClusterRestoreKind))
+}
+
+// getRestoreJob returns the job replaying the keys of the backup into the cluster, each snapshot of the backup
+// is loaded by a local redis server in its own container and imported into the cluster, so that the number
+// of masters of the cluster may differ from the one of the backup.
+func (r *ReconcileRedisClusterRestore) getRestoreJob(restore *redisv1alpha1.RedisClusterRestore,
+	backup *redisv1alpha1.RedisClusterBackup, cluster *redisv1alpha1.DistributedRedisCluster) (*batchv1.Job, error) {
+	bucket, err := backup.Spec.Backend.Container()
+	if err != nil {
+		return nil, err
+	}
+	folderName, err := backup.Location()
+	if err != nil {
+		return nil, err
+	}
+	image := restore.Spec.Image
+	if image == "" {
+		image = backup.Spec.Image
+	}
+
+	var containers []corev1.Container
+	for _, index := range snapshotIndexes(backup) {
+		container := corev1.Container{
+			Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeReplay, index),
+			Image:           image,
+			ImagePullPolicy: "Always",
+			Args: []string{
+				redisv1alpha1.JobTypeReplay,
+				fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+				fmt.Sprintf(`--bucket=%s`, bucket),
+				fmt.Sprintf(`--enable-analytics=%v`, "false"),
+				fmt.Sprintf(`--host=%s`, clusterHost(cluster)),
+				fmt.Sprintf(`--folder=%s`, folderName),
+				fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, index),
+				fmt.Sprintf(`--replay-port=%d`, replayBasePort+index),
+				"--",
+			},
+			Resources:      restore.Spec.PodSpec.Resources,
+			LivenessProbe:  restore.Spec.PodSpec.LivenessProbe,
+			ReadinessProbe: restore.Spec.PodSpec.ReadinessProbe,
+			Lifecycle:      restore.Spec.PodSpec.Lifecycle,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      redisv1alpha1.UtilVolumeName,
+					MountPath: redisv1alpha1.BackupDumpDir,
+					SubPath:   fmt.Sprintf("snapshot-%d", index),
+				},
+				{
+					Name:      "osmconfig",
+					ReadOnly:  true,
+					MountPath: osm.SecretMountPath,
+				},
+			},
+		}
+		if shard := backup.Shard(index); shard != nil && shard.SHA256 != "" {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  "REDIS_SHA256",
+				Value: shard.SHA256,
+			})
+		}
+		if cluster.Spec.PasswordSecret != nil {
+			container.Env = append(container.Env, redisPassword(cluster))
+		}
+		if cluster.IsTLSEnabled() {
+			container.Env = append(container.Env, statefulsets.TLSDirEnv())
+			container.VolumeMounts = append(container.VolumeMounts, statefulsets.TLSVolumeMount())
+		}
+		containers = append(containers, container)
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restore.JobName(),
+			Namespace: restore.Namespace,
+			Labels: map[string]string{
+				redisv1alpha1.LabelClusterName:  cluster.Name,
+				redisv1alpha1.AnnotationJobType: redisv1alpha1.JobTypeReplay,
+			},
+			OwnerReferences: []metav1.OwnerReference{restoreOwnerReference(restore)},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: containers,
+					Volumes: []corev1.Volume{
+						{
+							Name: redisv1alpha1.UtilVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+						{
+							Name: "osmconfig",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: restore.OSMSecretName(),
+								},
+							},
+						},
+					},
+					RestartPolicy:     corev1.RestartPolicyNever,
+					NodeSelector:      restore.Spec.PodSpec.NodeSelector,
+					Affinity:          restore.Spec.PodSpec.Affinity,
+					SchedulerName:     restore.Spec.PodSpec.SchedulerName,
+					Tolerations:       restore.Spec.PodSpec.Tolerations,
+					PriorityClassName: restore.Spec.PodSpec.PriorityClassName,
+					Priority:          restore.Spec.PodSpec.Priority,
+					SecurityContext:   restore.Spec.PodSpec.SecurityContext,
+					ImagePullSecrets:  restore.Spec.PodSpec.ImagePullSecrets,
+				},
+			},
+		},
+	}
+	if cluster.IsTLSEnabled() {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, statefulsets.TLSVolume(cluster))
+	}
+	return job, nil
+}
+
+func (r *ReconcileRedisClusterRestore) handleRestoreJob(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	job *batchv1.Job) error {
+	if !isJobFinished(job) {
+		reqLogger.Info("wait for the restore job to complete", "job", job.Name)
+		return nil
+	}
+
+	if job.Status.Succeeded == 0 {
+		msg := "Failed to complete restore"
+		reqLogger.Info(msg)
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreFailed,
+			msg,
+		)
+		return r.markAsFailedRestore(restore, "run batch job failed")
+	}
+
+	t := metav1.Now()
+	restore.Status.CompletionTime = &t
+	restore.Status.Phase = redisv1alpha1.RestorePhaseSucceeded
+	restore.Status.Reason = ""
+	if err := r.crController.UpdateCRStatus(restore); err != nil {
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreError,
+			err.Error(),
+		)
+		return err
+	}
+	msg := "Successfully completed restore"
+	reqLogger.Info(msg)
+	r.recorder.Event(
+		restore,
+		corev1.EventTypeNormal,
+		event.RestoreSuccessful,
+		msg,
+	)
+	return nil
+}
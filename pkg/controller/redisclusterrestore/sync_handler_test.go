@@ -0,0 +1,343 @@
+package redisclusterrestore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	store "kmodules.xyz/objectstore-api/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/apis"
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func newTestClient(t *testing.T, objs ...runtime.Object) client.Client {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	return fake.NewFakeClientWithScheme(s, objs...)
+}
+
+func newTestReconciler(c client.Client) *ReconcileRedisClusterRestore {
+	return &ReconcileRedisClusterRestore{
+		client:        c,
+		recorder:      record.NewFakeRecorder(10),
+		crController:  k8sutil.NewCRControl(c),
+		jobController: k8sutil.NewJobController(c),
+	}
+}
+
+func newTestBackup() *redisv1alpha1.RedisClusterBackup {
+	started := metav1.Now()
+	return &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "backups"},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			Image:            "redis-tools:5.0.4",
+			RedisClusterName: "source",
+			Backend: store.Backend{
+				StorageSecretName: "s3-secret",
+				S3:                &store.S3Spec{Bucket: "bucket", Endpoint: "http://ceph"},
+			},
+		},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			StartTime: &started,
+			Phase:     redisv1alpha1.BackupPhaseSucceeded,
+			Shards: []redisv1alpha1.ShardBackupStatus{
+				{Index: 0, NodeID: "1", SHA256: "sum0"},
+				{Index: 1, NodeID: "2"},
+			},
+		},
+	}
+}
+
+func newTestCluster() *redisv1alpha1.DistributedRedisCluster {
+	return &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			MasterSize:     3,
+			ServiceName:    "test-svc",
+			PasswordSecret: &corev1.LocalObjectReference{Name: "test-password"},
+		},
+		Status: redisv1alpha1.DistributedRedisClusterStatus{
+			Status: redisv1alpha1.ClusterStatusOK,
+			Nodes: []redisv1alpha1.RedisClusterNode{
+				{ID: "1", Role: redisv1alpha1.RedisClusterNodeRoleMaster, IP: "10.0.0.1", Port: "6379"},
+				{ID: "2", Role: redisv1alpha1.RedisClusterNodeRoleSlave, IP: "10.0.0.2", Port: "6379", MasterRef: "1"},
+			},
+		},
+	}
+}
+
+func newTestRestore() *redisv1alpha1.RedisClusterRestore {
+	return &redisv1alpha1.RedisClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+		Spec: redisv1alpha1.RedisClusterRestoreSpec{
+			RedisClusterName: "test",
+			BackupSource:     redisv1alpha1.BackupSourceSpec{Namespace: "backups", Name: "backup"},
+		},
+	}
+}
+
+func Test_snapshotIndexes(t *testing.T) {
+	tests := []struct {
+		name   string
+		status redisv1alpha1.RedisClusterBackupStatus
+		want   []int32
+	}{
+		{
+			name: "shards recorded",
+			status: redisv1alpha1.RedisClusterBackupStatus{
+				MasterSize: 3,
+				Shards:     []redisv1alpha1.ShardBackupStatus{{Index: 0}, {Index: 1}},
+			},
+			want: []int32{0, 1},
+		},
+		{
+			name:   "backup taken before the shards were recorded",
+			status: redisv1alpha1.RedisClusterBackupStatus{MasterSize: 3},
+			want:   []int32{0, 1, 2},
+		},
+		{
+			name: "empty backup",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := &redisv1alpha1.RedisClusterBackup{Status: tt.status}
+			if got := snapshotIndexes(backup); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("snapshotIndexes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterRestore_validateRestore(t *testing.T) {
+	running := newTestBackup()
+	running.Status.Phase = redisv1alpha1.BackupPhaseRunning
+	local := newTestBackup()
+	local.Spec.S3 = nil
+	local.Spec.Local = &store.LocalSpec{MountPath: "/backup"}
+	tests := []struct {
+		name    string
+		restore func(restore *redisv1alpha1.RedisClusterRestore)
+		objs    []runtime.Object
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			objs: []runtime.Object{newTestBackup(), newTestCluster()},
+		},
+		{
+			name: "backup in the namespace of the restore",
+			restore: func(restore *redisv1alpha1.RedisClusterRestore) {
+				restore.Spec.BackupSource.Namespace = ""
+			},
+			objs:    []runtime.Object{newTestBackup(), newTestCluster()},
+			wantErr: true,
+		},
+		{
+			name: "cluster name missing",
+			restore: func(restore *redisv1alpha1.RedisClusterRestore) {
+				restore.Spec.RedisClusterName = ""
+			},
+			objs:    []runtime.Object{newTestBackup(), newTestCluster()},
+			wantErr: true,
+		},
+		{
+			name:    "backup not succeeded",
+			objs:    []runtime.Object{running, newTestCluster()},
+			wantErr: true,
+		},
+		{
+			name:    "local backup",
+			objs:    []runtime.Object{local, newTestCluster()},
+			wantErr: true,
+		},
+		{
+			name:    "cluster not found",
+			objs:    []runtime.Object{newTestBackup()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := newTestRestore()
+			if tt.restore != nil {
+				tt.restore(restore)
+			}
+			r := newTestReconciler(newTestClient(t, tt.objs...))
+
+			backup, cluster, err := r.validateRestore(restore)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateRestore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (backup.Name != "backup" || cluster.Name != "test") {
+				t.Errorf("validateRestore() = %s, %s, want the backup backup and the cluster test", backup.Name, cluster.Name)
+			}
+		})
+	}
+}
+
+func TestReconcileRedisClusterRestore_getRestoreJob(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		tls       bool
+		wantImage string
+	}{
+		{
+			name:      "image of the backup",
+			wantImage: "redis-tools:5.0.4",
+		},
+		{
+			name:      "image of the restore",
+			image:     "redis-tools:6.0.5",
+			wantImage: "redis-tools:6.0.5",
+		},
+		{
+			name:      "tls",
+			tls:       true,
+			wantImage: "redis-tools:5.0.4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := newTestRestore()
+			restore.Spec.Image = tt.image
+			cluster := newTestCluster()
+			if tt.tls {
+				cluster.Spec.TLS = &redisv1alpha1.TLSSpec{SecretName: "test-tls"}
+			}
+			r := newTestReconciler(newTestClient(t))
+
+			job, err := r.getRestoreJob(restore, newTestBackup(), cluster)
+			if err != nil {
+				t.Fatalf("getRestoreJob() error = %v", err)
+			}
+			containers := job.Spec.Template.Spec.Containers
+			if len(containers) != 2 {
+				t.Fatalf("getRestoreJob() has %d containers, want one per snapshot", len(containers))
+			}
+			for i, container := range containers {
+				if container.Image != tt.wantImage {
+					t.Errorf("getRestoreJob() image = %s, want %s", container.Image, tt.wantImage)
+				}
+				for _, arg := range []string{
+					"--host=test-svc.default.svc",
+					fmt.Sprintf("--snapshot=backup-%d", i),
+					fmt.Sprintf("--replay-port=%d", replayBasePort+i),
+				} {
+					if !containsString(container.Args, arg) {
+						t.Errorf("getRestoreJob() container %d args = %v, want %s", i, container.Args, arg)
+					}
+				}
+				env := map[string]bool{}
+				for _, e := range container.Env {
+					env[e.Name] = true
+					if e.Name == "REDIS_PASSWORD" && e.ValueFrom.SecretKeyRef.Name != cluster.AppliedPasswordSecretName() {
+						t.Errorf("getRestoreJob() reads the password from %s, want the applied password", e.ValueFrom.SecretKeyRef.Name)
+					}
+				}
+				if env["REDIS_SHA256"] != (i == 0) {
+					t.Errorf("getRestoreJob() container %d env = %v, want REDIS_SHA256 only for a recorded checksum", i, container.Env)
+				}
+				if !env["REDIS_PASSWORD"] || env[redisv1alpha1.TLSDirENV] != tt.tls {
+					t.Errorf("getRestoreJob() container %d env = %v, want the password and the tls dir when enabled", i, container.Env)
+				}
+			}
+			var tlsVolume bool
+			for _, volume := range job.Spec.Template.Spec.Volumes {
+				tlsVolume = tlsVolume || volume.Name == redisv1alpha1.TLSVolumeName
+			}
+			if tlsVolume != tt.tls {
+				t.Errorf("getRestoreJob() tls volume = %v, want %v", tlsVolume, tt.tls)
+			}
+		})
+	}
+}
+
+// flushAdmin counts the flushes of the cluster
+type flushAdmin struct {
+	redisutil.IAdmin
+	flushes int
+}
+
+func (a *flushAdmin) FlushAll() error {
+	a.flushes++
+	return nil
+}
+
+func (a *flushAdmin) Close() {}
+
+func TestReconcileRedisClusterRestore_restore_flushAll(t *testing.T) {
+	flushed := metav1.Now()
+	tests := []struct {
+		name        string
+		flushTime   *metav1.Time
+		wantFlushes int
+	}{
+		{
+			name:        "keys flushed",
+			wantFlushes: 1,
+		},
+		{
+			name:      "keys already flushed",
+			flushTime: &flushed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := newTestRestore()
+			restore.Spec.FlushAll = true
+			restore.Status.FlushTime = tt.flushTime
+			storageSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s3-secret", Namespace: "backups"}}
+			c := newTestClient(t, restore, newTestBackup(), newTestCluster(), storageSecret)
+			admin := &flushAdmin{}
+			r := newTestReconciler(c)
+			r.newAdmin = func(*redisv1alpha1.DistributedRedisCluster, []string) (redisutil.IAdmin, error) {
+				return admin, nil
+			}
+
+			if err := r.restore(log, restore); err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+			if admin.flushes != tt.wantFlushes {
+				t.Errorf("restore() flushed the cluster %d times, want %d", admin.flushes, tt.wantFlushes)
+			}
+			got := &redisv1alpha1.RedisClusterRestore{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: restore.Namespace, Name: restore.Name}, got); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status.FlushTime == nil {
+				t.Errorf("restore() did not record the flush")
+			}
+			if _, err := r.jobController.GetJob(restore.Namespace, restore.JobName()); err != nil {
+				t.Errorf("restore() did not create the job: %v", err)
+			}
+		})
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,19 @@
 package event
 
 const (
-	BackupError      string = "BakcupError"
-	BackupFailed     string = "BakcupFailed"
-	Starting         string = "Starting"
-	Successful       string = "Successful"
-	BackupSuccessful string = "SuccessfulBackup"
+	BackupError        string = "BakcupError"
+	BackupFailed       string = "BakcupFailed"
+	Starting           string = "Starting"
+	Successful         string = "Successful"
+	BackupSuccessful   string = "SuccessfulBackup"
+	BackupCleanupError string = "BackupCleanupError"
+	BackupInUse        string = "BackupInUse"
+
+	BackupScheduleError string = "BackupScheduleError"
+	BackupScheduled     string = "BackupScheduled"
+	BackupPruned        string = "BackupPruned"
+
+	RestoreError      string = "RestoreError"
+	RestoreFailed     string = "RestoreFailed"
+	RestoreSuccessful string = "SuccessfulRestore"
 )
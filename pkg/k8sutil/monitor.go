@@ -0,0 +1,108 @@
+package k8sutil
+
+import (
+	"context"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IMonitorControl defines the interface that uses to create, update, and delete the prometheus-operator
+// ServiceMonitors and PodMonitors.
+type IMonitorControl interface {
+	// CreateServiceMonitor creates a ServiceMonitor.
+	CreateServiceMonitor(*monitoringv1.ServiceMonitor) error
+	// UpdateServiceMonitor updates a ServiceMonitor.
+	UpdateServiceMonitor(*monitoringv1.ServiceMonitor) error
+	// DeleteServiceMonitor deletes a ServiceMonitor.
+	DeleteServiceMonitor(*monitoringv1.ServiceMonitor) error
+	// GetServiceMonitor get ServiceMonitor.
+	GetServiceMonitor(namespace, name string) (*monitoringv1.ServiceMonitor, error)
+	// ListServiceMonitorByLabels list the ServiceMonitors matching the labels in the namespace, in all namespaces when it is empty.
+	ListServiceMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.ServiceMonitorList, error)
+	// CreatePodMonitor creates a PodMonitor.
+	CreatePodMonitor(*monitoringv1.PodMonitor) error
+	// UpdatePodMonitor updates a PodMonitor.
+	UpdatePodMonitor(*monitoringv1.PodMonitor) error
+	// DeletePodMonitor deletes a PodMonitor.
+	DeletePodMonitor(*monitoringv1.PodMonitor) error
+	// GetPodMonitor get PodMonitor.
+	GetPodMonitor(namespace, name string) (*monitoringv1.PodMonitor, error)
+	// ListPodMonitorByLabels list the PodMonitors matching the labels in the namespace, in all namespaces when it is empty.
+	ListPodMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.PodMonitorList, error)
+}
+
+type monitorController struct {
+	client client.Client
+}
+
+// NewMonitorController creates a concrete implementation of the
+// IMonitorControl.
+func NewMonitorController(client client.Client) IMonitorControl {
+	return &monitorController{client: client}
+}
+
+// CreateServiceMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) CreateServiceMonitor(sm *monitoringv1.ServiceMonitor) error {
+	return m.client.Create(context.TODO(), sm)
+}
+
+// UpdateServiceMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) UpdateServiceMonitor(sm *monitoringv1.ServiceMonitor) error {
+	return m.client.Update(context.TODO(), sm)
+}
+
+// DeleteServiceMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) DeleteServiceMonitor(sm *monitoringv1.ServiceMonitor) error {
+	return m.client.Delete(context.TODO(), sm)
+}
+
+// GetServiceMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) GetServiceMonitor(namespace, name string) (*monitoringv1.ServiceMonitor, error) {
+	sm := &monitoringv1.ServiceMonitor{}
+	err := m.client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, sm)
+	return sm, err
+}
+
+// ListServiceMonitorByLabels implement the IMonitorControl.Interface.
+func (m *monitorController) ListServiceMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.ServiceMonitorList, error) {
+	smList := &monitoringv1.ServiceMonitorList{}
+	err := m.client.List(context.TODO(), smList, client.InNamespace(namespace), labels)
+	return smList, err
+}
+
+// CreatePodMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) CreatePodMonitor(pm *monitoringv1.PodMonitor) error {
+	return m.client.Create(context.TODO(), pm)
+}
+
+// UpdatePodMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) UpdatePodMonitor(pm *monitoringv1.PodMonitor) error {
+	return m.client.Update(context.TODO(), pm)
+}
+
+// DeletePodMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) DeletePodMonitor(pm *monitoringv1.PodMonitor) error {
+	return m.client.Delete(context.TODO(), pm)
+}
+
+// GetPodMonitor implement the IMonitorControl.Interface.
+func (m *monitorController) GetPodMonitor(namespace, name string) (*monitoringv1.PodMonitor, error) {
+	pm := &monitoringv1.PodMonitor{}
+	err := m.client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, pm)
+	return pm, err
+}
+
+// ListPodMonitorByLabels implement the IMonitorControl.Interface.
+func (m *monitorController) ListPodMonitorByLabels(namespace string, labels client.MatchingLabels) (*monitoringv1.PodMonitorList, error) {
+	pmList := &monitoringv1.PodMonitorList{}
+	err := m.client.List(context.TODO(), pmList, client.InNamespace(namespace), labels)
+	return pmList, err
+}
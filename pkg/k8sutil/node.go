@@ -0,0 +1,34 @@
+package k8sutil
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// INodeControl defines the interface that uses to get the Nodes the pods are scheduled on.
+type INodeControl interface {
+	// GetNode get Node.
+	GetNode(name string) (*corev1.Node, error)
+}
+
+type nodeController struct {
+	client client.Client
+}
+
+// NewNodeController creates a concrete implementation of the
+// INodeControl.
+func NewNodeController(client client.Client) INodeControl {
+	return &nodeController{client: client}
+}
+
+// GetNode implement the INodeControl.Interface.
+func (n *nodeController) GetNode(name string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	err := n.client.Get(context.TODO(), types.NamespacedName{
+		Name: name,
+	}, node)
+	return node, err
+}
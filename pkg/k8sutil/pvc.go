@@ -0,0 +1,51 @@
+package k8sutil
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IPvcControl defines the interface that uses to get, update and list PersistentVolumeClaims.
+type IPvcControl interface {
+	// UpdatePvc updates a PersistentVolumeClaim.
+	UpdatePvc(*corev1.PersistentVolumeClaim) error
+	// GetPvc get PersistentVolumeClaim.
+	GetPvc(namespace, name string) (*corev1.PersistentVolumeClaim, error)
+	// ListPvcByLabels list the PersistentVolumeClaims matching the labels.
+	ListPvcByLabels(namespace string, labels client.MatchingLabels) (*corev1.PersistentVolumeClaimList, error)
+}
+
+type pvcController struct {
+	client client.Client
+}
+
+// NewPvcController creates a concrete implementation of the
+// IPvcControl.
+func NewPvcController(client client.Client) IPvcControl {
+	return &pvcController{client: client}
+}
+
+// UpdatePvc implement the IPvcControl.Interface.
+func (p *pvcController) UpdatePvc(pvc *corev1.PersistentVolumeClaim) error {
+	return p.client.Update(context.TODO(), pvc)
+}
+
+// GetPvc implement the IPvcControl.Interface.
+func (p *pvcController) GetPvc(namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := p.client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, pvc)
+	return pvc, err
+}
+
+// ListPvcByLabels implement the IPvcControl.Interface.
+func (p *pvcController) ListPvcByLabels(namespace string, labels client.MatchingLabels) (*corev1.PersistentVolumeClaimList, error) {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	err := p.client.List(context.TODO(), pvcList, client.InNamespace(namespace), labels)
+	return pvcList, err
+}
@@ -18,6 +18,8 @@ type IServiceControl interface {
 	DeleteService(*corev1.Service) error
 	// GetService get Service in a DistributedRedisCluster.
 	GetService(namespace, name string) (*corev1.Service, error)
+	// ListServiceByLabels list the Services matching the labels.
+	ListServiceByLabels(namespace string, labels client.MatchingLabels) (*corev1.ServiceList, error)
 }
 
 type serviceController struct {
@@ -54,3 +56,10 @@ func (s *serviceController) GetService(namespace, name string) (*corev1.Service,
 	}, svc)
 	return svc, err
 }
+
+// ListServiceByLabels implement the IServiceControl.Interface.
+func (s *serviceController) ListServiceByLabels(namespace string, labels client.MatchingLabels) (*corev1.ServiceList, error) {
+	svcList := &corev1.ServiceList{}
+	err := s.client.List(context.TODO(), svcList, client.InNamespace(namespace), labels)
+	return svcList, err
+}
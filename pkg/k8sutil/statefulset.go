@@ -5,6 +5,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -21,6 +22,8 @@ type IStatefulSetControl interface {
 	GetStatefulSet(namespace, name string) (*appsv1.StatefulSet, error)
 	// GetStatefulSetPods will retrieve the pods managed by a given StatefulSet.
 	GetStatefulSetPods(namespace, name string) (*corev1.PodList, error)
+	// ListStatefulSetByLabels list the StatefulSets matching the labels.
+	ListStatefulSetByLabels(namespace string, labels client.MatchingLabels) (*appsv1.StatefulSetList, error)
 }
 
 type stateFulSetController struct {
@@ -70,6 +73,22 @@ func (s *stateFulSetController) GetStatefulSetPods(namespace, name string) (*cor
 		match[k] = v
 	}
 	foundPods := &corev1.PodList{}
-	err = s.client.List(context.TODO(), foundPods, match)
-	return foundPods, err
+	if err := s.client.List(context.TODO(), foundPods, client.InNamespace(namespace), match); err != nil {
+		return nil, err
+	}
+	// the selectors of the statefulSets of a cluster may overlap, only keep the pods controlled by this one
+	ownedPods := &corev1.PodList{}
+	for _, pod := range foundPods.Items {
+		if metav1.IsControlledBy(&pod, statefulSet) {
+			ownedPods.Items = append(ownedPods.Items, pod)
+		}
+	}
+	return ownedPods, nil
+}
+
+// ListStatefulSetByLabels implement the IStatefulSetControl.Interface.
+func (s *stateFulSetController) ListStatefulSetByLabels(namespace string, labels client.MatchingLabels) (*appsv1.StatefulSetList, error) {
+	statefulSetList := &appsv1.StatefulSetList{}
+	err := s.client.List(context.TODO(), statefulSetList, client.InNamespace(namespace), labels)
+	return statefulSetList, err
 }
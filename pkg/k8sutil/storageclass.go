@@ -0,0 +1,34 @@
+package k8sutil
+
+import (
+	"context"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IStorageClassControl defines the interface that uses to get the StorageClasses of the data volumes.
+type IStorageClassControl interface {
+	// GetStorageClass get StorageClass.
+	GetStorageClass(name string) (*storagev1.StorageClass, error)
+}
+
+type storageClassController struct {
+	client client.Client
+}
+
+// NewStorageClassController creates a concrete implementation of the
+// IStorageClassControl.
+func NewStorageClassController(client client.Client) IStorageClassControl {
+	return &storageClassController{client: client}
+}
+
+// GetStorageClass implement the IStorageClassControl.Interface.
+func (s *storageClassController) GetStorageClass(name string) (*storagev1.StorageClass, error) {
+	class := &storagev1.StorageClass{}
+	err := s.client.Get(context.TODO(), types.NamespacedName{
+		Name: name,
+	}, class)
+	return class, err
+}
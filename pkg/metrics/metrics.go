@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+const metricsPrefix = "redis_cluster_"
+
+var clusterLabels = []string{"namespace", "name"}
+
+var (
+	healthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "healthy",
+		Help: "Whether the status of the cluster is Healthy.",
+	}, clusterLabels)
+	masters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "masters",
+		Help: "Number of masters owning slots, status.numberOfMaster of the cluster.",
+	}, clusterLabels)
+	minReplicationFactor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "min_replication_factor",
+		Help: "Smallest number of replicas of a master of the cluster.",
+	}, clusterLabels)
+	maxReplicationFactor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "max_replication_factor",
+		Help: "Largest number of replicas of a master of the cluster.",
+	}, clusterLabels)
+	slotsAssigned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "slots_assigned",
+		Help: "Number of hash slots owned by a master of the cluster.",
+	}, clusterLabels)
+	slotsUnassigned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "slots_unassigned",
+		Help: "Number of hash slots owned by no master of the cluster.",
+	}, clusterLabels)
+	nodesFlagged = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "nodes_flagged",
+		Help: "Number of nodes of the cluster flagged fail, pfail or handshake by at least one node.",
+	}, append(clusterLabels, "flag"))
+
+	slotsMigrated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "slots_migrated_total",
+		Help: "Number of hash slots migrated between masters of the cluster.",
+	}, clusterLabels)
+	keysMigrated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "keys_migrated_total",
+		Help: "Number of keys migrated between masters of the cluster.",
+	}, clusterLabels)
+	healActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "heal_actions_total",
+		Help: "Number of heal actions run on the cluster, by action.",
+	}, append(clusterLabels, "action"))
+	failovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "failovers_total",
+		Help: "Number of failovers started on the cluster.",
+	}, clusterLabels)
+	backups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "backups_total",
+		Help: "Number of completed backups of the cluster, by phase.",
+	}, append(clusterLabels, "phase"))
+)
+
+// flags are the node flags reported by the nodes_flagged gauge
+var flags = map[string]string{
+	redisutil.NodeStatusFail:      "fail",
+	redisutil.NodeStatusPFail:     "pfail",
+	redisutil.NodeStatusHandshake: "handshake",
+}
+
+// healActionNames are the actions counted by the heal_actions_total counter, see the manager healer
+var healActionNames = []string{"FixFailedNodes", "FixUntrustedNodes", "FixOpenSlots", "FixPlacement", "FixTerminatingPods"}
+
+// backupPhases are the final phases counted by the backups_total counter
+var backupPhases = []redisv1alpha1.BackupPhase{redisv1alpha1.BackupPhaseSucceeded, redisv1alpha1.BackupPhaseFailed}
+
+func init() {
+	metrics.Registry.MustRegister(
+		healthy,
+		masters,
+		minReplicationFactor,
+		maxReplicationFactor,
+		slotsAssigned,
+		slotsUnassigned,
+		nodesFlagged,
+		slotsMigrated,
+		keysMigrated,
+		healActions,
+		failovers,
+		backups,
+	)
+}
+
+// ObserveClusterStatus sets the gauges of the cluster reported by its status.
+func ObserveClusterStatus(namespace, name string, status *redisv1alpha1.DistributedRedisClusterStatus) {
+	if status.Status == redisv1alpha1.ClusterStatusOK {
+		healthy.WithLabelValues(namespace, name).Set(1)
+	} else {
+		healthy.WithLabelValues(namespace, name).Set(0)
+	}
+	masters.WithLabelValues(namespace, name).Set(float64(status.NumberOfMaster))
+	minReplicationFactor.WithLabelValues(namespace, name).Set(float64(status.MinReplicationFactor))
+	maxReplicationFactor.WithLabelValues(namespace, name).Set(float64(status.MaxReplicationFactor))
+}
+
+// ObserveClusterInfos sets the gauges of the cluster computed from the view of its nodes.
+func ObserveClusterInfos(namespace, name string, infos *redisutil.ClusterInfos) {
+	if infos == nil {
+		return
+	}
+	assigned := map[redisutil.Slot]bool{}
+	for _, node := range infos.GetNodes() {
+		for _, slot := range node.Slots {
+			assigned[slot] = true
+		}
+	}
+	slotsAssigned.WithLabelValues(namespace, name).Set(float64(len(assigned)))
+	slotsUnassigned.WithLabelValues(namespace, name).Set(float64(redisutil.DefaultHashMaxSlots + 1 - len(assigned)))
+
+	flagged := map[string]map[string]bool{}
+	for flag := range flags {
+		flagged[flag] = map[string]bool{}
+	}
+	for _, nodeInfos := range infos.Infos {
+		if nodeInfos == nil {
+			continue
+		}
+		for _, node := range nodeInfos.Friends {
+			for flag := range flags {
+				if node.HasStatus(flag) {
+					flagged[flag][node.ID] = true
+				}
+			}
+		}
+	}
+	for flag, label := range flags {
+		nodesFlagged.WithLabelValues(namespace, name, label).Set(float64(len(flagged[flag])))
+	}
+}
+
+// DeleteCluster removes the series of a deleted cluster.
+func DeleteCluster(namespace, name string) {
+	for _, vec := range []*prometheus.GaugeVec{healthy, masters, minReplicationFactor, maxReplicationFactor, slotsAssigned, slotsUnassigned} {
+		vec.DeleteLabelValues(namespace, name)
+	}
+	for _, label := range flags {
+		nodesFlagged.DeleteLabelValues(namespace, name, label)
+	}
+	slotsMigrated.DeleteLabelValues(namespace, name)
+	keysMigrated.DeleteLabelValues(namespace, name)
+	failovers.DeleteLabelValues(namespace, name)
+	for _, action := range healActionNames {
+		healActions.DeleteLabelValues(namespace, name, action)
+	}
+	for _, phase := range backupPhases {
+		backups.DeleteLabelValues(namespace, name, string(phase))
+	}
+}
+
+// SlotsMigrated counts the slots and keys migrated between two masters of the cluster.
+func SlotsMigrated(namespace, name string, slots, keys int) {
+	slotsMigrated.WithLabelValues(namespace, name).Add(float64(slots))
+	keysMigrated.WithLabelValues(namespace, name).Add(float64(keys))
+}
+
+// HealAction counts a heal action run on the cluster.
+func HealAction(namespace, name, action string) {
+	healActions.WithLabelValues(namespace, name, action).Inc()
+}
+
+// Failover counts a failover started on the cluster.
+func Failover(namespace, name string) {
+	failovers.WithLabelValues(namespace, name).Inc()
+}
+
+// BackupCompleted counts a backup of the cluster reaching a final phase.
+func BackupCompleted(namespace, clusterName string, phase redisv1alpha1.BackupPhase) {
+	backups.WithLabelValues(namespace, clusterName, string(phase)).Inc()
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+func TestObserveClusterStatus(t *testing.T) {
+	ObserveClusterStatus("default", "status", &redisv1alpha1.DistributedRedisClusterStatus{
+		Status:               redisv1alpha1.ClusterStatusOK,
+		NumberOfMaster:       3,
+		MinReplicationFactor: 1,
+		MaxReplicationFactor: 2,
+	})
+	for _, tt := range []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{"healthy", testutil.ToFloat64(healthy.WithLabelValues("default", "status")), 1},
+		{"masters", testutil.ToFloat64(masters.WithLabelValues("default", "status")), 3},
+		{"min_replication_factor", testutil.ToFloat64(minReplicationFactor.WithLabelValues("default", "status")), 1},
+		{"max_replication_factor", testutil.ToFloat64(maxReplicationFactor.WithLabelValues("default", "status")), 2},
+	} {
+		if tt.value != tt.want {
+			t.Errorf("ObserveClusterStatus() %s = %v, want %v", tt.name, tt.value, tt.want)
+		}
+	}
+
+	ObserveClusterStatus("default", "status", &redisv1alpha1.DistributedRedisClusterStatus{Status: redisv1alpha1.ClusterStatusKO})
+	if got := testutil.ToFloat64(healthy.WithLabelValues("default", "status")); got != 0 {
+		t.Errorf("ObserveClusterStatus() healthy = %v, want 0", got)
+	}
+}
+
+func TestObserveClusterInfos(t *testing.T) {
+	master := redisutil.NewDefaultNode()
+	master.ID = "1"
+	master.Slots = redisutil.BuildSlotSlice(0, 8191)
+	failed := redisutil.NewDefaultNode()
+	failed.ID = "2"
+	failed.FailStatus = []string{redisutil.NodeStatusFail}
+	pfailed := redisutil.NewDefaultNode()
+	pfailed.ID = "2"
+	pfailed.FailStatus = []string{redisutil.NodeStatusPFail}
+	infos := &redisutil.ClusterInfos{
+		Infos: map[string]*redisutil.NodeInfos{
+			"10.0.0.1:6379": {Node: master, Friends: redisutil.Nodes{failed}},
+			"10.0.0.3:6379": {Node: redisutil.NewDefaultNode(), Friends: redisutil.Nodes{pfailed}},
+		},
+	}
+
+	ObserveClusterInfos("default", "infos", infos)
+	if got := testutil.ToFloat64(slotsAssigned.WithLabelValues("default", "infos")); got != 8192 {
+		t.Errorf("ObserveClusterInfos() slots_assigned = %v, want 8192", got)
+	}
+	if got := testutil.ToFloat64(slotsUnassigned.WithLabelValues("default", "infos")); got != 8192 {
+		t.Errorf("ObserveClusterInfos() slots_unassigned = %v, want 8192", got)
+	}
+	for label, want := range map[string]float64{"fail": 1, "pfail": 1, "handshake": 0} {
+		if got := testutil.ToFloat64(nodesFlagged.WithLabelValues("default", "infos", label)); got != want {
+			t.Errorf("ObserveClusterInfos() nodes_flagged{flag=%q} = %v, want %v", label, got, want)
+		}
+	}
+
+	// the gauges are kept when the nodes could not be read
+	ObserveClusterInfos("default", "infos", nil)
+	if got := testutil.ToFloat64(slotsAssigned.WithLabelValues("default", "infos")); got != 8192 {
+		t.Errorf("ObserveClusterInfos(nil) slots_assigned = %v, want 8192", got)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	SlotsMigrated("default", "counters", 2, 10)
+	SlotsMigrated("default", "counters", 1, 5)
+	HealAction("default", "counters", "FixFailedNodes")
+	Failover("default", "counters")
+	BackupCompleted("default", "counters", redisv1alpha1.BackupPhaseSucceeded)
+	for _, tt := range []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{"slots_migrated_total", testutil.ToFloat64(slotsMigrated.WithLabelValues("default", "counters")), 3},
+		{"keys_migrated_total", testutil.ToFloat64(keysMigrated.WithLabelValues("default", "counters")), 15},
+		{"heal_actions_total", testutil.ToFloat64(healActions.WithLabelValues("default", "counters", "FixFailedNodes")), 1},
+		{"failovers_total", testutil.ToFloat64(failovers.WithLabelValues("default", "counters")), 1},
+		{"backups_total", testutil.ToFloat64(backups.WithLabelValues("default", "counters", string(redisv1alpha1.BackupPhaseSucceeded))), 1},
+	} {
+		if tt.value != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.value, tt.want)
+		}
+	}
+}
+
+func TestDeleteCluster(t *testing.T) {
+	ObserveClusterStatus("default", "deleted", &redisv1alpha1.DistributedRedisClusterStatus{Status: redisv1alpha1.ClusterStatusOK})
+	ObserveClusterInfos("default", "deleted", redisutil.NewClusterInfos())
+	Failover("default", "deleted")
+	BackupCompleted("default", "deleted", redisv1alpha1.BackupPhaseFailed)
+
+	DeleteCluster("default", "deleted")
+	for _, tt := range []struct {
+		name    string
+		deleted bool
+	}{
+		{"healthy", !healthy.DeleteLabelValues("default", "deleted")},
+		{"slots_unassigned", !slotsUnassigned.DeleteLabelValues("default", "deleted")},
+		{"nodes_flagged", !nodesFlagged.DeleteLabelValues("default", "deleted", "fail")},
+		{"failovers_total", !failovers.DeleteLabelValues("default", "deleted")},
+		{"backups_total", !backups.DeleteLabelValues("default", "deleted", string(redisv1alpha1.BackupPhaseFailed))},
+	} {
+		if !tt.deleted {
+			t.Errorf("DeleteCluster() kept the %s series", tt.name)
+		}
+	}
+}
@@ -144,6 +144,69 @@ func CheckBucketAccess(client client.Client, spec api.Backend, namespace string)
 	return c.HasWriteAccess()
 }
 
+// DeleteFolder removes all the items stored under the folder in the bucket of the backend.
+func DeleteFolder(client client.Client, spec api.Backend, namespace, folder string) error {
+	cfg, err := NewOSMContext(client, spec, namespace)
+	if err != nil {
+		return err
+	}
+	loc, err := stow.Dial(cfg.Provider, cfg.Config)
+	if err != nil {
+		return err
+	}
+	bucket, err := spec.Container()
+	if err != nil {
+		return err
+	}
+	c, err := loc.Container(bucket)
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimSuffix(folder, "/") + "/"
+	var ids []string
+	err = stow.Walk(c, prefix, 100, func(item stow.Item, err error) error {
+		if err != nil {
+			return err
+		}
+		ids = append(ids, item.ID())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.RemoveItem(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ItemSize returns the size in bytes of the item stored under the key in the bucket of the backend.
+func ItemSize(client client.Client, spec api.Backend, namespace, key string) (int64, error) {
+	cfg, err := NewOSMContext(client, spec, namespace)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := stow.Dial(cfg.Provider, cfg.Config)
+	if err != nil {
+		return 0, err
+	}
+	bucket, err := spec.Container()
+	if err != nil {
+		return 0, err
+	}
+	c, err := loc.Container(bucket)
+	if err != nil {
+		return 0, err
+	}
+	item, err := c.Item(key)
+	if err != nil {
+		return 0, err
+	}
+	return item.Size()
+}
+
 func NewOSMContext(client client.Client, spec api.Backend, namespace string) (*otx.Context, error) {
 	config := make(map[string][]byte)
 
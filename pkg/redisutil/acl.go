@@ -0,0 +1,82 @@
+package redisutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ACLDefaultUser is the user of the connections which don't authenticate with a username
+const ACLDefaultUser = "default"
+
+// ACLUser is a redis 6 ACL user
+type ACLUser struct {
+	Name     string
+	Password string
+	// Rules are the ACL rules of the user, e.g. "~cache:*", "+@read" or "-flushall"
+	Rules []string
+}
+
+// setUserArgs returns the arguments of the ACL SETUSER command replacing the user with the given one
+func (u ACLUser) setUserArgs() []interface{} {
+	args := []interface{}{"SETUSER", u.Name, "reset", "on"}
+	if u.Password == "" {
+		args = append(args, "nopass")
+	} else {
+		args = append(args, ">"+u.Password)
+	}
+	for _, rule := range u.Rules {
+		args = append(args, rule)
+	}
+	return args
+}
+
+// parseACLUserNames returns the names of the users of the ACL LIST reply, made of lines like "user <name> on ..."
+func parseACLUserNames(lines []string) []string {
+	names := make([]string, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "user" {
+			names = append(names, fields[1])
+		}
+	}
+	return names
+}
+
+// SetACLUsers creates or replaces the given users on every node, and deletes the other users but the default
+// user and the protected ones
+func (a *Admin) SetACLUsers(users []ACLUser, protected []string) error {
+	keep := map[string]bool{ACLDefaultUser: true}
+	for _, name := range protected {
+		keep[name] = true
+	}
+	for _, user := range users {
+		keep[user.Name] = true
+	}
+	for addr, c := range a.Connections().GetAll() {
+		resp := c.Cmd("ACL", "LIST")
+		if err := a.Connections().ValidateResp(resp, addr, "unable to list the ACL users"); err != nil {
+			return err
+		}
+		lines, err := resp.List()
+		if err != nil {
+			return fmt.Errorf("unable to parse the ACL users of node %s: %v", addr, err)
+		}
+		for _, name := range parseACLUserNames(lines) {
+			if keep[name] {
+				continue
+			}
+			log.Info("ACL DELUSER", "addr", addr, "user", name)
+			resp := c.Cmd("ACL", "DELUSER", name)
+			if err := a.Connections().ValidateResp(resp, addr, "unable to delete the ACL user"); err != nil {
+				return err
+			}
+		}
+		for _, user := range users {
+			resp := c.Cmd("ACL", user.setUserArgs()...)
+			if err := a.Connections().ValidateResp(resp, addr, fmt.Sprintf("unable to set the ACL user %s", user.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestACLUser_setUserArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		user ACLUser
+		want []interface{}
+	}{
+		{
+			name: "password",
+			user: ACLUser{Name: "app", Password: "secret", Rules: []string{"~cache:*", "+@read"}},
+			want: []interface{}{"SETUSER", "app", "reset", "on", ">secret", "~cache:*", "+@read"},
+		},
+		{
+			name: "no password",
+			user: ACLUser{Name: "app", Rules: []string{"+ping"}},
+			want: []interface{}{"SETUSER", "app", "reset", "on", "nopass", "+ping"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.setUserArgs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setUserArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseACLUserNames(t *testing.T) {
+	lines := []string{
+		"user app on #2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b ~cache:* -@all +@read",
+		"user default on nopass ~* +@all",
+		"",
+	}
+	want := []string{"app", "default"}
+	if got := parseACLUserNames(lines); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseACLUserNames() = %v, want %v", got, want)
+	}
+}
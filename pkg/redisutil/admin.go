@@ -1,10 +1,12 @@
 package redisutil
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,14 +19,27 @@ const (
 	ResetHard = "HARD"
 	// ResetSoft SOFT mode for RESET command
 	ResetSoft = "SOFT"
+
+	// failoverRetry number of seconds to wait for a manual failover to complete
+	failoverRetry = 30
 )
 
 const (
 	clusterKnownNodesREString = "cluster_known_nodes:([0-9]+)"
+	replicationOffsetREString = "master_repl_offset:([0-9]+)"
+	masterLinkStatusREString  = "master_link_status:([a-z]+)"
+	bgsaveInProgressREString  = "rdb_bgsave_in_progress:([0-9]+)"
+	lastBGSaveStatusREString  = "rdb_last_bgsave_status:([a-z]+)"
+	lastSaveTimeREString      = "rdb_last_save_time:([0-9]+)"
 )
 
 var (
 	clusterKnownNodesRE = regexp.MustCompile(clusterKnownNodesREString)
+	replicationOffsetRE = regexp.MustCompile(replicationOffsetREString)
+	masterLinkStatusRE  = regexp.MustCompile(masterLinkStatusREString)
+	bgsaveInProgressRE  = regexp.MustCompile(bgsaveInProgressREString)
+	lastBGSaveStatusRE  = regexp.MustCompile(lastBGSaveStatusREString)
+	lastSaveTimeRE      = regexp.MustCompile(lastSaveTimeREString)
 )
 
 // IAdmin redis cluster admin interface
@@ -42,6 +57,15 @@ type IAdmin interface {
 	SetConfigEpoch() error
 	// SetConfigIfNeed set redis config
 	SetConfigIfNeed(newConfig map[string]string) error
+	// SetPassword adds the password of the default user and sets the password used to authenticate with the
+	// master, on every node, the old password is still accepted until RemovePassword
+	SetPassword(password string) error
+	// RemovePassword removes a password of the default user on every node
+	RemovePassword(password string) error
+	// GetNodeConfig returns the config of the node corresponding to the addr
+	GetNodeConfig(addr string) (map[string]string, error)
+	// SetNodeConfigIfNeed set redis config of the node corresponding to the addr
+	SetNodeConfigIfNeed(addr string, newConfig map[string]string) error
 	//// InitRedisCluster used to configure the first node of a cluster
 	//InitRedisCluster(addr string) error
 	//// GetClusterInfosSelected return the Nodes infos for all nodes selected in the cluster
@@ -53,8 +77,21 @@ type IAdmin interface {
 	AttachSlaveToMaster(slave *Node, masterID string) error
 	// DetachSlave dettach a slave to its master
 	DetachSlave(slave *Node) error
-	//// StartFailover execute the failover of the Redis Master corresponding to the addr
-	//StartFailover(addr string) error
+	// StartFailover execute a manual failover on the Redis slave corresponding to the addr, the slave
+	// is promoted master of the slots of its current master
+	StartFailover(addr string) error
+	// Failover sends a manual failover to the Redis slave corresponding to the addr, without waiting
+	// for the slave to be promoted
+	Failover(addr string) error
+	// GetReplicationOffset get the replication offset of the node, for a slave it is the offset
+	// processed from its master
+	GetReplicationOffset(addr string) (int64, error)
+	// IsMasterLinkUp returns true if the replication link of the slave corresponding to the addr is up
+	IsMasterLinkUp(addr string) (bool, error)
+	// BGSave starts a background save of the node corresponding to the addr
+	BGSave(addr string) error
+	// GetPersistenceInfo get the state of the background saves of the node
+	GetPersistenceInfo(addr string) (*PersistenceInfo, error)
 	// ForgetNode execute the Redis command to force the cluster to forgot the the Node
 	ForgetNode(id string) error
 	//// ForgetNodeByAddr execute the Redis command to force the cluster to forgot the the Node
@@ -70,19 +107,23 @@ type IAdmin interface {
 	//DelSlots(addr string, slots []Slot) error
 	//// GetKeysInSlot exec the redis command to get the keys in the given slot on the node we are connected to
 	//GetKeysInSlot(addr string, slot Slot, batch int, limit bool) ([]string, error)
-	//// CountKeysInSlot exec the redis command to count the keys given slot on the node
-	//CountKeysInSlot(addr string, slot Slot) (int64, error)
-	// MigrateKeys from addr to destination node. returns number of slot migrated. If replace is true, replace key on busy error
-	MigrateKeys(addr string, dest *Node, slots []Slot, batch, timeout int, replace bool) (int, error)
-	// MigrateKeys use to migrate keys from slot to other slot. if replace is true, replace key on busy error
-	// timeout is in milliseconds
-	MigrateKeysInSlot(addr string, dest *Node, slot Slot, batch int, timeout int, replace bool) (int, error)
+	// CountKeysInSlot exec the redis command to count the keys given slot on the node
+	CountKeysInSlot(addr string, slot Slot) (int64, error)
+	// MigrateKeys from addr to destination node. returns number of slot migrated. If opts.Replace is true, replace key on busy error
+	MigrateKeys(addr string, dest *Node, slots []Slot, opts MigrateOptions) (int, error)
+	// MigrateKeys use to migrate keys from slot to other slot. if opts.Replace is true, replace key on busy error
+	MigrateKeysInSlot(addr string, dest *Node, slot Slot, opts MigrateOptions) (int, error)
 	// FlushAndReset reset the cluster configuration of the node, the node is flushed in the same pipe to ensure reset works
 	FlushAndReset(addr string, mode string) error
-	//// FlushAll flush all keys in cluster
-	//FlushAll()
+	// ResetNode reset the cluster configuration of the node without flushing it, a master still holding keys refuses the reset
+	ResetNode(addr string, mode string) error
+	// FlushAll flush all keys of the nodes of the connection map, the nodes must be masters
+	FlushAll() error
 	// GetHashMaxSlot get the max slot value
 	GetHashMaxSlot() Slot
+	// SetACLUsers creates or replaces the given users on every node, and deletes the other users but the default
+	// user and the protected ones
+	SetACLUsers(users []ACLUser, protected []string) error
 	////RebuildConnectionMap rebuild the connection map according to the given addresses
 	//RebuildConnectionMap(addrs []string, options *AdminOptions)
 }
@@ -93,12 +134,24 @@ type AdminOptions struct {
 	ClientName         string
 	RenameCommandsFile string
 	Password           string
+	// Username is the ACL user of the connections, the default user when empty
+	Username string
+	// FallbackCredentials are tried in order when a node rejects Username and Password
+	FallbackCredentials []Credentials
+	// AnnounceAddrs maps the address announced by a node (cluster-announce-ip/port) to the
+	// address the admin connects to
+	AnnounceAddrs map[string]string
+	// TLSConfig connects to the nodes over TLS when set
+	TLSConfig *tls.Config
 }
 
 // Admin wraps redis cluster admin logic
 type Admin struct {
-	hashMaxSlots Slot
-	cnx          IAdminConnections
+	hashMaxSlots  Slot
+	cnx           IAdminConnections
+	announceAddrs map[string]string
+	// memoryUsageFailed is set once MEMORY USAGE failed, the keys moved are no longer measured
+	memoryUsageFailed bool
 }
 
 // NewAdmin returns new AdminInterface instance
@@ -107,6 +160,9 @@ func NewAdmin(addrs []string, options *AdminOptions) IAdmin {
 	a := &Admin{
 		hashMaxSlots: DefaultHashMaxSlots,
 	}
+	if options != nil {
+		a.announceAddrs = options.AnnounceAddrs
+	}
 
 	// perform initial connections
 	a.cnx = NewAdminConnections(addrs, options)
@@ -169,6 +225,10 @@ func (a *Admin) getInfos(c IClient, addr string) (*NodeInfos, error) {
 	}
 
 	nodeInfos := DecodeNodeInfos(&raw, addr)
+	a.translateAnnounceAddr(nodeInfos.Node)
+	for _, friend := range nodeInfos.Friends {
+		a.translateAnnounceAddr(friend)
+	}
 
 	//if log.V(3) {
 	//	//Retrieve server info for debugging
@@ -194,6 +254,23 @@ func (a *Admin) getInfos(c IClient, addr string) (*NodeInfos, error) {
 	return nodeInfos, nil
 }
 
+// translateAnnounceAddr replaces the announced address of the node by the address the admin connects to
+func (a *Admin) translateAnnounceAddr(node *Node) {
+	if node == nil || len(a.announceAddrs) == 0 {
+		return
+	}
+	addr, ok := a.announceAddrs[node.IPPort()]
+	if !ok {
+		return
+	}
+	ip, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	node.IP = ip
+	node.Port = port
+}
+
 // ClusterManagerNodeIsEmpty Checks whether the node is empty. Node is considered not-empty if it has
 // some key or if it already knows other nodes
 func (a *Admin) ClusterManagerNodeIsEmpty() (bool, error) {
@@ -368,18 +445,92 @@ func (a *Admin) getAllConfig(c IClient, addr string) (map[string]string, error)
 // SetConfigIfNeed set redis config
 func (a *Admin) SetConfigIfNeed(newConfig map[string]string) error {
 	for addr, c := range a.Connections().GetAll() {
-		oldConfig, err := a.getAllConfig(c, addr)
-		if err != nil {
+		if err := a.setConfigIfNeed(c, addr, newConfig); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		for key, value := range newConfig {
-			if value != oldConfig[key] {
-				log.V(3).Info("CONFIG SET", key, value)
-				resp := c.Cmd("CONFIG", "SET", key, value)
-				if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve config"); err != nil {
-					return err
-				}
+// SetPassword adds the password of the default user and sets the password used to authenticate with the master,
+// on every node. The nodes supporting ACLs keep accepting the old password, so that the clients can switch to the
+// new one before it is removed by RemovePassword, the older nodes switch at once. The authenticated connections are
+// kept by redis.
+func (a *Admin) SetPassword(password string) error {
+	for addr, c := range a.Connections().GetAll() {
+		resp := c.Cmd("ACL", "SETUSER", ACLDefaultUser, ">"+password)
+		if resp != nil && isUnknownCommand(resp.Err) {
+			resp = c.Cmd("CONFIG", "SET", "requirepass", password)
+			if err := a.Connections().ValidateResp(resp, addr, "unable to set requirepass"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := a.Connections().ValidateResp(resp, addr, "unable to add the password of the default user"); err != nil {
+			return err
+		}
+	}
+	// every master accepts the new password, the replicas can authenticate with it
+	for addr, c := range a.Connections().GetAll() {
+		resp := c.Cmd("CONFIG", "SET", "masterauth", password)
+		if err := a.Connections().ValidateResp(resp, addr, "unable to set masterauth"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemovePassword removes a password of the default user on every node supporting ACLs, the older nodes only
+// accept their requirepass.
+func (a *Admin) RemovePassword(password string) error {
+	for addr, c := range a.Connections().GetAll() {
+		resp := c.Cmd("ACL", "SETUSER", ACLDefaultUser, "<"+password)
+		if resp != nil && isUnknownCommand(resp.Err) {
+			continue
+		}
+		if err := a.Connections().ValidateResp(resp, addr, "unable to remove the password of the default user"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isUnknownCommand returns true if the error is the reply of a node which doesn't support the command, e.g. the
+// ACL commands before redis 6.
+func isUnknownCommand(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unknown command")
+}
+
+// GetNodeConfig returns the config of the node corresponding to the addr
+func (a *Admin) GetNodeConfig(addr string) (map[string]string, error) {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	return a.getAllConfig(c, addr)
+}
+
+// SetNodeConfigIfNeed set redis config of the node corresponding to the addr
+func (a *Admin) SetNodeConfigIfNeed(addr string, newConfig map[string]string) error {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+	return a.setConfigIfNeed(c, addr, newConfig)
+}
+
+func (a *Admin) setConfigIfNeed(c IClient, addr string, newConfig map[string]string) error {
+	oldConfig, err := a.getAllConfig(c, addr)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range newConfig {
+		if value != oldConfig[key] {
+			log.V(3).Info("CONFIG SET", key, value)
+			resp := c.Cmd("CONFIG", "SET", key, value)
+			if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve config"); err != nil {
+				return err
 			}
 		}
 	}
@@ -391,9 +542,8 @@ func (a *Admin) GetHashMaxSlot() Slot {
 	return a.hashMaxSlots
 }
 
-// MigrateKeys use to migrate keys from slots to other slots. if replace is true, replace key on busy error
-// timeout is in milliseconds
-func (a *Admin) MigrateKeys(addr string, dest *Node, slots []Slot, batch int, timeout int, replace bool) (int, error) {
+// MigrateKeys use to migrate keys from slots to other slots. if opts.Replace is true, replace key on busy error
+func (a *Admin) MigrateKeys(addr string, dest *Node, slots []Slot, opts MigrateOptions) (int, error) {
 	if len(slots) == 0 {
 		return 0, nil
 	}
@@ -402,53 +552,34 @@ func (a *Admin) MigrateKeys(addr string, dest *Node, slots []Slot, batch int, ti
 	if err != nil {
 		return keyCount, err
 	}
-	timeoutStr := strconv.Itoa(timeout)
-	batchStr := strconv.Itoa(batch)
 
 	for _, slot := range slots {
-		for {
-			resp := c.Cmd("CLUSTER", "GETKEYSINSLOT", slot, batchStr)
-			if err := a.Connections().ValidateResp(resp, addr, "Unable to run command GETKEYSINSLOT"); err != nil {
-				return keyCount, err
-			}
-			keys, err := resp.List()
-			if err != nil {
-				log.Error(err, "wrong returned format for CLUSTER GETKEYSINSLOT")
-				return keyCount, err
-			}
-
-			keyCount += len(keys)
-			if len(keys) == 0 {
-				break
-			}
-
-			var args []string
-			if replace {
-				args = append([]string{dest.IP, dest.Port, "", "0", timeoutStr, "REPLACE", "KEYS"}, keys...)
-			} else {
-				args = append([]string{dest.IP, dest.Port, "", "0", timeoutStr, "KEYS"}, keys...)
-			}
-
-			resp = c.Cmd("MIGRATE", args)
-			if err := a.Connections().ValidateResp(resp, addr, "Unable to run command MIGRATE"); err != nil {
-				return keyCount, err
-			}
+		count, err := a.migrateKeysInSlot(c, addr, dest, slot, opts)
+		keyCount += count
+		if err != nil {
+			return keyCount, err
 		}
 	}
 
 	return keyCount, nil
 }
 
-// MigrateKeys use to migrate keys from slot to other slot. if replace is true, replace key on busy error
-// timeout is in milliseconds
-func (a *Admin) MigrateKeysInSlot(addr string, dest *Node, slot Slot, batch int, timeout int, replace bool) (int, error) {
-	keyCount := 0
+// MigrateKeysInSlot use to migrate keys from slot to other slot. if opts.Replace is true, replace key on busy error
+func (a *Admin) MigrateKeysInSlot(addr string, dest *Node, slot Slot, opts MigrateOptions) (int, error) {
 	c, err := a.Connections().Get(addr)
 	if err != nil {
-		return keyCount, err
+		return 0, err
 	}
-	timeoutStr := strconv.Itoa(timeout)
-	batchStr := strconv.Itoa(batch)
+	return a.migrateKeysInSlot(c, addr, dest, slot, opts)
+}
+
+// migrateKeysInSlot moves the keys of the slot by batches, no faster than the rates of the options.
+func (a *Admin) migrateKeysInSlot(c IClient, addr string, dest *Node, slot Slot, opts MigrateOptions) (int, error) {
+	keyCount := 0
+	timeoutStr := strconv.Itoa(opts.Timeout)
+	batchStr := strconv.Itoa(opts.Batch)
+	start := time.Now()
+	var movedBytes int64
 
 	for {
 		resp := c.Cmd("CLUSTER", "GETKEYSINSLOT", slot, batchStr)
@@ -461,13 +592,22 @@ func (a *Admin) MigrateKeysInSlot(addr string, dest *Node, slot Slot, batch int,
 			return keyCount, err
 		}
 
-		keyCount += len(keys)
 		if len(keys) == 0 {
 			break
 		}
 
+		if opts.BytesPerSecond > 0 && !a.memoryUsageFailed {
+			size, err := keysMemoryUsage(c, keys)
+			if err != nil {
+				// e.g. MEMORY is not allowed to the user of the operator
+				log.Error(err, "unable to measure the keys moved, only the keys per second are limited", "addr", addr)
+				a.memoryUsageFailed = true
+			}
+			movedBytes += size
+		}
+
 		var args []string
-		if replace {
+		if opts.Replace {
 			args = append([]string{dest.IP, dest.Port, "", "0", timeoutStr, "REPLACE", "KEYS"}, keys...)
 		} else {
 			args = append([]string{dest.IP, dest.Port, "", "0", timeoutStr, "KEYS"}, keys...)
@@ -477,11 +617,34 @@ func (a *Admin) MigrateKeysInSlot(addr string, dest *Node, slot Slot, batch int,
 		if err := a.Connections().ValidateResp(resp, addr, "Unable to run command MIGRATE"); err != nil {
 			return keyCount, err
 		}
+		keyCount += len(keys)
+
+		now := time.Now()
+		delay := throttleDelay(start, now, int64(keyCount), opts.KeysPerSecond)
+		// the bytes moved since start are not all measured once MEMORY USAGE failed
+		bytesDelay := throttleDelay(start, now, movedBytes, opts.BytesPerSecond)
+		if !a.memoryUsageFailed && bytesDelay > delay {
+			delay = bytesDelay
+		}
+		time.Sleep(delay)
 	}
 
 	return keyCount, nil
 }
 
+// CountKeysInSlot exec the redis command to count the keys given slot on the node
+func (a *Admin) CountKeysInSlot(addr string, slot Slot) (int64, error) {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return 0, err
+	}
+	resp := c.Cmd("CLUSTER", "COUNTKEYSINSLOT", slot)
+	if err := a.Connections().ValidateResp(resp, addr, "Unable to run command COUNTKEYSINSLOT"); err != nil {
+		return 0, err
+	}
+	return resp.Int64()
+}
+
 // ForgetNode used to force other redis cluster node to forget a specific node
 func (a *Admin) ForgetNode(id string) error {
 	infos, _ := a.GetClusterInfos()
@@ -508,11 +671,157 @@ func (a *Admin) ForgetNode(id string) error {
 	return nil
 }
 
+// StartFailover execute a manual failover on the Redis slave corresponding to the addr and wait until
+// the slave has been promoted master
+func (a *Admin) StartFailover(addr string) error {
+	if err := a.Failover(addr); err != nil {
+		return err
+	}
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < failoverRetry; i++ {
+		time.Sleep(1 * time.Second)
+		nodeInfos, err := a.getInfos(c, addr)
+		if err != nil {
+			log.Error(err, "unable to retrieve node info during failover", "addr", addr)
+			continue
+		}
+		if nodeInfos.Node.Role == RedisMasterRole {
+			log.Info("failover done", "addr", addr)
+			return nil
+		}
+	}
+	return fmt.Errorf("failover of node %s not done after %d seconds", addr, failoverRetry)
+}
+
+// Failover sends a manual failover to the Redis slave corresponding to the addr, the slave is
+// promoted once it has processed the replication stream of its master
+func (a *Admin) Failover(addr string) error {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Cmd("CLUSTER", "FAILOVER")
+	return a.Connections().ValidateResp(resp, addr, "unable to run command FAILOVER")
+}
+
+// GetReplicationOffset get the replication offset of the node, for a slave it is the offset
+// processed from its master
+func (a *Admin) GetReplicationOffset(addr string) (int64, error) {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	resp := c.Cmd("INFO", "REPLICATION")
+	if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve replication info"); err != nil {
+		return 0, err
+	}
+
+	raw, err := resp.Str()
+	if err != nil {
+		return 0, fmt.Errorf("wrong format from INFO REPLICATION: %v", err)
+	}
+
+	match := replicationOffsetRE.FindStringSubmatch(raw)
+	if len(match) == 0 {
+		return 0, fmt.Errorf("master_repl_offset regex not found")
+	}
+	return strconv.ParseInt(match[1], 10, 64)
+}
+
+// IsMasterLinkUp returns true if the replication link of the slave corresponding to the addr is up
+func (a *Admin) IsMasterLinkUp(addr string) (bool, error) {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return false, err
+	}
+
+	resp := c.Cmd("INFO", "REPLICATION")
+	if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve replication info"); err != nil {
+		return false, err
+	}
+
+	raw, err := resp.Str()
+	if err != nil {
+		return false, fmt.Errorf("wrong format from INFO REPLICATION: %v", err)
+	}
+
+	match := masterLinkStatusRE.FindStringSubmatch(raw)
+	if len(match) == 0 {
+		return false, nil
+	}
+	return match[1] == "up", nil
+}
+
+// BGSave starts a background save of the node corresponding to the addr
+func (a *Admin) BGSave(addr string) error {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Cmd("BGSAVE")
+	return a.Connections().ValidateResp(resp, addr, "unable to start background save")
+}
+
+// PersistenceInfo is the state of the background saves of a node, reported by INFO PERSISTENCE
+type PersistenceInfo struct {
+	// BGSaveInProgress is true while a background save is running
+	BGSaveInProgress bool
+	// LastBGSaveOK is true if the last background save succeeded
+	LastBGSaveOK bool
+	// LastSaveTime is the unix time of the last successful save
+	LastSaveTime int64
+}
+
+// GetPersistenceInfo get the state of the background saves of the node, unlike LASTSAVE it tells when a
+// background save completed within the second of the previous one
+func (a *Admin) GetPersistenceInfo(addr string) (*PersistenceInfo, error) {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Cmd("INFO", "PERSISTENCE")
+	if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve persistence info"); err != nil {
+		return nil, err
+	}
+
+	raw, err := resp.Str()
+	if err != nil {
+		return nil, fmt.Errorf("wrong format from INFO PERSISTENCE: %v", err)
+	}
+	return parsePersistenceInfo(raw)
+}
+
+func parsePersistenceInfo(raw string) (*PersistenceInfo, error) {
+	inProgress := bgsaveInProgressRE.FindStringSubmatch(raw)
+	status := lastBGSaveStatusRE.FindStringSubmatch(raw)
+	lastSave := lastSaveTimeRE.FindStringSubmatch(raw)
+	if len(inProgress) == 0 || len(status) == 0 || len(lastSave) == 0 {
+		return nil, fmt.Errorf("rdb_bgsave_in_progress, rdb_last_bgsave_status or rdb_last_save_time regex not found")
+	}
+	lastSaveTime, err := strconv.ParseInt(lastSave[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &PersistenceInfo{
+		BGSaveInProgress: inProgress[1] == "1",
+		LastBGSaveOK:     status[1] == "ok",
+		LastSaveTime:     lastSaveTime,
+	}, nil
+}
+
 // DetachSlave use to detach a slave to a master
 func (a *Admin) DetachSlave(slave *Node) error {
 	c, err := a.Connections().Get(slave.IPPort())
 	if err != nil {
-		log.Error(err, fmt.Sprintf("unable to get the connection for slave ID:%s, addr:%s , err:%v", slave.ID, slave.IPPort()))
+		log.Error(err, fmt.Sprintf("unable to get the connection for slave ID:%s, addr:%s", slave.ID, slave.IPPort()))
 		return err
 	}
 
@@ -532,6 +841,17 @@ func (a *Admin) DetachSlave(slave *Node) error {
 	return nil
 }
 
+// FlushAll flush all keys of the nodes of the connection map, the nodes must be masters
+func (a *Admin) FlushAll() error {
+	for addr, c := range a.Connections().GetAll() {
+		resp := c.Cmd("FLUSHALL")
+		if err := a.Connections().ValidateResp(resp, addr, "unable to flush all keys"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // FlushAndReset flush the cluster and reset the cluster configuration of the node. Commands are piped, to ensure no items arrived between flush and reset
 func (a *Admin) FlushAndReset(addr string, mode string) error {
 	c, err := a.Connections().Get(addr)
@@ -547,3 +867,15 @@ func (a *Admin) FlushAndReset(addr string, mode string) error {
 
 	return nil
 }
+
+// ResetNode reset the cluster configuration of the node without flushing it. A slave is detached from its
+// master and a master still holding keys refuses the reset, so no data is lost by mistake.
+func (a *Admin) ResetNode(addr string, mode string) error {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Cmd("CLUSTER", "RESET", mode)
+	return a.Connections().ValidateResp(resp, addr, "Cannot reset node")
+}
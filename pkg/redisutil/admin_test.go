@@ -0,0 +1,44 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parsePersistenceInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    *PersistenceInfo
+		wantErr bool
+	}{
+		{
+			name: "save in progress",
+			raw: "# Persistence\r\nloading:0\r\nrdb_changes_since_last_save:12\r\nrdb_bgsave_in_progress:1\r\n" +
+				"rdb_last_save_time:1577836800\r\nrdb_last_bgsave_status:ok\r\n",
+			want: &PersistenceInfo{BGSaveInProgress: true, LastBGSaveOK: true, LastSaveTime: 1577836800},
+		},
+		{
+			name: "last save failed",
+			raw: "# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:0\r\n" +
+				"rdb_last_save_time:1577836800\r\nrdb_last_bgsave_status:err\r\n",
+			want: &PersistenceInfo{LastSaveTime: 1577836800},
+		},
+		{
+			name:    "not the persistence section",
+			raw:     "# Replication\r\nrole:master\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePersistenceInfo(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePersistenceInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePersistenceInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
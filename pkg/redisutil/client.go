@@ -1,6 +1,8 @@
 package redisutil
 
 import (
+	"crypto/tls"
+	"net"
 	"strings"
 	"time"
 
@@ -46,21 +48,68 @@ type Client struct {
 	client          *redis.Client
 }
 
-// NewClient build a client connection and connect to a redis address
-func NewClient(addr, password string, cnxTimeout time.Duration, commandsMapping map[string]string) (IClient, error) {
+// Credentials authenticate a connection, the default user is used when Username is empty
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// NewClient build a client connection and connect to a redis address, over TLS when tlsConfig is not nil.
+// The credentials are tried in order until one is accepted by the node.
+func NewClient(addr string, credentials []Credentials, cnxTimeout time.Duration, commandsMapping map[string]string, tlsConfig *tls.Config) (IClient, error) {
 	var err error
 	c := &Client{
 		commandsMapping: commandsMapping,
 	}
 
-	c.client, err = redis.DialTimeout("tcp", addr, cnxTimeout)
+	if tlsConfig == nil {
+		c.client, err = redis.DialTimeout("tcp", addr, cnxTimeout)
+	} else {
+		c.client, err = dialTLS(addr, cnxTimeout, tlsConfig)
+	}
 	if err != nil {
 		return c, err
 	}
-	if password != "" {
-		err = c.client.Cmd("AUTH", password).Err
+	return c, c.auth(credentials)
+}
+
+func (c *Client) auth(credentials []Credentials) error {
+	var resp *redis.Resp
+	for _, cred := range credentials {
+		switch {
+		case cred.Username != "":
+			resp = c.client.Cmd("AUTH", cred.Username, cred.Password)
+		case cred.Password != "":
+			resp = c.client.Cmd("AUTH", cred.Password)
+		default:
+			// the default user without password
+			return nil
+		}
+		if resp.Err == nil || !resp.IsType(redis.AppErr) {
+			// authenticated, or the connection failed
+			return resp.Err
+		}
+	}
+	if resp == nil {
+		return nil
+	}
+	return resp.Err
+}
+
+// dialTLS connects to a redis address over TLS, the timeout is used as the read/write timeout like in redis.DialTimeout
+func dialTLS(addr string, timeout time.Duration, tlsConfig *tls.Config) (*redis.Client, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := redis.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
-	return c, err
+	client.ReadTimeout = timeout
+	client.WriteTimeout = timeout
+	return client, nil
 }
 
 // Close closes the connection.
@@ -0,0 +1,116 @@
+package redisutil
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+// serveAuth answers the AUTH commands read on the connection, accepting only the given credentials. It records
+// the credentials tried and closes the connection after closeAfter commands when it is not zero.
+func serveAuth(conn net.Conn, accepted Credentials, closeAfter int, tried chan<- []string) {
+	defer conn.Close()
+	var credentials []string
+	defer func() { tried <- credentials }()
+	reader := redis.NewRespReader(conn)
+	for {
+		resp := reader.Read()
+		if resp.Err != nil {
+			return
+		}
+		args, err := resp.Array()
+		if err != nil {
+			return
+		}
+		var strs []string
+		for _, arg := range args {
+			str, _ := arg.Str()
+			strs = append(strs, str)
+		}
+		credentials = append(credentials, fmt.Sprint(strs[1:]))
+		if closeAfter > 0 && len(credentials) == closeAfter {
+			return
+		}
+		username, password := "default", strs[len(strs)-1]
+		if len(strs) == 3 {
+			username = strs[1]
+		}
+		acceptedUser := accepted.Username
+		if acceptedUser == "" {
+			acceptedUser = "default"
+		}
+		if username == acceptedUser && password == accepted.Password {
+			conn.Write([]byte("+OK\r\n"))
+		} else {
+			conn.Write([]byte("-WRONGPASS invalid username-password pair\r\n"))
+		}
+	}
+}
+
+func TestClient_auth(t *testing.T) {
+	operator := Credentials{Username: "operator", Password: "operator"}
+	tests := []struct {
+		name        string
+		credentials []Credentials
+		accepted    Credentials
+		closeAfter  int
+		wantTried   []string
+		wantErr     bool
+	}{
+		{
+			name:        "first credentials accepted",
+			credentials: []Credentials{operator, {Password: "new"}},
+			accepted:    operator,
+			wantTried:   []string{"[operator operator]"},
+		},
+		{
+			name:        "fallback to the default user",
+			credentials: []Credentials{operator, {Password: "new"}, {Password: "old"}},
+			accepted:    Credentials{Password: "old"},
+			wantTried:   []string{"[operator operator]", "[new]", "[old]"},
+		},
+		{
+			name:        "no credentials accepted",
+			credentials: []Credentials{{Password: "new"}, {Password: "old"}},
+			accepted:    Credentials{Password: "other"},
+			wantTried:   []string{"[new]", "[old]"},
+			wantErr:     true,
+		},
+		{
+			name:        "default user without password",
+			credentials: []Credentials{{}},
+		},
+		{
+			name:        "connection closed",
+			credentials: []Credentials{{Password: "new"}, {Password: "old"}},
+			accepted:    Credentials{Password: "old"},
+			closeAfter:  1,
+			wantTried:   []string{"[new]"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			tried := make(chan []string, 1)
+			go serveAuth(serverConn, tt.accepted, tt.closeAfter, tried)
+			client, err := redis.NewClient(clientConn)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			c := &Client{client: client}
+
+			err = c.auth(tt.credentials)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("auth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			c.Close()
+			if got := <-tried; !reflect.DeepEqual(got, tt.wantTried) {
+				t.Errorf("auth() tried %v, want %v", got, tt.wantTried)
+			}
+		})
+	}
+}
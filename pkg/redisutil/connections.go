@@ -2,6 +2,7 @@ package redisutil
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -70,7 +71,8 @@ type AdminConnections struct {
 	connectionTimeout time.Duration
 	commandsMapping   map[string]string
 	clientName        string
-	password          string
+	credentials       []Credentials
+	tlsConfig         *tls.Config
 }
 
 func init() {
@@ -93,7 +95,9 @@ func NewAdminConnections(addrs []string, options *AdminOptions) IAdminConnection
 			cnx.commandsMapping = buildCommandReplaceMapping(options.RenameCommandsFile)
 		}
 		cnx.clientName = options.ClientName
-		cnx.password = options.Password
+		cnx.credentials = append([]Credentials{{Username: options.Username, Password: options.Password}},
+			options.FallbackCredentials...)
+		cnx.tlsConfig = options.TLSConfig
 	}
 	cnx.AddAll(addrs)
 	return cnx
@@ -263,7 +267,7 @@ func (cnx *AdminConnections) handleError(addr string, err error) bool {
 }
 
 func (cnx *AdminConnections) connect(addr string) (IClient, error) {
-	c, err := NewClient(addr, cnx.password, cnx.connectionTimeout, cnx.commandsMapping)
+	c, err := NewClient(addr, cnx.credentials, cnx.connectionTimeout, cnx.commandsMapping, cnx.tlsConfig)
 	if err != nil {
 		return nil, err
 	}
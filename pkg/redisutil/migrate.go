@@ -0,0 +1,63 @@
+package redisutil
+
+import (
+	"time"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+// MigrateOptions tunes how the keys of the slots are moved by MIGRATE.
+type MigrateOptions struct {
+	// Batch is the number of keys moved by each MIGRATE.
+	Batch int
+	// Timeout of each MIGRATE in milliseconds.
+	Timeout int
+	// Replace replaces the keys which already exist on the destination.
+	Replace bool
+	// KeysPerSecond limits the rate the keys are moved at, unlimited if 0.
+	KeysPerSecond int64
+	// BytesPerSecond limits the rate the keys are moved at, measured with MEMORY USAGE, unlimited if 0.
+	BytesPerSecond int64
+}
+
+// DefaultMigrateOptions are the options the keys are moved with when they are not configured.
+var DefaultMigrateOptions = MigrateOptions{
+	Batch:   10,
+	Timeout: 30000,
+	Replace: true,
+}
+
+// throttleDelay returns how long to wait for moved units not to have been moved faster than rate per second
+// since start, or 0 when rate is unlimited.
+func throttleDelay(start time.Time, now time.Time, moved, rate int64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	expected := time.Duration(float64(moved) / float64(rate) * float64(time.Second))
+	if delay := expected - now.Sub(start); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// keysMemoryUsage returns the bytes used by the keys, measured with a MEMORY USAGE per key sent in a single
+// pipeline. A key deleted in the meantime has no usage.
+func keysMemoryUsage(c IClient, keys []string) (int64, error) {
+	for _, key := range keys {
+		c.PipeAppend("MEMORY", "USAGE", key)
+	}
+	var total int64
+	for range keys {
+		resp := c.PipeResp()
+		if resp.IsType(redis.Nil) {
+			continue
+		}
+		size, err := resp.Int64()
+		if err != nil {
+			c.PipeClear()
+			return total, err
+		}
+		total += size
+	}
+	return total, nil
+}
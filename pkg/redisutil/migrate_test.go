@@ -0,0 +1,99 @@
+package redisutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+func Test_throttleDelay(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		moved   int64
+		rate    int64
+		want    time.Duration
+	}{
+		{name: "unlimited", elapsed: 0, moved: 1000, rate: 0, want: 0},
+		{name: "too fast", elapsed: 500 * time.Millisecond, moved: 100, rate: 100, want: 500 * time.Millisecond},
+		{name: "slow enough", elapsed: 2 * time.Second, moved: 100, rate: 100, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttleDelay(start, start.Add(tt.elapsed), tt.moved, tt.rate); got != tt.want {
+				t.Errorf("throttleDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pipeClient replies to the pipelined commands with the given responses, in order
+type pipeClient struct {
+	IClient
+	resps    []*redis.Resp
+	appended int
+	cleared  bool
+}
+
+func (c *pipeClient) PipeAppend(cmd string, args ...interface{}) {
+	c.appended++
+}
+
+func (c *pipeClient) PipeResp() *redis.Resp {
+	resp := c.resps[0]
+	c.resps = c.resps[1:]
+	return resp
+}
+
+func (c *pipeClient) PipeClear() (int, int) {
+	c.cleared = true
+	return 0, len(c.resps)
+}
+
+func Test_keysMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name        string
+		resps       []*redis.Resp
+		want        int64
+		wantErr     bool
+		wantCleared bool
+	}{
+		{
+			name:  "all the keys measured",
+			resps: []*redis.Resp{redis.NewResp(100), redis.NewResp(50)},
+			want:  150,
+		},
+		{
+			name:  "key deleted in the meantime",
+			resps: []*redis.Resp{redis.NewResp(100), redis.NewResp(nil)},
+			want:  100,
+		},
+		{
+			name:        "command not allowed",
+			resps:       []*redis.Resp{redis.NewResp(errors.New("NOPERM this user has no permissions to run the 'memory' command")), redis.NewResp(50)},
+			wantErr:     true,
+			wantCleared: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &pipeClient{resps: tt.resps}
+			got, err := keysMemoryUsage(c, []string{"a", "b"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keysMemoryUsage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("keysMemoryUsage() = %d, want %d", got, tt.want)
+			}
+			if c.appended != 2 {
+				t.Errorf("keysMemoryUsage() pipelined %d commands, want 2", c.appended)
+			}
+			if c.cleared != tt.wantCleared {
+				t.Errorf("keysMemoryUsage() cleared the pipeline = %v, want %v", c.cleared, tt.wantCleared)
+			}
+		})
+	}
+}
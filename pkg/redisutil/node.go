@@ -61,8 +61,11 @@ type Node struct {
 	ImportingSlots  map[Slot]string
 	ServerStartTime time.Time
 
-	NodeName string
-	PodName  string
+	NodeName    string
+	PodName     string
+	StatefulSet string
+	// Zone is the topology domain of the kubernetes node, set when the cluster has a topologyKey
+	Zone string
 }
 
 // Nodes represent a Node slice
@@ -167,11 +170,13 @@ func (n Nodes) GetNodesByFunc(f FindNodeFunc) (Nodes, error) {
 // ToAPINode used to convert the current Node to an API redisv1alpha1.RedisClusterNode
 func (n *Node) ToAPINode() redisv1alpha1.RedisClusterNode {
 	apiNode := redisv1alpha1.RedisClusterNode{
-		ID:      n.ID,
-		IP:      n.IP,
-		PodName: n.PodName,
-		Role:    n.GetRole(),
-		Slots:   []string{},
+		ID:          n.ID,
+		IP:          n.IP,
+		PodName:     n.PodName,
+		StatefulSet: n.StatefulSet,
+		Zone:        n.Zone,
+		Role:        n.GetRole(),
+		Slots:       []string{},
 	}
 
 	return apiNode
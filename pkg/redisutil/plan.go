@@ -0,0 +1,198 @@
+package redisutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plan records the changes of a cluster computed in dry-run mode. A nil Plan records nothing.
+type Plan struct {
+	steps []planStep
+}
+
+// planStep is a change of the plan. The keys moved by consecutive migrations between the same nodes are
+// merged into a single step.
+type planStep struct {
+	text     string
+	from, to string
+	slots    []Slot
+}
+
+// Add records a change of the cluster.
+func (p *Plan) Add(format string, args ...interface{}) {
+	if p == nil {
+		return
+	}
+	p.steps = append(p.steps, planStep{text: fmt.Sprintf(format, args...)})
+}
+
+// addMove records the migration of the keys of slots from a node to another.
+func (p *Plan) addMove(from, to string, slots []Slot) {
+	if p == nil {
+		return
+	}
+	if last := len(p.steps) - 1; last >= 0 && p.steps[last].text == "" && p.steps[last].from == from && p.steps[last].to == to {
+		p.steps[last].slots = append(p.steps[last].slots, slots...)
+		return
+	}
+	p.steps = append(p.steps, planStep{from: from, to: to, slots: append([]Slot{}, slots...)})
+}
+
+// Steps returns the changes in the order they were recorded.
+func (p *Plan) Steps() []string {
+	if p == nil {
+		return nil
+	}
+	var steps []string
+	for _, step := range p.steps {
+		if step.text != "" {
+			steps = append(steps, step.text)
+			continue
+		}
+		slots := append([]Slot{}, step.slots...)
+		sort.Sort(SlotSlice(slots))
+		steps = append(steps, fmt.Sprintf("move the slots %s from %s to %s", SlotSlice(slots), step.from, step.to))
+	}
+	return steps
+}
+
+// planAdmin is an IAdmin recording the changes of the cluster in a Plan instead of making them, the
+// informations are still read from the nodes.
+type planAdmin struct {
+	IAdmin
+	plan *Plan
+}
+
+// NewPlanAdmin returns an admin reading the nodes with the given admin and recording its changes in the plan.
+// The IMPORTING, MIGRATING and NODE states of the slots are not recorded, they are part of the slot moves.
+func NewPlanAdmin(admin IAdmin, plan *Plan) IAdmin {
+	return &planAdmin{IAdmin: admin, plan: plan}
+}
+
+func (a *planAdmin) SetConfigEpoch() error {
+	a.plan.Add("assign a different config epoch to each node")
+	return nil
+}
+
+// SetConfigIfNeed records the config differing from the one of each node.
+func (a *planAdmin) SetConfigIfNeed(newConfig map[string]string) error {
+	addrs := make([]string, 0, len(a.Connections().GetAll()))
+	for addr := range a.Connections().GetAll() {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if err := a.SetNodeConfigIfNeed(addr, newConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *planAdmin) SetPassword(password string) error {
+	a.plan.Add("set the password of the nodes")
+	return nil
+}
+
+func (a *planAdmin) RemovePassword(password string) error {
+	a.plan.Add("remove the previous password of the nodes")
+	return nil
+}
+
+// SetNodeConfigIfNeed records the config differing from the one of the node.
+func (a *planAdmin) SetNodeConfigIfNeed(addr string, newConfig map[string]string) error {
+	oldConfig, err := a.GetNodeConfig(addr)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(newConfig))
+	for key, value := range newConfig {
+		if value != oldConfig[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		a.plan.Add("set %s to %q on %s", key, newConfig[key], addr)
+	}
+	return nil
+}
+
+func (a *planAdmin) AttachNodeToCluster(addr string) error {
+	a.plan.Add("meet %s", addr)
+	return nil
+}
+
+func (a *planAdmin) AttachSlaveToMaster(slave *Node, masterID string) error {
+	a.plan.Add("replicate %s from master %s", slave.IPPort(), masterID)
+	return nil
+}
+
+func (a *planAdmin) DetachSlave(slave *Node) error {
+	a.plan.Add("detach slave %s", slave.IPPort())
+	return nil
+}
+
+func (a *planAdmin) StartFailover(addr string) error {
+	a.plan.Add("failover %s", addr)
+	return nil
+}
+
+func (a *planAdmin) Failover(addr string) error {
+	a.plan.Add("failover %s", addr)
+	return nil
+}
+
+func (a *planAdmin) BGSave(addr string) error {
+	a.plan.Add("bgsave %s", addr)
+	return nil
+}
+
+func (a *planAdmin) ForgetNode(id string) error {
+	a.plan.Add("forget node %s", id)
+	return nil
+}
+
+func (a *planAdmin) SetSlots(addr string, action string, slots []Slot, nodeID string) error {
+	return nil
+}
+
+func (a *planAdmin) AddSlots(addr string, slots []Slot) error {
+	a.plan.Add("add the slots %s to %s", SlotSlice(slots), addr)
+	return nil
+}
+
+func (a *planAdmin) SetSlot(addr, action string, slot Slot, nodeID string) error {
+	return nil
+}
+
+func (a *planAdmin) MigrateKeys(addr string, dest *Node, slots []Slot, opts MigrateOptions) (int, error) {
+	a.plan.addMove(addr, dest.IPPort(), slots)
+	return 0, nil
+}
+
+func (a *planAdmin) MigrateKeysInSlot(addr string, dest *Node, slot Slot, opts MigrateOptions) (int, error) {
+	a.plan.addMove(addr, dest.IPPort(), []Slot{slot})
+	return 0, nil
+}
+
+func (a *planAdmin) FlushAndReset(addr string, mode string) error {
+	a.plan.Add("flush and reset %s %s", strings.ToLower(mode), addr)
+	return nil
+}
+
+func (a *planAdmin) ResetNode(addr string, mode string) error {
+	a.plan.Add("reset %s %s", strings.ToLower(mode), addr)
+	return nil
+}
+
+func (a *planAdmin) FlushAll() error {
+	a.plan.Add("flush all the masters")
+	return nil
+}
+
+func (a *planAdmin) SetACLUsers(users []ACLUser, protected []string) error {
+	a.plan.Add("set the ACL users")
+	return nil
+}
@@ -0,0 +1,38 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanAdmin(t *testing.T) {
+	plan := &Plan{}
+	admin := NewPlanAdmin(nil, plan)
+	nodeB := &Node{ID: "b", IP: "10.0.0.2", Port: "6379"}
+	nodeC := &Node{ID: "c", IP: "10.0.0.3", Port: "6379"}
+
+	admin.AttachSlaveToMaster(nodeC, "b")
+	for _, slot := range []Slot{3, 1, 2} {
+		admin.SetSlot(nodeB.IPPort(), "IMPORTING", slot, "a")
+		admin.MigrateKeysInSlot("10.0.0.1:6379", nodeB, slot, DefaultMigrateOptions)
+		admin.SetSlot(nodeB.IPPort(), "NODE", slot, "b")
+	}
+	admin.MigrateKeysInSlot("10.0.0.1:6379", nodeC, 5, DefaultMigrateOptions)
+	admin.MigrateKeys("10.0.0.1:6379", nodeB, []Slot{7, 8}, DefaultMigrateOptions)
+
+	want := []string{
+		"replicate 10.0.0.3:6379 from master b",
+		"move the slots [1-3] from 10.0.0.1:6379 to 10.0.0.2:6379",
+		"move the slots [5-5] from 10.0.0.1:6379 to 10.0.0.3:6379",
+		"move the slots [7-8] from 10.0.0.1:6379 to 10.0.0.2:6379",
+	}
+	if got := plan.Steps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Steps() = %q, want %q", got, want)
+	}
+
+	var nilPlan *Plan
+	nilPlan.Add("nothing")
+	if steps := nilPlan.Steps(); steps != nil {
+		t.Errorf("a nil plan should record nothing, got %q", steps)
+	}
+}
@@ -0,0 +1,144 @@
+package redisutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, PEM encoded
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert returns a certificate signed by the parent, self-signed when the parent is nil. It has no host
+// name nor IP, like the certificates of the redis nodes dialed by IP.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	otherCA := newTestCert(t, "other-ca", nil)
+	client := newTestCert(t, "operator", ca)
+	tests := []struct {
+		name    string
+		server  *testCert
+		wantErr bool
+	}{
+		{
+			name:   "node certificate signed by the CA",
+			server: newTestCert(t, "redis", ca),
+		},
+		{
+			name:    "node certificate signed by another CA",
+			server:  newTestCert(t, "redis", otherCA),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(ca.certPEM, client.certPEM, client.keyPEM)
+			if err != nil {
+				t.Fatalf("NewTLSConfig() error = %v", err)
+			}
+			serverCert, err := tls.X509KeyPair(tt.server.certPEM, tt.server.keyPEM)
+			if err != nil {
+				t.Fatalf("X509KeyPair() error = %v", err)
+			}
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			defer listener.Close()
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+
+			// the node is dialed by IP, its certificate has no IP
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", listener.Addr().String(), config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				conn.Close()
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_verifyPeerCertificate(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	client := newTestCert(t, "operator", ca)
+	config, err := NewTLSConfig(ca.certPEM, client.certPEM, client.keyPEM)
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+	if !config.InsecureSkipVerify {
+		t.Errorf("NewTLSConfig() verifies the host names of the nodes dialed by IP")
+	}
+	if err := config.VerifyPeerCertificate(nil, nil); err == nil {
+		t.Errorf("VerifyPeerCertificate() accepted a node presenting no certificate")
+	}
+	if err := config.VerifyPeerCertificate([][]byte{[]byte("garbage")}, nil); err == nil {
+		t.Errorf("VerifyPeerCertificate() accepted an invalid certificate")
+	}
+
+	if _, err := NewTLSConfig([]byte("no CA"), client.certPEM, client.keyPEM); err == nil {
+		t.Errorf("NewTLSConfig() accepted a CA without certificate")
+	}
+	if _, err := NewTLSConfig(ca.certPEM, client.certPEM, ca.keyPEM); err == nil {
+		t.Errorf("NewTLSConfig() accepted a certificate with another key")
+	}
+}
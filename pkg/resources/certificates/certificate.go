@@ -0,0 +1,66 @@
+package certificates
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
+)
+
+const (
+	defaultIssuerKind  = "Issuer"
+	defaultIssuerGroup = "cert-manager.io"
+)
+
+// GroupVersionKind is the cert-manager Certificate, it is handled as an unstructured object so that the
+// operator does not depend on cert-manager being installed.
+var GroupVersionKind = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1alpha2",
+	Kind:    "Certificate",
+}
+
+// NewCertificateForCR creates a new cert-manager Certificate of the redis nodes of the given Cluster, stored in
+// the Secret the nodes mount. The same certificate authenticates the nodes as servers and as clients.
+func NewCertificateForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *unstructured.Unstructured {
+	issuer := cluster.Spec.TLS.IssuerRef
+	kind := issuer.Kind
+	if kind == "" {
+		kind = defaultIssuerKind
+	}
+	group := issuer.Group
+	if group == "" {
+		group = defaultIssuerGroup
+	}
+	headless := fmt.Sprintf("%s.%s.svc", cluster.Spec.ServiceName, cluster.Namespace)
+	client := fmt.Sprintf("%s.%s.svc", services.ClientServiceName(cluster.Spec.ServiceName), cluster.Namespace)
+
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(GroupVersionKind)
+	cert.SetName(cluster.TLSSecretName())
+	cert.SetNamespace(cluster.Namespace)
+	cert.SetLabels(labels)
+	cert.SetOwnerReferences(redisv1alpha1.DefaultOwnerReferences(cluster))
+	cert.Object["spec"] = map[string]interface{}{
+		"secretName": cluster.TLSSecretName(),
+		"commonName": headless,
+		"dnsNames": []interface{}{
+			headless,
+			"*." + headless,
+			client,
+			// the exporter sidecar connects to localhost
+			"localhost",
+		},
+		"ipAddresses": []interface{}{"127.0.0.1"},
+		"usages":      []interface{}{"server auth", "client auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  issuer.Name,
+			"kind":  kind,
+			"group": group,
+		},
+	}
+	return cert
+}
@@ -16,13 +16,21 @@ func NewConfigMapForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels ma
 	// Do CLUSTER FAILOVER when master down
 	shutdownContent := `#!/bin/sh
 CLUSTER_CONFIG="/data/nodes.conf"
+PASSWORD_FILE="` + redisv1alpha1.PasswordMountPath + `/password"
+TLS_ARGS=""
+if [ -n "${REDIS_TLS_DIR}" ]; then
+    TLS_ARGS="--tls --cert ${REDIS_TLS_DIR}/tls.crt --key ${REDIS_TLS_DIR}/tls.key --cacert ${REDIS_TLS_DIR}/ca.crt"
+fi
 failover() {
     echo "Do CLUSTER FAILOVER"
     masterID=$(cat ${CLUSTER_CONFIG} | grep "myself" | awk '{print $1}')
     echo "Master: ${masterID}"
     slave=$(cat ${CLUSTER_CONFIG} | grep ${masterID} | grep "slave" | awk '{NR==1;print $2}' | sed 's/:6379@16379//')
     echo "Slave: ${slave}"
-    redis-cli -h ${slave} -a "${REDIS_PASSWORD}" CLUSTER FAILOVER
+    # the mounted password is the current one after a rotation, the environment keeps the one of the startup
+    for password in "$(cat ${PASSWORD_FILE} 2>/dev/null)" "${REDIS_PASSWORD}"; do
+        redis-cli -h ${slave} -a "${password}" ${TLS_ARGS} CLUSTER FAILOVER | grep -q OK && break
+    done
 	echo "Wait for MASTER <-> SLAVE syncFinished"
 	sleep 20
 }
@@ -43,6 +51,15 @@ if [ -f ${CLUSTER_CONFIG} ]; then
     echo "Updating my IP to ${POD_IP} in ${CLUSTER_CONFIG}"
     sed -i.bak -e "/myself/ s/ .*:6379@16379/ ${POD_IP}:6379@16379/" ${CLUSTER_CONFIG}
 fi
+# announce the address of the service of the pod from the start, the node port services
+# announce the IP of the kubernetes node the pod runs on.
+ANNOUNCE_CONFIG="` + redisv1alpha1.AnnounceMountPath + `/$(hostname)"
+if [ -f ${ANNOUNCE_CONFIG} ]; then
+    . ${ANNOUNCE_CONFIG}
+    ANNOUNCE_IP=${HOST_IP:-${ANNOUNCE_IP}}
+    echo "Announcing ${ANNOUNCE_IP}:${ANNOUNCE_PORT}@${ANNOUNCE_BUS_PORT}"
+    set -- "$@" --cluster-announce-ip "${ANNOUNCE_IP}" --cluster-announce-port "${ANNOUNCE_PORT}" --cluster-announce-bus-port "${ANNOUNCE_BUS_PORT}"
+fi
 exec "$@"`
 
 	return &corev1.ConfigMap{
@@ -63,6 +80,23 @@ func RedisConfigMapName(clusterName string) string {
 	return fmt.Sprintf("%s-%s", "redis-cluster", clusterName)
 }
 
+// NewAnnounceConfigMapForCR creates the ConfigMap holding the announce config of each exposed pod, indexed by pod name
+func NewAnnounceConfigMapForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string, announces map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            AnnounceConfigMapName(cluster.Name),
+			Namespace:       cluster.Namespace,
+			Labels:          labels,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Data: announces,
+	}
+}
+
+func AnnounceConfigMapName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", "redis-cluster-announce", clusterName)
+}
+
 func NewConfigMapForRestore(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,87 @@
+package monitors
+
+import (
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
+)
+
+// Namespace returns the namespace of the monitor of the cluster, spec.monitor.prometheus.namespace or the
+// namespace of the cluster.
+func Namespace(cluster *redisv1alpha1.DistributedRedisCluster) string {
+	if ns := cluster.Spec.Monitor.Prometheus.Namespace; ns != "" {
+		return ns
+	}
+	return cluster.Namespace
+}
+
+// Labels returns the labels selecting the monitors of the cluster, in any namespace.
+func Labels(cluster *redisv1alpha1.DistributedRedisCluster) map[string]string {
+	return map[string]string{
+		redisv1alpha1.LabelClusterName:      cluster.Name,
+		redisv1alpha1.LabelClusterNamespace: cluster.Namespace,
+	}
+}
+
+func newObjectMeta(cluster *redisv1alpha1.DistributedRedisCluster) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{
+		Name:      cluster.Name,
+		Namespace: Namespace(cluster),
+		Labels:    utils.MergeLabels(cluster.Spec.Monitor.Prometheus.Labels, Labels(cluster)),
+	}
+	// a monitor created in the namespace of Prometheus can't be garbage collected with the cluster
+	if meta.Namespace == cluster.Namespace {
+		meta.OwnerReferences = redisv1alpha1.DefaultOwnerReferences(cluster)
+	}
+	return meta
+}
+
+// NewServiceMonitorForCR creates a new ServiceMonitor scraping the endpoints of the metrics service of the given Cluster.
+func NewServiceMonitorForCR(cluster *redisv1alpha1.DistributedRedisCluster) *monitoringv1.ServiceMonitor {
+	return &monitoringv1.ServiceMonitor{
+		ObjectMeta: newObjectMeta(cluster),
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints: []monitoringv1.Endpoint{
+				{
+					Port:     services.MetricsPortName,
+					Path:     redisv1alpha1.PrometheusExporterTelemetryPath,
+					Interval: cluster.Spec.Monitor.Prometheus.Interval,
+				},
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					redisv1alpha1.LabelClusterName: cluster.Name,
+					services.LabelMetrics:          "true",
+				},
+			},
+			NamespaceSelector: monitoringv1.NamespaceSelector{
+				MatchNames: []string{cluster.Namespace},
+			},
+		},
+	}
+}
+
+// NewPodMonitorForCR creates a new PodMonitor scraping the exporter of the redis pods of the given Cluster.
+func NewPodMonitorForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *monitoringv1.PodMonitor {
+	return &monitoringv1.PodMonitor{
+		ObjectMeta: newObjectMeta(cluster),
+		Spec: monitoringv1.PodMonitorSpec{
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+				{
+					Port:     services.MetricsPortName,
+					Path:     redisv1alpha1.PrometheusExporterTelemetryPath,
+					Interval: cluster.Spec.Monitor.Prometheus.Interval,
+				},
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			NamespaceSelector: monitoringv1.NamespaceSelector{
+				MatchNames: []string{cluster.Namespace},
+			},
+		},
+	}
+}
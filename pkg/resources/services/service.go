@@ -1,10 +1,22 @@
 package services
 
 import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
+)
+
+const (
+	// MetricsPortName is the name of the port of the exporter, in the pods and in the metrics service
+	MetricsPortName = "prom-http"
+	// LabelMetrics selects the metrics service of a cluster
+	LabelMetrics = redisv1alpha1.GenericKey + "/metrics"
 )
 
 // NewHeadLessSvcForCR creates a new headless service for the given Cluster.
@@ -27,3 +39,84 @@ func NewHeadLessSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels
 
 	return svc
 }
+
+// NewSvcForCR creates a new ClusterIP service in front of all the redis pods of the given Cluster.
+func NewSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *corev1.Service {
+	clientPort := corev1.ServicePort{Name: "client", Port: 6379}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          labels,
+			Name:            ClientServiceName(cluster.Spec.ServiceName),
+			Namespace:       cluster.Namespace,
+			Annotations:     cluster.Spec.Expose.Annotations,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports:    []corev1.ServicePort{clientPort},
+			Selector: labels,
+		},
+	}
+
+	return svc
+}
+
+// NewPodSvcForCR creates a new service exposing a single redis pod of the given Cluster, the type of the
+// service is spec.expose.podServiceType.
+func NewPodSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, podName string, labels map[string]string) *corev1.Service {
+	clientPort := corev1.ServicePort{Name: "client", Port: 6379}
+	gossipPort := corev1.ServicePort{Name: "gossip", Port: 16379}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          utils.MergeLabels(labels, map[string]string{redisv1alpha1.LabelPodName: podName}),
+			Name:            podName,
+			Namespace:       cluster.Namespace,
+			Annotations:     cluster.Spec.Expose.Annotations,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  cluster.Spec.Expose.PodServiceType,
+			Ports: []corev1.ServicePort{clientPort, gossipPort},
+			Selector: map[string]string{
+				appsv1.StatefulSetPodNameLabel: podName,
+			},
+		},
+	}
+
+	return svc
+}
+
+// NewMetricsSvcForCR creates a new headless service on the port of the exporter of the redis pods of the
+// given Cluster, its endpoints are scraped through a ServiceMonitor.
+func NewMetricsSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *corev1.Service {
+	metricsPort := corev1.ServicePort{
+		Name:       MetricsPortName,
+		Port:       cluster.Spec.Monitor.Prometheus.Port,
+		TargetPort: intstr.FromString(MetricsPortName),
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          utils.MergeLabels(labels, map[string]string{LabelMetrics: "true"}),
+			Name:            MetricsServiceName(cluster.Spec.ServiceName),
+			Namespace:       cluster.Namespace,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:     []corev1.ServicePort{metricsPort},
+			Selector:  labels,
+			ClusterIP: corev1.ClusterIPNone,
+		},
+	}
+
+	return svc
+}
+
+// MetricsServiceName returns the name of the service of the exporters.
+func MetricsServiceName(serviceName string) string {
+	return fmt.Sprintf("%s-metrics", serviceName)
+}
+
+// ClientServiceName returns the name of the ClusterIP service of the clients.
+func ClientServiceName(serviceName string) string {
+	return fmt.Sprintf("%s-client", serviceName)
+}
@@ -1,15 +1,22 @@
 package statefulsets
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
 const (
@@ -20,18 +27,37 @@ const (
 	graceTime = 30
 
 	passwordENV = "REDIS_PASSWORD"
+	// passwordFileENV is the file the exporter reads the password from
+	passwordFileENV = "REDIS_PASSWORD_FILE"
+	// operatorPasswordENV is the password of the ACL user of the operator
+	operatorPasswordENV = "REDIS_OPERATOR_PASSWORD"
 
 	configMapVolumeName = "conf"
+
+	// keys of a kubernetes.io/tls Secret, ca.crt is added by cert-manager
+	tlsCertKey       = corev1.TLSCertKey
+	tlsPrivateKeyKey = corev1.TLSPrivateKeyKey
+	tlsCAKey         = "ca.crt"
 )
 
-// NewStatefulSetForCR creates a new StatefulSet for the given Cluster.
-func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) (*appsv1.StatefulSet, error) {
+// operatorACLRules are the ACL rules of the user of the operator, restricted to the commands it runs to
+// manage the cluster, move the keys of the slots and apply the users.
+var operatorACLRules = []string{
+	"~*", "-@all",
+	"+cluster", "+info", "+config", "+flushall", "+migrate", "+client",
+	"+bgsave", "+lastsave", "+acl", "+ping", "+memory",
+}
+
+// NewStatefulSetForCR creates a new StatefulSet for the given shard of the Cluster.
+func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, shard int, backup *redisv1alpha1.RedisClusterBackup, labels map[string]string) (*appsv1.StatefulSet, error) {
 	password := redisPassword(cluster)
 	volumes := redisVolumes(cluster, backup)
-	name := ClusterStatefulSetName(cluster.Name)
+	name := ClusterStatefulSetName(cluster.Name, shard)
 	namespace := cluster.Namespace
 	spec := cluster.Spec
-	size := spec.MasterSize * (spec.ClusterReplicas + 1)
+	size := spec.ClusterReplicas + 1
+	clusterLabels := labels
+	labels = ShardLabels(labels, shard)
 	ss := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            name,
@@ -42,8 +68,9 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, backup
 		Spec: appsv1.StatefulSetSpec{
 			ServiceName: cluster.Spec.ServiceName,
 			Replicas:    &size,
+			// pods are restarted by the operator, so that masters can be failed over before being restarted
 			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
-				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+				Type: appsv1.OnDeleteStatefulSetStrategyType,
 			},
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
@@ -54,7 +81,7 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, backup
 					Annotations: cluster.Spec.Annotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:        getAffinity(spec.Affinity, labels),
+					Affinity:        getAffinity(spec.Affinity, spec.TopologyKey, clusterLabels, labels),
 					Tolerations:     spec.ToleRations,
 					SecurityContext: spec.SecurityContext,
 					NodeSelector:    cluster.Spec.NodeSelector,
@@ -77,37 +104,117 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, backup
 		}
 	}
 	if spec.Monitor != nil {
-		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers, redisExporterContainer(cluster, password))
+		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers, redisExporterContainer(cluster))
 	}
 	if spec.Init != nil {
-		initContainer, err := redisInitContainer(cluster, backup, password)
+		initContainer, err := redisInitContainer(cluster, name, shard, backup, password)
 		if err != nil {
 			return nil, err
 		}
 		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers, initContainer)
 	}
+	hash, err := templateHash(&ss.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	ss.Annotations = map[string]string{
+		redisv1alpha1.AnnotationTemplateHash: hash,
+	}
 	return ss, nil
 }
 
-func getAffinity(affinity *corev1.Affinity, labels map[string]string) *corev1.Affinity {
+// templateHash returns the hash of the pod template, it is used to detect that the pods need to be updated.
+func templateHash(template *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%x", hasher.Sum32()), nil
+}
+
+// IsTemplateChanged returns true if the pod template of the statefulSet differs from the desired one.
+func IsTemplateChanged(current, desired *appsv1.StatefulSet) bool {
+	return current.Annotations[redisv1alpha1.AnnotationTemplateHash] != desired.Annotations[redisv1alpha1.AnnotationTemplateHash]
+}
+
+// IsTemplateEdited returns true if the pod template of the statefulSet differs from the desired one in a field set
+// by the operator, the fields defaulted by the apiserver are ignored.
+func IsTemplateEdited(current, desired *appsv1.StatefulSet) bool {
+	template := desired.Spec.Template.DeepCopy()
+	for i := range template.Spec.InitContainers {
+		setProbeDefaults(&template.Spec.InitContainers[i])
+	}
+	for i := range template.Spec.Containers {
+		setProbeDefaults(&template.Spec.Containers[i])
+	}
+	return !equality.Semantic.DeepDerivative(*template, current.Spec.Template)
+}
+
+// setProbeDefaults sets the defaults of the apiserver on the unset numeric fields of the probes of the container,
+// the zero values would be compared as set.
+func setProbeDefaults(container *corev1.Container) {
+	for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+		if probe == nil {
+			continue
+		}
+		if probe.TimeoutSeconds == 0 {
+			probe.TimeoutSeconds = 1
+		}
+		if probe.PeriodSeconds == 0 {
+			probe.PeriodSeconds = 10
+		}
+		if probe.SuccessThreshold == 0 {
+			probe.SuccessThreshold = 1
+		}
+		if probe.FailureThreshold == 0 {
+			probe.FailureThreshold = 3
+		}
+	}
+}
+
+func getAffinity(affinity *corev1.Affinity, topologyKey string, clusterLabels, labels map[string]string) *corev1.Affinity {
 	if affinity != nil {
 		return affinity
 	}
 
 	// return a SOFT anti-affinity by default
-	return &corev1.Affinity{
-		PodAntiAffinity: &corev1.PodAntiAffinity{
-			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
-				{
-					Weight: 100,
-					PodAffinityTerm: corev1.PodAffinityTerm{
-						TopologyKey: hostnameTopologyKey,
-						LabelSelector: &metav1.LabelSelector{
-							MatchLabels: labels,
-						},
-					},
+	terms := []corev1.WeightedPodAffinityTerm{
+		{
+			Weight: 100,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				TopologyKey: hostnameTopologyKey,
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: labels,
+				},
+			},
+		},
+	}
+	if topologyKey != "" {
+		// spread the pods of the shard across the domains, so that the replicas are not in the domain of their
+		// master, then the pods of the cluster, so that the masters of the shards are not either.
+		terms = append(terms, corev1.WeightedPodAffinityTerm{
+			Weight: 100,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				TopologyKey: topologyKey,
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: labels,
+				},
+			},
+		}, corev1.WeightedPodAffinityTerm{
+			Weight: 50,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				TopologyKey: topologyKey,
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: clusterLabels,
 				},
 			},
+		})
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: terms,
 		},
 	}
 }
@@ -132,10 +239,29 @@ func persistentClaim(cluster *redisv1alpha1.DistributedRedisCluster, labels map[
 	}
 }
 
-func ClusterStatefulSetName(clusterName string) string {
+// DataClaimName returns the name of the PVC holding the data of a redis pod.
+func DataClaimName(podName string) string {
+	return fmt.Sprintf("%s-%s", redisStorageVolumeName, podName)
+}
+
+// ClusterStatefulSetName returns the name of the statefulSet of a shard.
+func ClusterStatefulSetName(clusterName string, shard int) string {
+	return fmt.Sprintf("drc-%s-%d", clusterName, shard)
+}
+
+// LegacyClusterStatefulSetName returns the name of the single statefulSet holding every redis pod,
+// used by the clusters created before each shard had its own statefulSet.
+func LegacyClusterStatefulSetName(clusterName string) string {
 	return fmt.Sprintf("drc-%s", clusterName)
 }
 
+// ShardLabels returns the labels of the pods of a shard.
+func ShardLabels(labels map[string]string, shard int) map[string]string {
+	return utils.MergeLabels(labels, map[string]string{
+		redisv1alpha1.LabelShard: strconv.Itoa(shard),
+	})
+}
+
 func getRedisCommand(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) []string {
 	cmd := []string{
 		"/conf/fix-ip.sh",
@@ -147,6 +273,22 @@ func getRedisCommand(cluster *redisv1alpha1.DistributedRedisCluster, password *c
 		cmd = append(cmd, fmt.Sprintf("--requirepass '$(%s)'", passwordENV),
 			fmt.Sprintf("--masterauth '$(%s)'", passwordENV))
 	}
+	if cluster.IsACLEnabled() {
+		// declared in the configuration so that the user exists as soon as a node starts
+		cmd = append(cmd, fmt.Sprintf("--user %s on '>$(%s)' %s", redisv1alpha1.ACLOperatorUser, operatorPasswordENV,
+			strings.Join(operatorACLRules, " ")))
+	}
+	if cluster.IsTLSEnabled() {
+		// the plaintext port is disabled, clients, replicas and the cluster bus all use TLS
+		cmd = append(cmd,
+			"--port 0",
+			"--tls-port 6379",
+			fmt.Sprintf("--tls-cert-file %s", tlsFile(tlsCertKey)),
+			fmt.Sprintf("--tls-key-file %s", tlsFile(tlsPrivateKeyKey)),
+			fmt.Sprintf("--tls-ca-cert-file %s", tlsFile(tlsCAKey)),
+			"--tls-cluster yes",
+			"--tls-replication yes")
+	}
 	if len(cluster.Spec.Command) > 0 {
 		cmd = append(cmd, cluster.Spec.Command...)
 	}
@@ -155,6 +297,10 @@ func getRedisCommand(cluster *redisv1alpha1.DistributedRedisCluster, password *c
 
 func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) corev1.Container {
 	probeArg := "redis-cli -h $(hostname)"
+	if cluster.IsTLSEnabled() {
+		probeArg = fmt.Sprintf("%s --tls --cert %s --key %s --cacert %s", probeArg,
+			tlsFile(tlsCertKey), tlsFile(tlsPrivateKeyKey), tlsFile(tlsCAKey))
+	}
 
 	container := corev1.Container{
 		Name:  redisServerName,
@@ -171,7 +317,7 @@ func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, passwo
 				Protocol:      corev1.ProtocolTCP,
 			},
 		},
-		VolumeMounts: volumeMounts(),
+		VolumeMounts: volumeMounts(cluster),
 		Command:      getRedisCommand(cluster, password),
 		LivenessProbe: &corev1.Probe{
 			InitialDelaySeconds: graceTime,
@@ -223,11 +369,39 @@ func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, passwo
 	if password != nil {
 		container.Env = append(container.Env, *password)
 	}
+	if cluster.IsACLEnabled() {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: operatorPasswordENV,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: cluster.ACLOperatorSecretName(),
+					},
+					Key: "password",
+				},
+			},
+		})
+	}
+	if cluster.IsTLSEnabled() {
+		// used by shutdown.sh
+		container.Env = append(container.Env, TLSDirEnv())
+	}
+	if cluster.ArePodsExposed() && cluster.Spec.Expose.PodServiceType == corev1.ServiceTypeNodePort {
+		// used by fix-ip.sh, a node port service is reached on the IP of the kubernetes node
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: "HOST_IP",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "status.hostIP",
+				},
+			},
+		})
+	}
 
 	return container
 }
 
-func redisExporterContainer(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) corev1.Container {
+func redisExporterContainer(cluster *redisv1alpha1.DistributedRedisCluster) corev1.Container {
 	container := corev1.Container{
 		Name: "exporter",
 		Args: append([]string{
@@ -247,13 +421,27 @@ func redisExporterContainer(cluster *redisv1alpha1.DistributedRedisCluster, pass
 		Resources:       cluster.Spec.Monitor.Resources,
 		SecurityContext: cluster.Spec.Monitor.SecurityContext,
 	}
-	if password != nil {
-		container.Env = append(container.Env, *password)
+	if cluster.Spec.PasswordSecret != nil {
+		// the mounted Secret follows the rotations of the password, unlike an environment variable
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  passwordFileENV,
+			Value: redisv1alpha1.PasswordMountPath + "/password",
+		})
+		container.VolumeMounts = append(container.VolumeMounts, passwordVolumeMount())
+	}
+	if cluster.IsTLSEnabled() {
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "REDIS_ADDR", Value: "rediss://localhost:6379"},
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_CERT_FILE", Value: tlsFile(tlsCertKey)},
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", Value: tlsFile(tlsPrivateKeyKey)},
+			corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CA_CERT_FILE", Value: tlsFile(tlsCAKey)},
+		)
+		container.VolumeMounts = append(container.VolumeMounts, TLSVolumeMount())
 	}
 	return container
 }
 
-func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup, password *corev1.EnvVar) (corev1.Container, error) {
+func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, ssName string, shard int, backup *redisv1alpha1.RedisClusterBackup, password *corev1.EnvVar) (corev1.Container, error) {
 	backupSpec := backup.Spec.Backend
 	bucket, err := backupSpec.Container()
 	if err != nil {
@@ -278,12 +466,10 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, backup *
 		},
 		Env: []corev1.EnvVar{
 			{
-				Name: "POD_NAME",
-				ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{
-						FieldPath: "metadata.name",
-					},
-				},
+				// the restore tool picks the snapshot from the ordinal at the end of the pod name,
+				// a shard is restored from the snapshot of the same index.
+				Name:  "POD_NAME",
+				Value: ssName,
 			},
 			{
 				Name: "REDIS_RESTORE_SUCCEEDED",
@@ -313,14 +499,20 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, backup *
 			},
 		},
 	}
+	if snapshot := backup.Shard(int32(shard)); snapshot != nil && snapshot.SHA256 != "" {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "REDIS_SHA256",
+			Value: snapshot.SHA256,
+		})
+	}
 	if password != nil {
 		container.Env = append(container.Env, *password)
 	}
 	return container, nil
 }
 
-func volumeMounts() []corev1.VolumeMount {
-	return []corev1.VolumeMount{
+func volumeMounts(cluster *redisv1alpha1.DistributedRedisCluster) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{
 		{
 			Name:      redisStorageVolumeName,
 			MountPath: "/data",
@@ -330,6 +522,62 @@ func volumeMounts() []corev1.VolumeMount {
 			MountPath: "/conf",
 		},
 	}
+	if cluster.IsTLSEnabled() {
+		mounts = append(mounts, TLSVolumeMount())
+	}
+	if cluster.Spec.PasswordSecret != nil {
+		mounts = append(mounts, passwordVolumeMount())
+	}
+	if cluster.ArePodsExposed() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      redisv1alpha1.AnnounceVolumeName,
+			ReadOnly:  true,
+			MountPath: redisv1alpha1.AnnounceMountPath,
+		})
+	}
+	return mounts
+}
+
+func tlsFile(key string) string {
+	return path.Join(redisv1alpha1.TLSMountPath, key)
+}
+
+// TLSVolume returns the volume of the certificate of the redis nodes.
+func TLSVolume(cluster *redisv1alpha1.DistributedRedisCluster) corev1.Volume {
+	return corev1.Volume{
+		Name: redisv1alpha1.TLSVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: cluster.TLSSecretName(),
+			},
+		},
+	}
+}
+
+// passwordVolumeMount returns the read-only mount of spec.passwordSecret.
+func passwordVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      redisv1alpha1.PasswordVolumeName,
+		ReadOnly:  true,
+		MountPath: redisv1alpha1.PasswordMountPath,
+	}
+}
+
+// TLSVolumeMount returns the read-only mount of the certificate of the redis nodes.
+func TLSVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      redisv1alpha1.TLSVolumeName,
+		ReadOnly:  true,
+		MountPath: redisv1alpha1.TLSMountPath,
+	}
+}
+
+// TLSDirEnv returns the environment variable telling the redis scripts to connect over TLS.
+func TLSDirEnv() corev1.EnvVar {
+	return corev1.EnvVar{
+		Name:  redisv1alpha1.TLSDirENV,
+		Value: redisv1alpha1.TLSMountPath,
+	}
 }
 
 // Returns the REDIS_PASSWORD environment variable.
@@ -372,6 +620,34 @@ func redisVolumes(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv
 	if dataVolume != nil {
 		volumes = append(volumes, *dataVolume)
 	}
+	if cluster.IsTLSEnabled() {
+		volumes = append(volumes, TLSVolume(cluster))
+	}
+	if cluster.Spec.PasswordSecret != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: redisv1alpha1.PasswordVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: cluster.Spec.PasswordSecret.Name,
+				},
+			},
+		})
+	}
+	if cluster.ArePodsExposed() {
+		// optional, the configMap is written by the operator once the services of the pods have an address
+		optional := true
+		volumes = append(volumes, corev1.Volume{
+			Name: redisv1alpha1.AnnounceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: configmaps.AnnounceConfigMapName(cluster.Name),
+					},
+					Optional: &optional,
+				},
+			},
+		})
+	}
 	if cluster.Spec.Init != nil {
 		volumes = append(volumes, corev1.Volume{
 			Name: "osmconfig",
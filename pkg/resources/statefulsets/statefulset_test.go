@@ -0,0 +1,107 @@
+package statefulsets
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func newTemplateStatefulSet(container corev1.Container) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{container}},
+			},
+		},
+	}
+}
+
+func TestIsTemplateEdited(t *testing.T) {
+	desired := corev1.Container{
+		Name:  redisServerName,
+		Image: "redis:5.0.4",
+		Env:   []corev1.EnvVar{{Name: passwordENV, Value: "password"}},
+		LivenessProbe: &corev1.Probe{
+			InitialDelaySeconds: 30,
+			Handler:             corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"redis-cli", "ping"}}},
+		},
+	}
+	defaulted := *desired.DeepCopy()
+	defaulted.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	defaulted.ImagePullPolicy = corev1.PullIfNotPresent
+	defaulted.LivenessProbe.TimeoutSeconds = 1
+	defaulted.LivenessProbe.PeriodSeconds = 10
+	defaulted.LivenessProbe.SuccessThreshold = 1
+	defaulted.LivenessProbe.FailureThreshold = 3
+	image := *defaulted.DeepCopy()
+	image.Image = "redis:5.0.5"
+	env := *defaulted.DeepCopy()
+	env.Env = nil
+	probe := *defaulted.DeepCopy()
+	probe.LivenessProbe.PeriodSeconds = 5
+
+	tests := []struct {
+		name    string
+		current corev1.Container
+		want    bool
+	}{
+		{
+			name:    "template defaulted by the apiserver",
+			current: defaulted,
+			want:    false,
+		},
+		{
+			name:    "image edited",
+			current: image,
+			want:    true,
+		},
+		{
+			name:    "env removed",
+			current: env,
+			want:    true,
+		},
+		{
+			name:    "probe period edited",
+			current: probe,
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemplateEdited(newTemplateStatefulSet(tt.current), newTemplateStatefulSet(desired)); got != tt.want {
+				t.Errorf("IsTemplateEdited() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_redisExporterContainer(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			PasswordSecret: &corev1.LocalObjectReference{Name: "test-password"},
+			Monitor: &redisv1alpha1.AgentSpec{
+				Image:      "oliver006/redis_exporter:latest",
+				Prometheus: &redisv1alpha1.PrometheusSpec{Port: 9100},
+			},
+		},
+	}
+
+	container := redisExporterContainer(cluster)
+
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if _, ok := env[passwordENV]; ok {
+		t.Errorf("redisExporterContainer() env = %v, want the password read from the mounted Secret", container.Env)
+	}
+	if got, want := env[passwordFileENV], redisv1alpha1.PasswordMountPath+"/password"; got != want {
+		t.Errorf("redisExporterContainer() %s = %s, want %s", passwordFileENV, got, want)
+	}
+	if len(container.VolumeMounts) != 1 || container.VolumeMounts[0] != passwordVolumeMount() {
+		t.Errorf("redisExporterContainer() volume mounts = %v, want the password Secret", container.VolumeMounts)
+	}
+}